@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hilverd/sitemapper/crawler"
+	"github.com/hilverd/sitemapper/httpclient"
+	"github.com/hilverd/sitemapper/linkextractor"
+	"github.com/hilverd/sitemapper/logging"
+	"github.com/hilverd/sitemapper/server"
+	"github.com/hilverd/sitemapper/sitemap"
+	"github.com/hilverd/sitemapper/watch"
+)
+
+const usage = `Usage: sitemapper COMMAND [OPTIONS] [ARGUMENTS]
+
+Commands:
+  crawl    crawl web pages and print or save a site map
+  check    crawl web pages and list broken links
+  convert  convert a saved crawl to another format
+  stats    print statistics about a saved crawl
+  diff     compare two saved crawls
+  serve    run crawls submitted over an HTTP API
+  watch    crawl on a schedule and send alerts when the site changes
+  help     show help for a command, as in sitemapper help crawl
+
+sitemapper [OPTIONS] SEED_URL is short for sitemapper crawl [OPTIONS] SEED_URL.
+Crawls are saved using sitemapper crawl -format ndjson SEED_URL > FILE.
+`
+
+var commandUsage = map[string]string{
+	"crawl": `Usage: sitemapper crawl [OPTIONS] SEED_URL
+Crawl web pages starting from SEED_URL and print a basic site map to standard output, or write it to
+the files given by -output and the -output-FORMAT options.
+`,
+	"check": `Usage: sitemapper check [OPTIONS] SEED_URL
+Crawl web pages starting from SEED_URL and list broken links, which are pages that could not be
+reached or gave an error response, along with the pages that link to them. Exits with status 1 if
+any broken links were found.
+`,
+	"convert": `Usage: sitemapper convert [OPTIONS] [FILE]
+Convert a crawl saved in NDJSON format from FILE (or standard input) to another format.
+`,
+	"stats": `Usage: sitemapper stats [OPTIONS] [FILE]
+Print statistics about a crawl saved in NDJSON format in FILE (or standard input).
+`,
+	"diff": `Usage: sitemapper diff [OPTIONS] OLD_FILE NEW_FILE
+Compare two crawls saved in NDJSON format. Lists added pages (+), removed pages (-), newly broken
+pages (!) and pages whose depth or links changed (~). Exits with status 1 if there are differences.
+`,
+	"serve": `Usage: sitemapper serve [OPTIONS]
+Run crawl jobs submitted over an HTTP API. POST a JSON object such as {"seed_url": "https://example.com/",
+"max_depth": 2} to /crawls to submit a job, GET /crawls/ID for its state and progress, GET
+/crawls/ID/result?format=ndjson|csv|tsv|text|xml|html for its result (with table=pages|edges and
+columns=LIST for csv and tsv), and DELETE /crawls/ID to cancel it. Other options are used for all
+jobs, and as defaults for max_depth, max_pages, max_pages_per_host, max_bytes,
+max_concurrent_requests (which jobs cannot exceed), deterministic and keep_failed_pages.
+`,
+	"watch": `Usage: sitemapper watch [OPTIONS] SEED_URL
+Crawl web pages starting from SEED_URL on a schedule given by -every or -cron, and save each crawl in
+NDJSON format in the -history directory. After each crawl, compare it with the previous one and send
+alerts to the -webhook URL and the -hook-command if pages disappeared, pages became broken, or the
+number of pages changed by more than -page-count-change.
+`,
+}
+
+func run(arguments []string, stdout io.Writer) int {
+	if len(arguments) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 1
+	}
+
+	switch arguments[0] {
+	case "crawl":
+		return runCrawl(arguments[1:], stdout)
+	case "check":
+		return runCheck(arguments[1:], stdout)
+	case "convert":
+		return runConvert(arguments[1:], stdout)
+	case "stats":
+		return runStats(arguments[1:], stdout)
+	case "diff":
+		return runDiff(arguments[1:], stdout)
+	case "serve":
+		return runServe(arguments[1:])
+	case "watch":
+		return runWatch(arguments[1:])
+	case "help", "-h", "-help", "--help":
+		return runHelp(arguments[1:], stdout)
+	default:
+		return runCrawl(arguments, stdout)
+	}
+}
+
+func runHelp(arguments []string, stdout io.Writer) int {
+	if len(arguments) == 0 {
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+
+	if _, ok := commandUsage[arguments[0]]; !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", arguments[0], usage)
+		return 1
+	}
+
+	return run([]string{arguments[0], "-h"}, stdout)
+}
+
+func runCrawl(arguments []string, stdout io.Writer) int {
+	configuration, httpClient, loginForm, outputs := parseCommandLineOptions("crawl", arguments)
+	configuration.SitemapWriter = stdout
+
+	stopServingMetrics := serveMetrics(outputs)
+	defer stopServingMetrics()
+
+	// Log in before any output files are created, so that a failed login does not leave them behind.
+	logIn(httpClient, loginForm)
+
+	files := &outputFiles{}
+	stopAbortingOnSignal := abortOnSignal(files)
+	defer stopAbortingOnSignal()
+
+	var writeErr error
+	textWriters := make([]io.Writer, 0)
+	pageWriters := make([]sitemap.PageWriter, 0)
+	for _, output := range outputs.outputs {
+		if output.format == "text" {
+			textWriter, err := openOutput(output, stdout, files)
+			if err != nil {
+				files.abort()
+				logger.Fatal("Failed to create output file", "error", err)
+			}
+			textWriters = append(textWriters, textWriter)
+			continue
+		}
+
+		pageWriter, err := pageWriterFor(output, outputs, stdout, files)
+		if err != nil {
+			files.abort()
+			logger.Fatal("Failed to create output file", "error", err)
+		}
+
+		pageWriters = append(pageWriters, pageWriter)
+		configuration.Observers = append(configuration.Observers, crawler.OnPageCrawled(func(URL url.URL, page sitemap.Page) {
+			if writeErr == nil {
+				writeErr = pageWriter.WritePage(URL, page)
+			}
+		}))
+	}
+
+	result, report := crawler.CrawlWithReport(configuration, httpClient)
+
+	for _, pageWriter := range pageWriters {
+		if writeErr == nil {
+			writeErr = pageWriter.Flush()
+		}
+	}
+	for _, textWriter := range textWriters {
+		if writeErr == nil {
+			_, writeErr = fmt.Fprintln(textWriter, result.PrettyPrint())
+		}
+	}
+
+	printReport(report)
+
+	if writeErr != nil {
+		files.abort()
+		logger.Fatal("Failed to write sitemap", "error", writeErr)
+	}
+	if err := files.commit(); err != nil {
+		logger.Fatal(err.Error())
+	}
+
+	return 0
+}
+
+// abortOnSignal removes any partially written output files when the crawl is interrupted, until the
+// returned function is called.
+func abortOnSignal(files *outputFiles) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-signals:
+			files.abort()
+			os.Exit(130)
+		case <-done:
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+func runCheck(arguments []string, stdout io.Writer) int {
+	configuration, httpClient, loginForm, outputs := parseCommandLineOptions("check", arguments)
+
+	stopServingMetrics := serveMetrics(outputs)
+	defer stopServingMetrics()
+
+	logIn(httpClient, loginForm)
+	result, report := crawler.CrawlWithReport(configuration, httpClient)
+	brokenLinks := result.BrokenLinks()
+
+	for _, brokenLink := range brokenLinks {
+		fmt.Fprintf(stdout, "%s: %s\n", brokenLink.URL.String(), brokenLink.Error)
+		for _, URL := range brokenLink.LinkedFrom {
+			fmt.Fprintf(stdout, "  linked from %s\n", URL.String())
+		}
+	}
+
+	printReport(report)
+	if logger.Format() == logging.Text {
+		fmt.Fprintf(os.Stderr, "Found %d broken links\n", len(brokenLinks))
+	} else {
+		logger.Info("Found broken links", "count", len(brokenLinks))
+	}
+
+	if len(brokenLinks) > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// printReport prints a summary of a crawl, unless log messages are written as JSON, in which case
+// the summary is logged when the crawl finishes.
+func printReport(report crawler.Report) {
+	if logger.Format() == logging.Text {
+		fmt.Fprintln(os.Stderr, report)
+	}
+}
+
+// logIn submits the login form, if there is one, so that the cookies it sets are sent while
+// crawling.
+func logIn(httpClient linkextractor.HTTPClient, loginForm *httpclient.LoginForm) {
+	if loginForm != nil {
+		if err := loginForm.Submit(httpClient.Do); err != nil {
+			logger.Fatal(err.Error())
+		}
+	}
+}
+
+func runConvert(arguments []string, stdout io.Writer) int {
+	flags := newFlagSet("convert")
+	to := flags.String("to", "text", "format to convert to: text, ndjson, csv, tsv or html")
+	table := flags.String("table", sitemap.PagesTable, "table to write for csv and tsv: pages or edges")
+	pageColumns := flags.String("page-columns", "", "comma-separated columns of the pages table, out of "+strings.Join(sitemap.PageColumns, ",")+"; with inbound, rows are only written once all pages have been crawled (default "+strings.Join(sitemap.DefaultPageColumns, ",")+")")
+	edgeColumns := flags.String("edge-columns", "", "comma-separated columns of the edges table (default "+strings.Join(sitemap.EdgeColumns, ",")+")")
+	_ = flags.Parse(arguments)
+
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 1
+	}
+
+	var pageWriter sitemap.PageWriter
+	switch *to {
+	case "text":
+	case "ndjson":
+		pageWriter = sitemap.NewNDJSONWriter(stdout)
+	case "csv", "tsv":
+		tableOptions, err := parseTableOptions(*table, *pageColumns, *edgeColumns)
+		if err != nil {
+			logger.Fatal(err.Error())
+		}
+		if pageWriter, err = tableWritersFor(output{format: *to}, tableOptions, stdout, nil); err != nil {
+			logger.Fatal(err.Error())
+		}
+	case "html":
+		pageWriter = sitemap.NewHTMLWriter(stdout)
+	default:
+		logger.Fatal("to must be one of text, ndjson, csv, tsv or html")
+	}
+
+	savedCrawl := readSavedCrawl(flags.Arg(0))
+
+	if pageWriter == nil {
+		fmt.Fprintln(stdout, savedCrawl.PrettyPrint())
+	} else if err := savedCrawl.Write(pageWriter); err != nil {
+		logger.Fatal("Failed to write sitemap", "error", err)
+	}
+
+	return 0
+}
+
+func runStats(arguments []string, stdout io.Writer) int {
+	flags := newFlagSet("stats")
+	_ = flags.Parse(arguments)
+
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 1
+	}
+
+	fmt.Fprintln(stdout, readSavedCrawl(flags.Arg(0)).Statistics())
+	return 0
+}
+
+func runServe(arguments []string) int {
+	var allowedHosts repeatedFlag
+	flags := newFlagSet("serve")
+	address := flags.String("addr", "localhost:8080", "address to serve the API on")
+	maxJobs := flags.Int("max-jobs", 2, "maximum number of crawls to run at the same time; other jobs are queued")
+	storeDirectory := flags.String("store", "", "directory to keep jobs and their results in, so they survive restarts (default keep them in memory)")
+	flags.Var(&allowedHosts, "allow-host", "only accept crawls of this host (can be repeated; default accept any host)")
+
+	configuration, httpClient, loginForm, _ := parseOptions(flags, arguments)
+	if len(configuration.Observers) > 0 {
+		logger.Fatal("progress and metrics-addr cannot be used with serve")
+	}
+
+	var store server.Store = server.NewMemoryStore()
+	if *storeDirectory != "" {
+		directoryStore, err := server.NewDirectoryStore(*storeDirectory)
+		if err != nil {
+			logger.Fatal("Failed to open job store", "directory", *storeDirectory, "error", err)
+		}
+		store = directoryStore
+	}
+
+	logIn(httpClient, loginForm)
+
+	crawlServer, err := server.New(server.Options{
+		Defaults:      configuration,
+		LinkExtractor: httpClient,
+		MaxJobs:       *maxJobs,
+		Store:         store,
+		AllowedHosts:  allowedHosts,
+		Logger:        logger,
+	})
+	if err != nil {
+		logger.Fatal("Failed to start server", "error", err)
+	}
+
+	listener, err := net.Listen("tcp", *address)
+	if err != nil {
+		logger.Fatal("Failed to listen", "address", *address, "error", err)
+	}
+
+	httpServer := &http.Server{Handler: crawlServer}
+	stopped := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+
+		logger.Info("Stopping server")
+		_ = httpServer.Shutdown(context.Background())
+		crawlServer.Close()
+		close(stopped)
+	}()
+
+	logger.Info("Serving crawl API", "url", "http://"+listener.Addr().String()+"/crawls")
+	if err := httpServer.Serve(listener); err != http.ErrServerClosed {
+		logger.Fatal("Failed to serve", "error", err)
+	}
+	<-stopped
+
+	return 0
+}
+
+func runWatch(arguments []string) int {
+	flags := newFlagSet("watch")
+	every := flags.Duration("every", 0, "crawl straight away and then at this interval, such as 6h")
+	cronExpression := flags.String("cron", "", "crawl at times matching this cron expression of five fields (minute hour day-of-month month day-of-week), in local time, such as '0 3 * * *'")
+	historyDirectory := flags.String("history", "", "directory to keep a snapshot of each crawl in")
+	keep := flags.Int("keep", 0, "number of most recent snapshots to keep (zero means keep all)")
+	webhookURL := flags.String("webhook", "", "URL to POST a JSON notification to when there are alerts")
+	webhookTimeout := flags.Duration("webhook-timeout", 30*time.Second, "how long to wait for the webhook to respond")
+	hookCommand := flags.String("hook-command", "", "shell command to run when there are alerts, with the JSON notification on standard input and SITEMAPPER_ALERTS, SITEMAPPER_SEED_URL, SITEMAPPER_SNAPSHOT and SITEMAPPER_PREVIOUS_SNAPSHOT set")
+	pageCountChange := flags.String("page-count-change", "", "raise an alert if the number of pages changes by more than this many pages, or by more than a percentage such as 10% (default no alert)")
+
+	configuration, httpClient, loginForm, outputs := parseOptions(flags, arguments)
+
+	var schedule watch.Schedule
+	switch {
+	case (*every == 0) == (*cronExpression == ""):
+		logger.Fatal("Exactly one of every and cron must be given")
+	case *every < 0:
+		logger.Fatal("every must be greater than zero")
+	case *every > 0:
+		schedule = watch.Every(*every)
+	default:
+		cron, err := watch.ParseCron(*cronExpression)
+		if err != nil {
+			logger.Fatal("Invalid cron expression", "error", err)
+		}
+		schedule = cron
+	}
+
+	switch {
+	case *historyDirectory == "":
+		logger.Fatal("history must be given")
+	case *keep < 0:
+		logger.Fatal("keep must be at least zero")
+	case *webhookTimeout <= 0:
+		logger.Fatal("webhook-timeout must be greater than zero")
+	}
+
+	var threshold watch.PageCountThreshold
+	if *pageCountChange != "" {
+		var err error
+		if threshold, err = watch.ParsePageCountThreshold(*pageCountChange); err != nil {
+			logger.Fatal("Invalid page-count-change", "error", err)
+		}
+	}
+
+	var notifiers []watch.Notifier
+	if *webhookURL != "" {
+		notifiers = append(notifiers, watch.Webhook{URL: *webhookURL, Timeout: *webhookTimeout})
+	}
+	if *hookCommand != "" {
+		notifiers = append(notifiers, watch.Command{Command: *hookCommand})
+	}
+
+	stopServingMetrics := serveMetrics(outputs)
+	defer stopServingMetrics()
+
+	watcher := watch.Watcher{
+		SeedURL:        configuration.SeedURL.String(),
+		Schedule:       schedule,
+		RunImmediately: *every > 0,
+		History:        watch.History{Directory: *historyDirectory, Keep: *keep},
+		Crawl: func(ctx context.Context) (sitemap.Sitemap, crawler.Report, error) {
+			if loginForm != nil {
+				if err := loginForm.Submit(httpClient.Do); err != nil {
+					return nil, crawler.Report{}, err
+				}
+			}
+
+			result, report := crawler.CrawlWithContext(ctx, configuration, httpClient)
+			printReport(report)
+			return result, report, nil
+		},
+		PageCountThreshold: threshold,
+		Notifiers:          notifiers,
+		Logger:             configuration.Logger,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := watcher.Run(ctx); err != nil {
+		logger.Fatal("Failed to watch", "error", err)
+	}
+
+	return 0
+}
+
+func runDiff(arguments []string, stdout io.Writer) int {
+	flags := newFlagSet("diff")
+	_ = flags.Parse(arguments)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	difference := sitemap.Diff(readSavedCrawl(flags.Arg(0)), readSavedCrawl(flags.Arg(1)))
+	if difference.IsEmpty() {
+		return 0
+	}
+
+	fmt.Fprintln(stdout, difference)
+	return 1
+}
+
+func newFlagSet(command string) *flag.FlagSet {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, commandUsage[command])
+
+		hasOptions := false
+		flags.VisitAll(func(*flag.Flag) { hasOptions = true })
+		if hasOptions {
+			fmt.Fprint(os.Stderr, "\nOptions:\n")
+			flags.PrintDefaults()
+		}
+	}
+
+	return flags
+}
+
+// readSavedCrawl reads a crawl saved in NDJSON format from a file, or from standard input if the
+// file name is empty or -.
+func readSavedCrawl(fileName string) sitemap.Sitemap {
+	var reader io.Reader = os.Stdin
+
+	if fileName == "" || fileName == "-" {
+		fileName = "from standard input"
+	} else {
+		file, err := os.Open(fileName)
+		if err != nil {
+			logger.Fatal("Failed to read saved crawl", "file", fileName, "error", err)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	result, err := sitemap.ReadNDJSON(reader)
+	if err != nil {
+		logger.Fatal("Failed to read saved crawl", "file", fileName, "error", err)
+	}
+
+	return result
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const savedCrawl = `{"url":"https://example.com/","depth":0,"links":["https://example.com/a","https://example.com/b"]}
+{"url":"https://example.com/a","depth":1,"links":[]}
+{"url":"https://example.com/b","depth":1,"links":["https://example.com/"]}
+`
+
+const changedCrawl = `{"url":"https://example.com/","depth":0,"links":["https://example.com/a"]}
+{"url":"https://example.com/a","depth":1,"links":[]}
+`
+
+func Test_run(t *testing.T) {
+	directory := t.TempDir()
+	savedCrawlFile := filepath.Join(directory, "saved.ndjson")
+	changedCrawlFile := filepath.Join(directory, "changed.ndjson")
+	if err := ioutil.WriteFile(savedCrawlFile, []byte(savedCrawl), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(changedCrawlFile, []byte(changedCrawl), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		arguments    []string
+		wantExitCode int
+		wantOutput   string
+	}{
+		{
+			name:      "convert to text",
+			arguments: []string{"convert", savedCrawlFile},
+			wantOutput: `https://example.com/
+  -> https://example.com/a
+  -> https://example.com/b
+
+https://example.com/a
+
+https://example.com/b
+  -> https://example.com/
+`,
+		},
+		{
+			name:      "convert to CSV",
+			arguments: []string{"convert", "-to", "csv", changedCrawlFile},
+			wantOutput: `url,depth,status,content_type,title,outbound
+https://example.com/,0,200,,,1
+https://example.com/a,1,200,,,0
+`,
+		},
+		{
+			name:       "convert to TSV edges with some columns",
+			arguments:  []string{"convert", "-to", "tsv", "-table", "edges", "-edge-columns", "target,source", changedCrawlFile},
+			wantOutput: "target\tsource\nhttps://example.com/a\thttps://example.com/\n",
+		},
+		{
+			name:      "stats",
+			arguments: []string{"stats", changedCrawlFile},
+			wantOutput: `Pages: 2 (0 failed, 0 broken)
+Links: 1 (0.5 per page)
+Retries: 0
+Bytes transferred: 0 (0 after decompression)
+Pages by depth:
+  0: 1
+  1: 1
+Most linked-to pages:
+  1 https://example.com/a
+`,
+		},
+		{
+			name:         "diff without differences",
+			arguments:    []string{"diff", savedCrawlFile, savedCrawlFile},
+			wantExitCode: 0,
+			wantOutput:   "",
+		},
+		{
+			name:         "diff with differences",
+			arguments:    []string{"diff", savedCrawlFile, changedCrawlFile},
+			wantExitCode: 1,
+			wantOutput: `- https://example.com/b
+~ https://example.com/
+    - https://example.com/b
+`,
+		},
+		{
+			name:       "help",
+			arguments:  []string{"help"},
+			wantOutput: usage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			if got := run(tt.arguments, &output); got != tt.wantExitCode {
+				t.Errorf("run() = %v, want %v", got, tt.wantExitCode)
+			}
+			if output.String() != tt.wantOutput {
+				t.Errorf("run() wrote %v, want %v", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func Test_runCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<a href="/missing">Missing</a>`)
+	}))
+	defer server.Close()
+
+	var output bytes.Buffer
+	if got := run([]string{"check", server.URL}, &output); got != 1 {
+		t.Errorf("run() = %v, want 1", got)
+	}
+	if want := server.URL + "/missing"; !strings.HasPrefix(output.String(), want) {
+		t.Errorf("run() wrote %v, want a broken link to %v", output.String(), want)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "REDACTED"
+
+var environmentVariablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Settings that only make sense on the command line.
+var commandLineOnlySettings = map[string]bool{
+	"config":       true,
+	"profile":      true,
+	"print-config": true,
+	"version":      true,
+}
+
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"Proxy-Authorization": true,
+}
+
+// configSettings holds the values from a config file by the name of the command line option they
+// set. Values in which environment variables were interpolated are treated as secrets.
+type configSettings struct {
+	values       map[string][]string
+	secretValues map[string]bool
+}
+
+func readConfigFile(fileName string, profile string) (configSettings, error) {
+	result := configSettings{values: map[string][]string{}, secretValues: map[string]bool{}}
+
+	contents, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return result, err
+	}
+
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(contents, &document); err != nil {
+		return result, fmt.Errorf("Failed to parse config file %s: %w", fileName, err)
+	}
+
+	if err := result.add(document); err != nil {
+		return result, fmt.Errorf("Invalid config file %s: %w", fileName, err)
+	}
+
+	if profile != "" {
+		profiles, _ := document["profiles"].(map[string]interface{})
+		profileDocument, ok := profiles[profile].(map[string]interface{})
+		if !ok {
+			return result, fmt.Errorf("Profile %q not found in config file %s", profile, fileName)
+		}
+
+		if err := result.add(profileDocument); err != nil {
+			return result, fmt.Errorf("Invalid profile %q in config file %s: %w", profile, fileName, err)
+		}
+	}
+
+	return result, nil
+}
+
+// add adds the settings in a document, replacing any values the settings had already.
+func (settings configSettings) add(document map[string]interface{}) error {
+	for name, value := range document {
+		if name == "profiles" {
+			continue
+		}
+
+		if commandLineOnlySettings[name] {
+			return fmt.Errorf("%s can only be given on the command line", name)
+		}
+
+		var rawValues []interface{}
+		switch value := value.(type) {
+		case []interface{}:
+			rawValues = value
+		case map[string]interface{}, nil:
+			return fmt.Errorf("%s should be a single value or a list of values", name)
+		default:
+			rawValues = []interface{}{value}
+		}
+
+		values := make([]string, 0, len(rawValues))
+		for _, rawValue := range rawValues {
+			value, interpolated, err := interpolateEnvironmentVariables(name, fmt.Sprint(rawValue))
+			if err != nil {
+				return err
+			}
+			if interpolated {
+				settings.secretValues[value] = true
+			}
+			values = append(values, value)
+		}
+
+		settings.values[name] = values
+	}
+
+	return nil
+}
+
+// interpolateEnvironmentVariables replaces references like ${NAME} with the values of environment
+// variables. Login fields are left alone because ParseLoginField already does this for them.
+func interpolateEnvironmentVariables(name string, value string) (string, bool, error) {
+	if name == "login-field" || !environmentVariablePattern.MatchString(value) {
+		return value, false, nil
+	}
+
+	var missing []string
+	result := environmentVariablePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		variable := environmentVariablePattern.FindStringSubmatch(reference)[1]
+		variableValue, ok := os.LookupEnv(variable)
+		if !ok {
+			missing = append(missing, variable)
+		}
+		return variableValue
+	})
+
+	if len(missing) > 0 {
+		return "", false, fmt.Errorf("%s refers to environment variable %s, which is not set", name, missing[0])
+	}
+
+	return result, true, nil
+}
+
+// applyConfigSettings sets options from a config file, except for ones that were given on the
+// command line. It returns the seed URL from the config file, if there is one.
+func applyConfigSettings(flags *flag.FlagSet, settings configSettings) (string, error) {
+	givenOnCommandLine := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { givenOnCommandLine[f.Name] = true })
+
+	names := make([]string, 0, len(settings.values))
+	for name := range settings.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	seedURL := ""
+	for _, name := range names {
+		values := settings.values[name]
+
+		if name == "seed-url" {
+			if len(values) != 1 {
+				return "", fmt.Errorf("seed-url should be a single value")
+			}
+			seedURL = values[0]
+			continue
+		}
+
+		f := flags.Lookup(name)
+		switch {
+		case f == nil:
+			return "", fmt.Errorf("Unknown setting %s in config file", name)
+		case givenOnCommandLine[name]:
+			continue
+		}
+
+		if _, repeated := f.Value.(*repeatedFlag); !repeated && len(values) != 1 {
+			return "", fmt.Errorf("%s should be a single value", name)
+		}
+
+		for _, value := range values {
+			if err := flags.Set(name, value); err != nil {
+				return "", fmt.Errorf("Invalid value for %s in config file: %w", name, err)
+			}
+		}
+	}
+
+	return seedURL, nil
+}
+
+// printEffectiveSettings writes all settings in the config file format, with anything that looks
+// like a secret redacted.
+func printEffectiveSettings(writer io.Writer, flags *flag.FlagSet, seedURL string, secretValues map[string]bool) error {
+	document := map[string]interface{}{}
+	if seedURL != "" {
+		document["seed-url"] = seedURL
+	}
+
+	flags.VisitAll(func(f *flag.Flag) {
+		if commandLineOnlySettings[f.Name] {
+			return
+		}
+
+		if values, repeated := f.Value.(*repeatedFlag); repeated {
+			redactedValues := make([]string, 0, len(*values))
+			for _, value := range *values {
+				redactedValues = append(redactedValues, redactSetting(f.Name, value, secretValues))
+			}
+			document[f.Name] = redactedValues
+			return
+		}
+
+		switch value := f.Value.(flag.Getter).Get().(type) {
+		case string:
+			document[f.Name] = redactSetting(f.Name, value, secretValues)
+		case time.Duration:
+			document[f.Name] = value.String()
+		default:
+			document[f.Name] = value
+		}
+	})
+
+	encoder := yaml.NewEncoder(writer)
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+func redactSetting(name string, value string, secretValues map[string]bool) string {
+	switch {
+	case value == "":
+		return value
+	case name == "header":
+		headerName := strings.TrimSpace(strings.SplitN(value, ":", 2)[0])
+		if secretValues[value] || sensitiveHeaders[http.CanonicalHeaderKey(headerName)] {
+			return headerName + ": " + redacted
+		}
+		return value
+	case name == "login-field":
+		return strings.SplitN(value, "=", 2)[0] + "=" + redacted
+	case secretValues[value]:
+		return redacted
+	case name == "proxy":
+		if proxyURL, err := url.Parse(value); err == nil {
+			return proxyURL.Redacted()
+		}
+		return redacted
+	default:
+		return value
+	}
+}
@@ -0,0 +1,95 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type budgetCounts struct {
+	queuedURLs         map[url.URL]bool
+	skippedURLs        map[url.URL]bool
+	pagesPerHost       map[string]int
+	pagesPerPathPrefix map[string]int
+}
+
+func newBudgetCounts() budgetCounts {
+	return budgetCounts{
+		queuedURLs:         map[url.URL]bool{},
+		skippedURLs:        map[url.URL]bool{},
+		pagesPerHost:       map[string]int{},
+		pagesPerPathPrefix: map[string]int{},
+	}
+}
+
+// applyBudgets lets through the links that fit within the maximum number of pages, pages per host
+// and pages per path prefix. A link only counts towards the budgets the first time it is queued.
+func applyBudgets(configuration Configuration, state crawlState, referrer url.URL, links []urlAtDepth) (crawlState, []urlAtDepth) {
+	newState := state
+	result := make([]urlAtDepth, 0)
+
+	for _, link := range links {
+		if newState.budgets.queuedURLs[link.URL] {
+			result = append(result, link)
+			continue
+		}
+
+		if exceededBudget, exceeded := findExceededBudget(configuration, newState.budgets, link.URL); exceeded {
+			if !newState.budgets.skippedURLs[link.URL] {
+				newState.budgets.skippedURLs[link.URL] = true
+				if newState.report.BudgetSkips == nil {
+					newState.report.BudgetSkips = map[string]int{}
+				}
+				newState.report.BudgetSkips[exceededBudget]++
+			}
+			newState.observers.notify(LinkRejected{From: referrer, URL: link.URL, Depth: link.depth, Reason: exceededBudget})
+			continue
+		}
+
+		recordQueuedURL(configuration, newState.budgets, link.URL)
+		result = append(result, link)
+	}
+
+	return newState, result
+}
+
+func findExceededBudget(configuration Configuration, budgets budgetCounts, URL url.URL) (string, bool) {
+	if 0 < configuration.MaxPages && configuration.MaxPages <= len(budgets.queuedURLs) {
+		return fmt.Sprintf("max-pages=%d", configuration.MaxPages), true
+	}
+
+	if 0 < configuration.MaxPagesPerHost && configuration.MaxPagesPerHost <= budgets.pagesPerHost[strings.ToLower(URL.Host)] {
+		return fmt.Sprintf("max-pages-per-host=%d", configuration.MaxPagesPerHost), true
+	}
+
+	for _, prefix := range sortedPathPrefixes(configuration) {
+		maxPages := configuration.MaxPagesPerPathPrefix[prefix]
+		if strings.HasPrefix(URL.Path, prefix) && 0 < maxPages && maxPages <= budgets.pagesPerPathPrefix[prefix] {
+			return fmt.Sprintf("max-pages-per-path-prefix %s=%d", prefix, maxPages), true
+		}
+	}
+
+	return "", false
+}
+
+func recordQueuedURL(configuration Configuration, budgets budgetCounts, URL url.URL) {
+	budgets.queuedURLs[URL] = true
+	budgets.pagesPerHost[strings.ToLower(URL.Host)]++
+
+	for prefix := range configuration.MaxPagesPerPathPrefix {
+		if strings.HasPrefix(URL.Path, prefix) {
+			budgets.pagesPerPathPrefix[prefix]++
+		}
+	}
+}
+
+func sortedPathPrefixes(configuration Configuration) []string {
+	result := make([]string, 0, len(configuration.MaxPagesPerPathPrefix))
+	for prefix := range configuration.MaxPagesPerPathPrefix {
+		result = append(result, prefix)
+	}
+
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,101 @@
+package crawler
+
+import (
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+	"github.com/hilverd/sitemapper/logging"
+)
+
+func TestCrawlWithBudgets(t *testing.T) {
+	stub := stubLinkExtractor{
+		urlToLinks: map[url.URL][]url.URL{
+			crawlertest.MakeURL("https://example.com/"): {
+				crawlertest.MakeURL("https://example.com/about"),
+				crawlertest.MakeURL("https://example.com/tags/a"),
+				crawlertest.MakeURL("https://example.com/tags/b"),
+				crawlertest.MakeURL("https://example.com/tags/c"),
+			},
+			crawlertest.MakeURL("https://example.com/about"): {
+				crawlertest.MakeURL("https://example.com/"),
+				crawlertest.MakeURL("https://example.com/tags/c"),
+			},
+			crawlertest.MakeURL("https://example.com/tags/a"): {},
+			crawlertest.MakeURL("https://example.com/tags/b"): {},
+			crawlertest.MakeURL("https://example.com/tags/c"): {},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		configuration   Configuration
+		wantPages       []string
+		wantBudgetSkips map[string]int
+	}{
+		{
+			name: "maximum number of pages",
+			configuration: Configuration{
+				MaxConcurrentRequests: 1,
+				MaxPages:              3,
+			},
+			wantPages: []string{
+				"https://example.com/",
+				"https://example.com/about",
+				"https://example.com/tags/a",
+			},
+			wantBudgetSkips: map[string]int{"max-pages=3": 2},
+		},
+		{
+			name: "maximum number of pages per host",
+			configuration: Configuration{
+				MaxConcurrentRequests: 1,
+				MaxPagesPerHost:       2,
+			},
+			wantPages: []string{
+				"https://example.com/",
+				"https://example.com/about",
+			},
+			wantBudgetSkips: map[string]int{"max-pages-per-host=2": 3},
+		},
+		{
+			name: "maximum number of pages per path prefix",
+			configuration: Configuration{
+				MaxConcurrentRequests: 1,
+				MaxPagesPerPathPrefix: map[string]int{"/tags/": 2},
+			},
+			wantPages: []string{
+				"https://example.com/",
+				"https://example.com/about",
+				"https://example.com/tags/a",
+				"https://example.com/tags/b",
+			},
+			wantBudgetSkips: map[string]int{"max-pages-per-path-prefix /tags/=2": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configuration := tt.configuration
+			configuration.SeedURL = crawlertest.MakeURL("https://example.com/")
+			configuration.Logger = logging.New(ioutil.Discard, logging.Text, logging.Debug)
+
+			got, gotReport := CrawlWithReport(configuration, stub)
+
+			gotPages := make([]string, 0)
+			for URL := range got {
+				gotPages = append(gotPages, URL.String())
+			}
+			sort.Strings(gotPages)
+
+			if !reflect.DeepEqual(gotPages, tt.wantPages) {
+				t.Errorf("CrawlWithReport() pages = %v, want %v", gotPages, tt.wantPages)
+			}
+			if !reflect.DeepEqual(gotReport.BudgetSkips, tt.wantBudgetSkips) {
+				t.Errorf("CrawlWithReport() budget skips = %v, want %v", gotReport.BudgetSkips, tt.wantBudgetSkips)
+			}
+		})
+	}
+}
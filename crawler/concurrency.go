@@ -0,0 +1,102 @@
+package crawler
+
+import (
+	"time"
+)
+
+const (
+	latencyTolerance        = 2.0
+	latencySmoothingFactor  = 0.2
+	unboundedMaxConcurrency = 1 << 30
+)
+
+type concurrencyLimiter struct {
+	min               int
+	max               int
+	limit             int
+	slowStart         bool
+	successesAtLimit  int
+	smoothedLatency   time.Duration
+	baselineLatency   time.Duration
+	lastDecreaseStart time.Time
+}
+
+func newConcurrencyLimiter(configuration Configuration) *concurrencyLimiter {
+	if !configuration.AdaptiveConcurrency {
+		return nil
+	}
+
+	max := configuration.MaxConcurrentRequests
+	if max <= 0 {
+		max = unboundedMaxConcurrency
+	}
+
+	min := configuration.MinConcurrentRequests
+	switch {
+	case min <= 0:
+		min = 1
+	case max < min:
+		min = max
+	}
+
+	return &concurrencyLimiter{min: min, max: max, limit: min, slowStart: true}
+}
+
+// observe adjusts the limit using additive increase / multiplicative decrease. The limit grows
+// while latency stays close to the lowest smoothed latency seen so far, and is halved when the
+// server starts to struggle. Requests that started before the last decrease are not allowed to
+// trigger another one, as they were sent at the old (higher) limit.
+func (limiter *concurrencyLimiter) observe(startedAt time.Time, latency time.Duration, overloaded bool) bool {
+	if !overloaded {
+		limiter.recordLatency(latency)
+		overloaded = float64(limiter.smoothedLatency) > latencyTolerance*float64(limiter.baselineLatency)
+	}
+
+	if overloaded {
+		if startedAt.Before(limiter.lastDecreaseStart) {
+			return false
+		}
+
+		limiter.lastDecreaseStart = time.Now()
+		limiter.slowStart = false
+		limiter.successesAtLimit = 0
+		return limiter.setLimit(limiter.limit / 2)
+	}
+
+	if limiter.slowStart {
+		return limiter.setLimit(limiter.limit + 1)
+	}
+
+	limiter.successesAtLimit++
+	if limiter.successesAtLimit >= limiter.limit {
+		limiter.successesAtLimit = 0
+		return limiter.setLimit(limiter.limit + 1)
+	}
+
+	return false
+}
+
+func (limiter *concurrencyLimiter) recordLatency(latency time.Duration) {
+	if limiter.smoothedLatency == 0 {
+		limiter.smoothedLatency = latency
+	} else {
+		limiter.smoothedLatency += time.Duration(latencySmoothingFactor * float64(latency-limiter.smoothedLatency))
+	}
+
+	if limiter.baselineLatency == 0 || limiter.smoothedLatency < limiter.baselineLatency {
+		limiter.baselineLatency = limiter.smoothedLatency
+	}
+}
+
+func (limiter *concurrencyLimiter) setLimit(limit int) bool {
+	switch {
+	case limit < limiter.min:
+		limit = limiter.min
+	case limiter.max < limit:
+		limit = limiter.max
+	}
+
+	changed := limit != limiter.limit
+	limiter.limit = limit
+	return changed
+}
@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_concurrencyLimiter_observe(t *testing.T) {
+	type observation struct {
+		latency    time.Duration
+		overloaded bool
+	}
+	tests := []struct {
+		name         string
+		limiter      concurrencyLimiter
+		observations []observation
+		want         int
+	}{
+		{
+			name:    "limit grows by one per response during slow start",
+			limiter: concurrencyLimiter{min: 1, max: 10, limit: 1, slowStart: true},
+			observations: []observation{
+				{100 * time.Millisecond, false},
+				{100 * time.Millisecond, false},
+				{100 * time.Millisecond, false},
+			},
+			want: 4,
+		},
+		{
+			name:    "limit does not grow beyond the maximum",
+			limiter: concurrencyLimiter{min: 1, max: 2, limit: 1, slowStart: true},
+			observations: []observation{
+				{100 * time.Millisecond, false},
+				{100 * time.Millisecond, false},
+				{100 * time.Millisecond, false},
+			},
+			want: 2,
+		},
+		{
+			name:    "limit is halved when the server is overloaded",
+			limiter: concurrencyLimiter{min: 1, max: 20, limit: 8, slowStart: true},
+			observations: []observation{
+				{100 * time.Millisecond, true},
+			},
+			want: 4,
+		},
+		{
+			name:    "limit does not drop below the minimum",
+			limiter: concurrencyLimiter{min: 3, max: 20, limit: 4, slowStart: true},
+			observations: []observation{
+				{100 * time.Millisecond, true},
+			},
+			want: 3,
+		},
+		{
+			name:    "limit is cut when latency rises",
+			limiter: concurrencyLimiter{min: 1, max: 20, limit: 4, slowStart: true},
+			observations: []observation{
+				{100 * time.Millisecond, false},
+				{5 * time.Second, false},
+			},
+			want: 2,
+		},
+		{
+			name:    "limit grows by one per window of responses after slow start",
+			limiter: concurrencyLimiter{min: 1, max: 20, limit: 3, slowStart: false},
+			observations: []observation{
+				{100 * time.Millisecond, false},
+				{100 * time.Millisecond, false},
+				{100 * time.Millisecond, false},
+				{100 * time.Millisecond, false},
+			},
+			want: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := tt.limiter
+			for _, observation := range tt.observations {
+				limiter.observe(time.Now(), observation.latency, observation.overloaded)
+			}
+			if limiter.limit != tt.want {
+				t.Errorf("concurrencyLimiter.limit = %v, want %v", limiter.limit, tt.want)
+			}
+		})
+	}
+}
+
+func Test_concurrencyLimiter_observe_ignoresRequestsStartedBeforeLastDecrease(t *testing.T) {
+	limiter := concurrencyLimiter{min: 1, max: 20, limit: 16, slowStart: true}
+	startedAt := time.Now()
+
+	limiter.observe(startedAt, 100*time.Millisecond, true)
+	limiter.observe(startedAt, 100*time.Millisecond, true)
+
+	if limiter.limit != 8 {
+		t.Errorf("concurrencyLimiter.limit = %v, want %v", limiter.limit, 8)
+	}
+}
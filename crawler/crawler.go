@@ -1,19 +1,36 @@
 package crawler
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"io"
+	"net/http"
 	"net/url"
+	"sort"
+	"time"
 
 	"github.com/hilverd/sitemapper/linkextractor"
+	"github.com/hilverd/sitemapper/logging"
 	"github.com/hilverd/sitemapper/sitemap"
 )
 
 type Configuration struct {
 	MaxConcurrentRequests int
+	MinConcurrentRequests int
+	AdaptiveConcurrency   bool
 	MaxDepth              int
+	MaxBytes              int64
+	MaxPages              int
+	MaxPagesPerHost       int
+	MaxPagesPerPathPrefix map[string]int
+	TrapRules             []TrapRule   // nil means DefaultTrapRules(); an empty slice disables trap detection
+	Priority              PriorityFunc // nil means BreadthFirst
+	Deterministic         bool         // crawl one depth at a time so the result does not depend on timing
+	KeepUncrawledLinks    bool         // do not remove links to pages that were not crawled from the result
+	KeepFailedPages       bool         // add pages that could not be crawled to the result, with their errors
 	SeedURL               url.URL
-	ProgressWriter        io.Writer
+	Observers             []Observer
+	Logger                *logging.Logger // if set, crawl events are logged here
 	SitemapWriter         io.Writer
 }
 
@@ -23,43 +40,88 @@ type urlAtDepth struct {
 }
 
 type crawlState struct {
-	linksToBeCrawled  []urlAtDepth
-	linksBeingCrawled map[urlAtDepth]bool
+	linksToBeCrawled  *frontier
+	linksBeingCrawled map[url.URL]int
+	dispatchedURLs    map[url.URL]bool
 	sitemap           sitemap.Sitemap
+	concurrency       *concurrencyLimiter
+	budgets           budgetCounts
+	trapRules         []TrapRule
+	trapURLs          map[url.URL]bool
+	observers         *observers
+	report            Report
+
+	resultsAtCurrentDepth []*extractionResult
 }
 
 type extractionResult struct {
-	pageURL urlAtDepth
-	urls    *[]url.URL
+	pageURL      urlAtDepth
+	page         *sitemap.Page
+	wireBytes    int64
+	decodedBytes int64
+	startedAt    time.Time
+	latency      time.Duration
+	overloaded   bool
+	retries      int
+	truncated    bool
+	contentType  string
+	statusCode   int
+	err          error
 }
 
 func Crawl(configuration Configuration, linkextractor linkextractor.LinkExtractor) sitemap.Sitemap {
-	state := initialCrawlState(configuration.SeedURL)
+	result, _ := CrawlWithReport(configuration, linkextractor)
+	return result
+}
+
+func CrawlWithReport(configuration Configuration, linkextractor linkextractor.LinkExtractor) (sitemap.Sitemap, Report) {
+	return CrawlWithContext(context.Background(), configuration, linkextractor)
+}
+
+// CrawlWithContext is like CrawlWithReport, but stops fetching pages once ctx is done. Requests in
+// flight are aborted if linkextractor is a ContextPageExtractor, and otherwise the crawl finishes as
+// soon as they have completed. It returns the pages crawled so far.
+func CrawlWithContext(ctx context.Context, configuration Configuration, linkextractor linkextractor.LinkExtractor) (sitemap.Sitemap, Report) {
+	crawlStartedAt := time.Now()
+	state := initialCrawlState(configuration)
 	extractionResults := make(chan *extractionResult, configuration.MaxConcurrentRequests)
 
-	state = extractLinksFromNextLink(configuration, linkextractor, state, extractionResults)
+	state = extractLinksFromNextLink(ctx, configuration, linkextractor, state, extractionResults)
 
 	for extractionResult := range extractionResults {
-		delete(state.linksBeingCrawled, extractionResult.pageURL)
+		delete(state.linksBeingCrawled, extractionResult.pageURL.URL)
+		state.observers.notify(newFetchFinished(extractionResult))
 
-		if extractionResult.urls != nil {
-			if page, alreadyCrawled := state.sitemap[extractionResult.pageURL.URL]; !alreadyCrawled || extractionResult.pageURL.depth < page.Depth {
-				state.sitemap[extractionResult.pageURL.URL] = sitemap.Page{Depth: extractionResult.pageURL.depth, URLs: *extractionResult.urls}
-
-				potentiallySuitableLinks := make([]urlAtDepth, 0)
+		if state.concurrency != nil {
+			if state.concurrency.observe(extractionResult.startedAt, extractionResult.latency, extractionResult.overloaded) {
+				state.observers.notify(ConcurrencyChanged{Limit: state.concurrency.limit})
+			}
+		}
 
-				for _, linkURL := range *extractionResult.urls {
-					potentiallySuitableLink := urlAtDepth{linkURL, extractionResult.pageURL.depth + 1}
-					potentiallySuitableLinks = append(potentiallySuitableLinks, potentiallySuitableLink)
-				}
+		// A request that was aborted because the crawl was cancelled says nothing about the page.
+		aborted := ctx.Err() != nil && errors.Is(extractionResult.err, ctx.Err())
 
-				suitableLinks := filterOutUnsuitableLinks(configuration, state, potentiallySuitableLinks)
-				state.linksToBeCrawled = append(state.linksToBeCrawled, suitableLinks...)
+		if configuration.Deterministic {
+			if !aborted {
+				state.resultsAtCurrentDepth = append(state.resultsAtCurrentDepth, extractionResult)
 			}
+		} else if !aborted {
+			state = processExtractionResult(configuration, state, extractionResult)
+		}
+
+		// Once the crawl is cancelled, nothing more is queued or fetched, but the pages that were
+		// fetched are still added to the sitemap.
+		if ctx.Err() != nil && !state.report.Cancelled {
+			state.report.Cancelled = true
+			clearFrontier(state)
+		}
+
+		if configuration.Deterministic && len(state.linksBeingCrawled) == 0 && state.linksToBeCrawled.len() == 0 {
+			state = processResultsAtCurrentDepth(configuration, state)
 		}
 
 		for shouldExtractLinksFromAnotherLink(configuration, state) {
-			state = extractLinksFromNextLink(configuration, linkextractor, state, extractionResults)
+			state = extractLinksFromNextLink(ctx, configuration, linkextractor, state, extractionResults)
 		}
 
 		if len(state.linksBeingCrawled) == 0 {
@@ -67,86 +129,345 @@ func Crawl(configuration Configuration, linkextractor linkextractor.LinkExtracto
 		}
 	}
 
-	return state.sitemap.FilterOutLinksThatHaveNoPage()
+	state.observers.notify(CrawlFinished{Report: state.report, Duration: time.Since(crawlStartedAt)})
+
+	if configuration.KeepUncrawledLinks {
+		return state.sitemap, state.report
+	}
+
+	return state.sitemap.FilterOutLinksThatHaveNoPage(), state.report
 }
 
-func initialCrawlState(seedURL url.URL) crawlState {
-	return crawlState{
-		linksToBeCrawled:  []urlAtDepth{{seedURL, 0}},
-		linksBeingCrawled: map[urlAtDepth]bool{},
+// processResultsAtCurrentDepth handles the results for a whole level of the crawl at once, in order
+// of URL, so that which links get queued does not depend on the order in which requests finish.
+func processResultsAtCurrentDepth(configuration Configuration, state crawlState) crawlState {
+	newState := state
+	results := newState.resultsAtCurrentDepth
+	newState.resultsAtCurrentDepth = nil
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].pageURL.URL.String() < results[j].pageURL.URL.String()
+	})
+
+	for _, result := range results {
+		newState = processExtractionResult(configuration, newState, result)
+	}
+
+	return newState
+}
+
+func processExtractionResult(configuration Configuration, state crawlState, extractionResult *extractionResult) crawlState {
+	if errors.Is(extractionResult.err, linkextractor.ErrDisallowedByRobots) {
+		newState := state
+		newState.report.RobotsDisallowed++
+		newState.observers.notify(LinkRejected{URL: extractionResult.pageURL.URL, Depth: extractionResult.pageURL.depth, Reason: ReasonRobotsTxt})
+		return newState
+	}
+
+	newState := recordFetch(configuration, state, extractionResult)
+
+	if extractionResult.page == nil {
+		if configuration.KeepFailedPages {
+			failedPage := sitemap.Page{
+				Depth:        extractionResult.pageURL.depth,
+				URLs:         []url.URL{},
+				ContentType:  extractionResult.contentType,
+				Retries:      extractionResult.retries,
+				WireBytes:    extractionResult.wireBytes,
+				DecodedBytes: extractionResult.decodedBytes,
+				StatusCode:   extractionResult.statusCode,
+				Error:        extractionResult.err.Error(),
+			}
+			newState.sitemap[extractionResult.pageURL.URL] = failedPage
+			newState.observers.notify(PageCrawled{URL: extractionResult.pageURL.URL, Page: failedPage})
+		}
+		return newState
+	}
+
+	newPage := *extractionResult.page
+	newPage.Depth = extractionResult.pageURL.depth
+	newState.sitemap[extractionResult.pageURL.URL] = newPage
+	newState.observers.notify(PageCrawled{URL: extractionResult.pageURL.URL, Page: newPage})
+
+	referrer := extractionResult.pageURL.URL
+	potentiallySuitableLinks := make([]urlAtDepth, 0)
+
+	for _, linkURL := range newPage.URLs {
+		potentiallySuitableLink := urlAtDepth{linkURL, extractionResult.pageURL.depth + 1}
+		potentiallySuitableLinks = append(potentiallySuitableLinks, potentiallySuitableLink)
+		newState.observers.notify(LinkDiscovered{From: referrer, URL: linkURL, Depth: potentiallySuitableLink.depth})
+	}
+
+	if newState.report.MaxBytesReached {
+		for _, link := range potentiallySuitableLinks {
+			newState.observers.notify(LinkRejected{From: referrer, URL: link.URL, Depth: link.depth, Reason: ReasonMaxBytes})
+		}
+		return newState
+	}
+
+	suitableLinks := filterOutUnsuitableLinks(configuration, newState, referrer, potentiallySuitableLinks)
+	newState, suitableLinks = filterOutTraps(newState, referrer, suitableLinks)
+	newState, suitableLinks = applyBudgets(configuration, newState, referrer, suitableLinks)
+	for _, link := range suitableLinks {
+		queue(newState, link)
+	}
+
+	return newState
+}
+
+func recordFetch(configuration Configuration, state crawlState, extractionResult *extractionResult) crawlState {
+	newState := state
+
+	if extractionResult.page != nil {
+		newState.report.PagesCrawled++
+	} else {
+		newState.report.PagesFailed++
+	}
+
+	newState.report.WireBytes += extractionResult.wireBytes
+	newState.report.DecodedBytes += extractionResult.decodedBytes
+
+	if 0 < configuration.MaxBytes && configuration.MaxBytes <= newState.report.WireBytes && !newState.report.MaxBytesReached {
+		newState.report.MaxBytesReached = true
+		newState.observers.notify(MaxBytesReached{WireBytes: newState.report.WireBytes})
+		clearFrontier(newState)
+	}
+
+	return newState
+}
+
+func initialCrawlState(configuration Configuration) crawlState {
+	budgets := newBudgetCounts()
+	recordQueuedURL(configuration, budgets, configuration.SeedURL)
+
+	state := crawlState{
+		linksToBeCrawled:  newFrontier(configuration),
+		linksBeingCrawled: map[url.URL]int{},
+		dispatchedURLs:    map[url.URL]bool{},
 		sitemap:           map[url.URL]sitemap.Page{},
+		concurrency:       newConcurrencyLimiter(configuration),
+		budgets:           budgets,
+		trapRules:         trapRules(configuration),
+		trapURLs:          map[url.URL]bool{},
+		observers:         newObservers(configuration),
+	}
+
+	if state.concurrency != nil {
+		state.observers.notify(ConcurrencyChanged{Limit: state.concurrency.limit})
+	}
+
+	queue(state, urlAtDepth{configuration.SeedURL, 0})
+	return state
+}
+
+func clearFrontier(state crawlState) {
+	pages := state.linksToBeCrawled.len()
+	state.linksToBeCrawled.clear()
+	state.observers.notify(FrontierCleared{Pages: pages})
+}
+
+func queue(state crawlState, link urlAtDepth) {
+	if state.report.Cancelled {
+		return
+	}
+
+	if state.linksToBeCrawled.push(link) {
+		state.observers.notify(PageQueued{URL: link.URL, Depth: link.depth})
 	}
 }
 
 func extractLinksFromNextLink(
+	ctx context.Context,
 	configuration Configuration,
 	linkextractor linkextractor.LinkExtractor,
 	state crawlState,
 	extractionResults chan *extractionResult,
 ) crawlState {
 	newState := state
-	link := newState.linksToBeCrawled[0]
-	newState.linksToBeCrawled = newState.linksToBeCrawled[1:]
-	newState.linksBeingCrawled[link] = true
+	link := newState.linksToBeCrawled.pop()
+	newState.linksBeingCrawled[link.URL] = link.depth
+	newState.dispatchedURLs[link.URL] = true
 	URL := link.URL
+	observers := newState.observers
+	observers.notify(FetchStarted{URL: URL, Depth: link.depth})
 
 	go func() {
-		if page, alreadyCrawled := state.sitemap[URL]; alreadyCrawled {
-			fmt.Fprintf(configuration.ProgressWriter, "Using cached links from %s\n", URL.String())
-			extractionResults <- &extractionResult{pageURL: link, urls: &page.URLs}
-		} else {
-			fmt.Fprintf(configuration.ProgressWriter, "Extracting links from %s\n", URL.String())
-			links, err := linkextractor.ExtractLinks(URL)
-
-			if err == nil {
-				extractionResults <- &extractionResult{pageURL: link, urls: &links}
-			} else {
-				fmt.Fprintf(configuration.ProgressWriter, "Warning: failed to extract links from %s: %s\n", URL.String(), err)
-				extractionResults <- &extractionResult{pageURL: link, urls: nil}
-			}
+		startedAt := time.Now()
+		page, err := extractPage(ctx, linkextractor, URL, notifyRetries(observers))
+		result := &extractionResult{
+			pageURL:      link,
+			wireBytes:    page.WireBytes,
+			decodedBytes: page.DecodedBytes,
+			startedAt:    startedAt,
+			latency:      time.Since(startedAt),
+			overloaded:   page.Retries > 0 || errorIndicatesOverload(err),
+			retries:      page.Retries,
+			truncated:    page.Truncated,
+			contentType:  page.ContentType,
+			statusCode:   page.StatusCode,
+			err:          err,
 		}
+
+		if err == nil {
+			result.page = newSitemapPage(page)
+		}
+
+		extractionResults <- result
 	}()
 
 	return newState
 }
 
-func filterOutUnsuitableLinks(configuration Configuration, state crawlState, links []urlAtDepth) []urlAtDepth {
+func extractPage(ctx context.Context, extractor linkextractor.LinkExtractor, URL url.URL, onRetry func(linkextractor.RetryAttempt)) (linkextractor.Page, error) {
+	if contextPageExtractor, ok := extractor.(linkextractor.ContextPageExtractor); ok {
+		return contextPageExtractor.ExtractPageWithContext(ctx, URL, onRetry)
+	}
+
+	if retryObservingPageExtractor, ok := extractor.(linkextractor.RetryObservingPageExtractor); ok {
+		return retryObservingPageExtractor.ExtractPageObservingRetries(URL, onRetry)
+	}
+
+	if pageExtractor, ok := extractor.(linkextractor.PageExtractor); ok {
+		return pageExtractor.ExtractPage(URL)
+	}
+
+	links, err := extractor.ExtractLinks(URL)
+	return linkextractor.Page{Links: links, StatusCode: statusCodeWithoutResponse(err)}, err
+}
+
+func notifyRetries(observers *observers) func(linkextractor.RetryAttempt) {
+	return func(attempt linkextractor.RetryAttempt) {
+		observers.notify(Retry{URL: attempt.URL, Retries: attempt.Retries, Delay: attempt.Delay, Err: attempt.Err})
+	}
+}
+
+func newFetchFinished(extractionResult *extractionResult) FetchFinished {
+	result := FetchFinished{
+		URL:          extractionResult.pageURL.URL,
+		Depth:        extractionResult.pageURL.depth,
+		StatusCode:   extractionResult.statusCode,
+		Err:          extractionResult.err,
+		Duration:     extractionResult.latency,
+		Retries:      extractionResult.retries,
+		Truncated:    extractionResult.truncated,
+		WireBytes:    extractionResult.wireBytes,
+		DecodedBytes: extractionResult.decodedBytes,
+	}
+
+	if extractionResult.page != nil {
+		result.Links = len(extractionResult.page.URLs)
+	}
+
+	return result
+}
+
+// statusCodeWithoutResponse works out the HTTP status of a response for link extractors that
+// cannot report it themselves.
+func statusCodeWithoutResponse(err error) int {
+	var statusError *linkextractor.StatusError
+
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.As(err, &statusError):
+		return statusError.StatusCode
+	default:
+		return 0
+	}
+}
+
+func newSitemapPage(page linkextractor.Page) *sitemap.Page {
+	return &sitemap.Page{
+		URLs:         page.Links,
+		LinkDetails:  newLinkDetails(page.LinkDetails),
+		Title:        page.Title,
+		ContentType:  page.ContentType,
+		Retries:      page.Retries,
+		Charset:      page.Charset,
+		Truncated:    page.Truncated,
+		WireBytes:    page.WireBytes,
+		DecodedBytes: page.DecodedBytes,
+	}
+}
+
+func newLinkDetails(details []linkextractor.LinkDetails) []sitemap.LinkDetails {
+	if len(details) == 0 {
+		return nil
+	}
+
+	result := make([]sitemap.LinkDetails, 0, len(details))
+	for _, linkDetails := range details {
+		result = append(result, sitemap.LinkDetails(linkDetails))
+	}
+
+	return result
+}
+
+func errorIndicatesOverload(err error) bool {
+	var statusError *linkextractor.StatusError
+	if !errors.As(err, &statusError) {
+		return false
+	}
+
+	return statusError.StatusCode == http.StatusTooManyRequests || statusError.StatusCode == http.StatusServiceUnavailable
+}
+
+func filterOutUnsuitableLinks(configuration Configuration, state crawlState, referrer url.URL, links []urlAtDepth) []urlAtDepth {
 	result := make([]urlAtDepth, 0)
 	for _, link := range links {
-		if linkIsSuitable(configuration, state, link) {
+		suitable, reason := linkIsSuitable(configuration, state, link)
+		switch {
+		case suitable:
 			result = append(result, link)
+		case reason != "":
+			state.observers.notify(LinkRejected{From: referrer, URL: link.URL, Depth: link.depth, Reason: reason})
 		}
 	}
 
 	return result
 }
 
-func linkIsSuitable(configuration Configuration, state crawlState, link urlAtDepth) bool {
-	if page, alreadyCrawled := state.sitemap[link.URL]; alreadyCrawled && page.Depth <= link.depth {
-		return false
-	}
-
+// linkIsSuitable also gives the reason for rejecting a link, except for links that have been
+// crawled already.
+func linkIsSuitable(configuration Configuration, state crawlState, link urlAtDepth) (bool, string) {
 	switch {
+	case state.dispatchedURLs[link.URL]:
+		return false, ""
 	case 0 < configuration.MaxDepth && configuration.MaxDepth < link.depth:
-		return false
-	case state.linksBeingCrawled[link]:
-		return false
+		return false, ReasonMaxDepth
 	case link.URL.Host != configuration.SeedURL.Host:
-		return false
+		return false, ReasonOtherHost
 	case link.URL.Scheme != "http" && link.URL.Scheme != "https":
-		return false
+		return false, ReasonUnsupportedScheme
 	default:
-		return true
+		return true, ""
 	}
 }
 
 func shouldExtractLinksFromAnotherLink(configuration Configuration, state crawlState) bool {
 	switch {
-	case len(state.linksToBeCrawled) == 0:
+	case state.report.Cancelled || state.linksToBeCrawled.len() == 0:
 		return false
-	case 0 < configuration.MaxConcurrentRequests && configuration.MaxConcurrentRequests <= len(state.linksBeingCrawled):
+	case !linksAtDepthCanBeCrawled(state, state.linksToBeCrawled.peek().depth):
+		return false
+	case state.concurrency != nil && state.concurrency.limit <= len(state.linksBeingCrawled):
+		return false
+	case state.concurrency == nil && 0 < configuration.MaxConcurrentRequests && configuration.MaxConcurrentRequests <= len(state.linksBeingCrawled):
 		return false
 	default:
 		return true
 	}
 }
+
+// linksAtDepthCanBeCrawled reports whether no page that is still being crawled could turn up a
+// shorter path to a link at the given depth. Waiting for that means each page is fetched once, at
+// the depth of its shortest path from the seed URL.
+func linksAtDepthCanBeCrawled(state crawlState, depth int) bool {
+	for _, depthBeingCrawled := range state.linksBeingCrawled {
+		if depthBeingCrawled < depth-1 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -1,15 +1,19 @@
 package crawler
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net/url"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/hilverd/sitemapper/crawlertest"
 	"github.com/hilverd/sitemapper/linkextractor"
+	"github.com/hilverd/sitemapper/logging"
 	"github.com/hilverd/sitemapper/sitemap"
 )
 
@@ -58,8 +62,43 @@ func TestCrawl(t *testing.T) {
 			name: "happy path",
 			args: args{
 				configuration: Configuration{
-					SeedURL:        crawlertest.MakeURL("https://example.com/"),
-					ProgressWriter: ioutil.Discard,
+					SeedURL: crawlertest.MakeURL("https://example.com/"),
+					Logger:  logging.New(ioutil.Discard, logging.Text, logging.Debug),
+				},
+				linkextractor: stub,
+			},
+			want: map[url.URL]sitemap.Page{
+				crawlertest.MakeURL("https://example.com/"): {
+					Depth: 0,
+					URLs: []url.URL{
+						crawlertest.MakeURL("https://example.com/one"),
+						crawlertest.MakeURL("https://example.com/two"),
+					},
+				},
+				crawlertest.MakeURL("https://example.com/one"): {
+					Depth: 1,
+					URLs:  []url.URL{},
+				},
+				crawlertest.MakeURL("https://example.com/two"): {
+					Depth: 1,
+					URLs: []url.URL{
+						crawlertest.MakeURL("https://example.com/three"),
+					},
+				},
+				crawlertest.MakeURL("https://example.com/three"): {
+					Depth: 2,
+					URLs:  []url.URL{},
+				},
+			},
+		},
+		{
+			name: "happy path with adaptive concurrency",
+			args: args{
+				configuration: Configuration{
+					MaxConcurrentRequests: 4,
+					AdaptiveConcurrency:   true,
+					SeedURL:               crawlertest.MakeURL("https://example.com/"),
+					Logger:                logging.New(ioutil.Discard, logging.Text, logging.Debug),
 				},
 				linkextractor: stub,
 			},
@@ -174,9 +213,9 @@ func TestCrawlWithMaxDepth(t *testing.T) {
 			name: "links beyond the maximum depth are not crawled",
 			args: args{
 				configuration: Configuration{
-					MaxDepth:       4,
-					SeedURL:        crawlertest.MakeURL("https://example.com/seed"),
-					ProgressWriter: ioutil.Discard,
+					MaxDepth: 4,
+					SeedURL:  crawlertest.MakeURL("https://example.com/seed"),
+					Logger:   logging.New(ioutil.Discard, logging.Text, logging.Debug),
 				},
 				linkextractor: &specialCaseLinkExtractor{},
 			},
@@ -233,3 +272,681 @@ func TestCrawlWithMaxDepth(t *testing.T) {
 		})
 	}
 }
+
+type flakyPageExtractor struct{}
+
+func (stub flakyPageExtractor) ExtractLinks(URL url.URL) ([]url.URL, error) {
+	page, err := stub.ExtractPage(URL)
+	return page.Links, err
+}
+
+func (stub flakyPageExtractor) ExtractPage(URL url.URL) (linkextractor.Page, error) {
+	switch URL.String() {
+	case "https://example.com/":
+		return linkextractor.Page{
+			Links:   []url.URL{crawlertest.MakeURL("https://example.com/flaky")},
+			Retries: 0,
+		}, nil
+	case "https://example.com/flaky":
+		return linkextractor.Page{Links: []url.URL{}, Retries: 3}, nil
+	default:
+		return linkextractor.Page{Links: []url.URL{}, Retries: 1}, fmt.Errorf("No links defined for %s", URL.String())
+	}
+}
+
+func TestCrawlRecordsRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		configuration Configuration
+		want          sitemap.Sitemap
+	}{
+		{
+			name: "number of retries is recorded for each page",
+			configuration: Configuration{
+				SeedURL: crawlertest.MakeURL("https://example.com/"),
+				Logger:  logging.New(ioutil.Discard, logging.Text, logging.Debug),
+			},
+			want: map[url.URL]sitemap.Page{
+				crawlertest.MakeURL("https://example.com/"): {
+					Depth:   0,
+					URLs:    []url.URL{crawlertest.MakeURL("https://example.com/flaky")},
+					Retries: 0,
+				},
+				crawlertest.MakeURL("https://example.com/flaky"): {
+					Depth:   1,
+					URLs:    []url.URL{},
+					Retries: 3,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Crawl(tt.configuration, flakyPageExtractor{}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Crawl() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type fixedSizePageExtractor struct {
+	urlToLinks map[url.URL][]url.URL
+}
+
+func (stub fixedSizePageExtractor) ExtractLinks(URL url.URL) ([]url.URL, error) {
+	page, err := stub.ExtractPage(URL)
+	return page.Links, err
+}
+
+func (stub fixedSizePageExtractor) ExtractPage(URL url.URL) (linkextractor.Page, error) {
+	return linkextractor.Page{Links: stub.urlToLinks[URL], WireBytes: 100, DecodedBytes: 400}, nil
+}
+
+func TestCrawlWithReport(t *testing.T) {
+	stub := fixedSizePageExtractor{
+		urlToLinks: map[url.URL][]url.URL{
+			crawlertest.MakeURL("https://example.com/"): {
+				crawlertest.MakeURL("https://example.com/a"),
+				crawlertest.MakeURL("https://example.com/b"),
+				crawlertest.MakeURL("https://example.com/c"),
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		configuration Configuration
+		wantPages     int
+		wantReport    Report
+	}{
+		{
+			name: "bytes are counted",
+			configuration: Configuration{
+				MaxConcurrentRequests: 1,
+				SeedURL:               crawlertest.MakeURL("https://example.com/"),
+				Logger:                logging.New(ioutil.Discard, logging.Text, logging.Debug),
+			},
+			wantPages:  4,
+			wantReport: Report{PagesCrawled: 4, WireBytes: 400, DecodedBytes: 1600},
+		},
+		{
+			name: "no pages are queued once the maximum number of bytes is reached",
+			configuration: Configuration{
+				MaxConcurrentRequests: 1,
+				MaxBytes:              150,
+				SeedURL:               crawlertest.MakeURL("https://example.com/"),
+				Logger:                logging.New(ioutil.Discard, logging.Text, logging.Debug),
+			},
+			wantPages:  2,
+			wantReport: Report{PagesCrawled: 2, WireBytes: 200, DecodedBytes: 800, MaxBytesReached: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotReport := CrawlWithReport(tt.configuration, stub)
+			if len(got) != tt.wantPages {
+				t.Errorf("CrawlWithReport() returned %v pages, want %v", len(got), tt.wantPages)
+			}
+			if !reflect.DeepEqual(gotReport, tt.wantReport) {
+				t.Errorf("CrawlWithReport() report = %+v, want %+v", gotReport, tt.wantReport)
+			}
+		})
+	}
+}
+
+type countingLinkExtractor struct {
+	stub       stubLinkExtractor
+	slowURL    url.URL
+	mutex      sync.Mutex
+	fetchCount map[url.URL]int
+}
+
+func (extractor *countingLinkExtractor) ExtractLinks(URL url.URL) ([]url.URL, error) {
+	extractor.mutex.Lock()
+	extractor.fetchCount[URL]++
+	extractor.mutex.Unlock()
+
+	if URL == extractor.slowURL {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return extractor.stub.ExtractLinks(URL)
+}
+
+func TestCrawlFetchesEachPageOnceAtItsShortestDepth(t *testing.T) {
+	/*
+		seed
+		 | \
+		 A  B (slow)
+		 |   \
+		 C -> D -> E
+
+		D is first found through C, but B offers a shorter path to it.
+	*/
+	stub := stubLinkExtractor{
+		urlToLinks: map[url.URL][]url.URL{
+			crawlertest.MakeURL("https://example.com/"): {
+				crawlertest.MakeURL("https://example.com/a"),
+				crawlertest.MakeURL("https://example.com/b"),
+			},
+			crawlertest.MakeURL("https://example.com/a"): {crawlertest.MakeURL("https://example.com/c")},
+			crawlertest.MakeURL("https://example.com/b"): {crawlertest.MakeURL("https://example.com/d")},
+			crawlertest.MakeURL("https://example.com/c"): {crawlertest.MakeURL("https://example.com/d")},
+			crawlertest.MakeURL("https://example.com/d"): {crawlertest.MakeURL("https://example.com/e")},
+			crawlertest.MakeURL("https://example.com/e"): {},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		priority   PriorityFunc
+		wantDepths map[string]int
+	}{
+		{
+			name: "breadth first",
+			wantDepths: map[string]int{
+				"https://example.com/":  0,
+				"https://example.com/a": 1,
+				"https://example.com/b": 1,
+				"https://example.com/c": 2,
+				"https://example.com/d": 2,
+				"https://example.com/e": 3,
+			},
+		},
+		{
+			name:     "shortest path first",
+			priority: ShortestPathFirst,
+			wantDepths: map[string]int{
+				"https://example.com/":  0,
+				"https://example.com/a": 1,
+				"https://example.com/b": 1,
+				"https://example.com/c": 2,
+				"https://example.com/d": 2,
+				"https://example.com/e": 3,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extractor := &countingLinkExtractor{
+				stub:       stub,
+				slowURL:    crawlertest.MakeURL("https://example.com/b"),
+				fetchCount: map[url.URL]int{},
+			}
+			configuration := Configuration{
+				MaxConcurrentRequests: 10,
+				Priority:              tt.priority,
+				SeedURL:               crawlertest.MakeURL("https://example.com/"),
+				Logger:                logging.New(ioutil.Discard, logging.Text, logging.Debug),
+			}
+
+			got := Crawl(configuration, extractor)
+
+			gotDepths := map[string]int{}
+			for URL, page := range got {
+				gotDepths[URL.String()] = page.Depth
+			}
+			if !reflect.DeepEqual(gotDepths, tt.wantDepths) {
+				t.Errorf("Crawl() depths = %v, want %v", gotDepths, tt.wantDepths)
+			}
+			for URL, count := range extractor.fetchCount {
+				if count != 1 {
+					t.Errorf("Crawl() fetched %s %d times, want 1", URL.String(), count)
+				}
+			}
+		})
+	}
+}
+
+type jitteryLinkExtractor struct {
+	stub stubLinkExtractor
+}
+
+func (extractor jitteryLinkExtractor) ExtractLinks(URL url.URL) ([]url.URL, error) {
+	time.Sleep(time.Duration(rand.Intn(2000)) * time.Microsecond)
+	return extractor.stub.ExtractLinks(URL)
+}
+
+func TestCrawlIsDeterministic(t *testing.T) {
+	const numberOfPages = 40
+
+	urlToLinks := map[url.URL][]url.URL{}
+	for i := 0; i < numberOfPages; i++ {
+		pageURL := crawlertest.MakeURL(fmt.Sprintf("https://example.com/%d", i))
+		urlToLinks[pageURL] = []url.URL{
+			crawlertest.MakeURL(fmt.Sprintf("https://example.com/%d", (i*7+3)%numberOfPages)),
+			crawlertest.MakeURL(fmt.Sprintf("https://example.com/%d", (i*11+5)%numberOfPages)),
+			crawlertest.MakeURL(fmt.Sprintf("https://example.com/tags/%d", i%5)),
+		}
+	}
+	for i := 0; i < 5; i++ {
+		urlToLinks[crawlertest.MakeURL(fmt.Sprintf("https://example.com/tags/%d", i))] = []url.URL{
+			crawlertest.MakeURL(fmt.Sprintf("https://example.com/%d", i*3+1)),
+		}
+	}
+	extractor := jitteryLinkExtractor{stub: stubLinkExtractor{urlToLinks: urlToLinks}}
+
+	tests := []struct {
+		name          string
+		configuration Configuration
+	}{
+		{
+			name: "maximum depth",
+			configuration: Configuration{
+				MaxDepth: 3,
+			},
+		},
+		{
+			name: "maximum number of pages",
+			configuration: Configuration{
+				MaxPages: 17,
+			},
+		},
+		{
+			name: "maximum number of pages per path prefix",
+			configuration: Configuration{
+				MaxDepth:              4,
+				MaxPagesPerPathPrefix: map[string]int{"/tags/": 2},
+			},
+		},
+		{
+			name: "shortest path first",
+			configuration: Configuration{
+				MaxPages: 25,
+				Priority: ShortestPathFirst,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configuration := tt.configuration
+			configuration.MaxConcurrentRequests = 32
+			configuration.Deterministic = true
+			configuration.SeedURL = crawlertest.MakeURL("https://example.com/0")
+			configuration.Logger = logging.New(ioutil.Discard, logging.Text, logging.Debug)
+
+			want, wantReport := CrawlWithReport(configuration, extractor)
+
+			for run := 1; run < 50; run++ {
+				got, gotReport := CrawlWithReport(configuration, extractor)
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("CrawlWithReport() run %d = %v, want %v", run, got, want)
+				}
+				if !reflect.DeepEqual(gotReport, wantReport) {
+					t.Fatalf("CrawlWithReport() run %d report = %+v, want %+v", run, gotReport, wantReport)
+				}
+			}
+		})
+	}
+}
+
+func TestCrawlStreamsPages(t *testing.T) {
+	stub := stubLinkExtractor{
+		urlToLinks: map[url.URL][]url.URL{
+			crawlertest.MakeURL("https://example.com/"): {
+				crawlertest.MakeURL("https://example.com/a"),
+			},
+			crawlertest.MakeURL("https://example.com/a"): {
+				crawlertest.MakeURL("https://example.com/a/b"),
+			},
+		},
+	}
+
+	tests := []struct {
+		name               string
+		keepUncrawledLinks bool
+		want               sitemap.Sitemap
+	}{
+		{
+			name: "links to pages that were not crawled are removed from the result",
+			want: map[url.URL]sitemap.Page{
+				crawlertest.MakeURL("https://example.com/"): {
+					Depth: 0,
+					URLs:  []url.URL{crawlertest.MakeURL("https://example.com/a")},
+				},
+				crawlertest.MakeURL("https://example.com/a"): {
+					Depth: 1,
+					URLs:  []url.URL{},
+				},
+			},
+		},
+		{
+			name:               "links to pages that were not crawled are kept",
+			keepUncrawledLinks: true,
+			want: map[url.URL]sitemap.Page{
+				crawlertest.MakeURL("https://example.com/"): {
+					Depth: 0,
+					URLs:  []url.URL{crawlertest.MakeURL("https://example.com/a")},
+				},
+				crawlertest.MakeURL("https://example.com/a"): {
+					Depth: 1,
+					URLs:  []url.URL{crawlertest.MakeURL("https://example.com/a/b")},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streamed := sitemap.Sitemap{}
+			configuration := Configuration{
+				MaxConcurrentRequests: 1,
+				MaxDepth:              1,
+				KeepUncrawledLinks:    tt.keepUncrawledLinks,
+				SeedURL:               crawlertest.MakeURL("https://example.com/"),
+				Observers: []Observer{OnPageCrawled(func(URL url.URL, page sitemap.Page) {
+					streamed[URL] = page
+				})},
+			}
+
+			got := Crawl(configuration, stub)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Crawl() = %v, want %v", got, tt.want)
+			}
+			if len(streamed) != len(got) || !reflect.DeepEqual(streamed[crawlertest.MakeURL("https://example.com/a")].URLs, stub.urlToLinks[crawlertest.MakeURL("https://example.com/a")]) {
+				t.Errorf("Crawl() streamed %v", streamed)
+			}
+		})
+	}
+}
+
+type notFoundLinkExtractor struct {
+	stub stubLinkExtractor
+}
+
+func (extractor notFoundLinkExtractor) ExtractLinks(URL url.URL) ([]url.URL, error) {
+	if links, ok := extractor.stub.urlToLinks[URL]; ok {
+		return links, nil
+	}
+
+	return []url.URL{}, &linkextractor.StatusError{StatusCode: 404, Status: "404 Not Found"}
+}
+
+func TestCrawlKeepsFailedPages(t *testing.T) {
+	extractor := notFoundLinkExtractor{
+		stub: stubLinkExtractor{
+			urlToLinks: map[url.URL][]url.URL{
+				crawlertest.MakeURL("https://example.com/"): {
+					crawlertest.MakeURL("https://example.com/missing"),
+				},
+			},
+		},
+	}
+
+	configuration := Configuration{
+		MaxConcurrentRequests: 1,
+		KeepFailedPages:       true,
+		SeedURL:               crawlertest.MakeURL("https://example.com/"),
+	}
+
+	want := sitemap.Sitemap{
+		crawlertest.MakeURL("https://example.com/"): {
+			Depth: 0,
+			URLs:  []url.URL{crawlertest.MakeURL("https://example.com/missing")},
+		},
+		crawlertest.MakeURL("https://example.com/missing"): {
+			Depth:      1,
+			URLs:       []url.URL{},
+			StatusCode: 404,
+			Error:      "Got a 404 Not Found response",
+		},
+	}
+
+	if got := Crawl(configuration, extractor); !reflect.DeepEqual(got, want) {
+		t.Errorf("Crawl() = %v, want %v", got, want)
+	}
+}
+
+type failingPageExtractor struct{}
+
+func (stub failingPageExtractor) ExtractLinks(URL url.URL) ([]url.URL, error) {
+	page, err := stub.ExtractPage(URL)
+	return page.Links, err
+}
+
+func (stub failingPageExtractor) ExtractPage(URL url.URL) (linkextractor.Page, error) {
+	switch URL.Path {
+	case "/":
+		return linkextractor.Page{
+			Links: []url.URL{
+				crawlertest.MakeURL("https://example.com/image.png"),
+				crawlertest.MakeURL("https://example.com/corrupt"),
+				crawlertest.MakeURL("https://example.com/unreachable"),
+			},
+			StatusCode: 200,
+		}, nil
+	case "/image.png":
+		return linkextractor.Page{StatusCode: 200}, fmt.Errorf("%w: image/png", linkextractor.ErrUnsupportedContentType)
+	case "/corrupt":
+		return linkextractor.Page{StatusCode: 200}, fmt.Errorf("gzip: invalid header")
+	default:
+		return linkextractor.Page{}, fmt.Errorf("GET request failed: connection refused")
+	}
+}
+
+func TestCrawlRecordsStatusCodesOfFailedPages(t *testing.T) {
+	configuration := Configuration{
+		MaxConcurrentRequests: 1,
+		KeepFailedPages:       true,
+		SeedURL:               crawlertest.MakeURL("https://example.com/"),
+	}
+
+	tests := []struct {
+		url            string
+		wantStatusCode int
+		wantBroken     bool
+	}{
+		{url: "https://example.com/image.png", wantStatusCode: 200, wantBroken: false},
+		{url: "https://example.com/corrupt", wantStatusCode: 200, wantBroken: true},
+		{url: "https://example.com/unreachable", wantStatusCode: 0, wantBroken: true},
+	}
+
+	got := Crawl(configuration, failingPageExtractor{})
+	for _, tt := range tests {
+		page := got[crawlertest.MakeURL(tt.url)]
+		if page.StatusCode != tt.wantStatusCode || page.Broken() != tt.wantBroken {
+			t.Errorf("Crawl() gave %s status %v and broken %v, want %v and %v", tt.url, page.StatusCode, page.Broken(), tt.wantStatusCode, tt.wantBroken)
+		}
+	}
+}
+
+type robotsPageExtractor struct{}
+
+func (stub robotsPageExtractor) ExtractLinks(URL url.URL) ([]url.URL, error) {
+	page, err := stub.ExtractPage(URL)
+	return page.Links, err
+}
+
+func (stub robotsPageExtractor) ExtractPage(URL url.URL) (linkextractor.Page, error) {
+	if URL.Path == "/private/" {
+		return linkextractor.Page{}, linkextractor.ErrDisallowedByRobots
+	}
+
+	return linkextractor.Page{
+		Links: []url.URL{
+			crawlertest.MakeURL("https://example.com/public/"),
+			crawlertest.MakeURL("https://example.com/private/"),
+		},
+		StatusCode: 200,
+	}, nil
+}
+
+func TestCrawlSkipsPagesThatRobotsDisallows(t *testing.T) {
+	for _, deterministic := range []bool{false, true} {
+		var rejected []LinkRejected
+		configuration := Configuration{
+			MaxConcurrentRequests: 2,
+			KeepFailedPages:       true,
+			Deterministic:         deterministic,
+			SeedURL:               crawlertest.MakeURL("https://example.com/"),
+			Observers: []Observer{ObserverFunc(func(event Event) {
+				if event, ok := event.(LinkRejected); ok {
+					rejected = append(rejected, event)
+				}
+			})},
+		}
+
+		got, gotReport := CrawlWithReport(configuration, robotsPageExtractor{})
+		if _, ok := got[crawlertest.MakeURL("https://example.com/private/")]; ok || len(got) != 2 {
+			t.Errorf("CrawlWithReport() with deterministic %v returned %v, want / and /public/ only", deterministic, got)
+		}
+		if gotReport.RobotsDisallowed != 1 || gotReport.PagesFailed != 0 {
+			t.Errorf("CrawlWithReport() with deterministic %v report = %+v, want 1 page disallowed and none failed", deterministic, gotReport)
+		}
+
+		want := []LinkRejected{{URL: crawlertest.MakeURL("https://example.com/private/"), Depth: 1, Reason: ReasonRobotsTxt}}
+		if !reflect.DeepEqual(rejected, want) {
+			t.Errorf("CrawlWithReport() with deterministic %v rejected %v, want %v", deterministic, rejected, want)
+		}
+	}
+}
+
+func TestCrawlWithContextStopsWhenCancelled(t *testing.T) {
+	stub := stubLinkExtractor{
+		urlToLinks: map[url.URL][]url.URL{
+			crawlertest.MakeURL("https://example.com/"): {
+				crawlertest.MakeURL("https://example.com/one"),
+				crawlertest.MakeURL("https://example.com/two"),
+			},
+			crawlertest.MakeURL("https://example.com/one"): {},
+			crawlertest.MakeURL("https://example.com/two"): {},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var cleared []FrontierCleared
+	configuration := Configuration{
+		MaxConcurrentRequests: 1,
+		MaxDepth:              2,
+		SeedURL:               crawlertest.MakeURL("https://example.com/"),
+		Observers: []Observer{
+			OnPageCrawled(func(url.URL, sitemap.Page) { cancel() }),
+			ObserverFunc(func(event Event) {
+				if event, ok := event.(FrontierCleared); ok {
+					cleared = append(cleared, event)
+				}
+			}),
+		},
+	}
+
+	got, gotReport := CrawlWithContext(ctx, configuration, stub)
+
+	want := sitemap.Sitemap{crawlertest.MakeURL("https://example.com/"): {Depth: 0, URLs: []url.URL{}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CrawlWithContext() = %v, want %v", got, want)
+	}
+	if !gotReport.Cancelled {
+		t.Errorf("CrawlWithContext() report is not marked as cancelled")
+	}
+	if want := []FrontierCleared{{Pages: 2}}; !reflect.DeepEqual(cleared, want) {
+		t.Errorf("CrawlWithContext() notified %v, want %v", cleared, want)
+	}
+}
+
+// cancellingLinkExtractor cancels the crawl while fetching /one, and keeps /two in flight until
+// then. It does not know about contexts, so both requests run to completion.
+type cancellingLinkExtractor struct {
+	cancel    context.CancelFunc
+	mutex     *sync.Mutex
+	fetched   map[url.URL]bool
+	cancelled chan struct{}
+}
+
+func (extractor cancellingLinkExtractor) ExtractLinks(URL url.URL) ([]url.URL, error) {
+	extractor.mutex.Lock()
+	extractor.fetched[URL] = true
+	extractor.mutex.Unlock()
+
+	switch URL.Path {
+	case "/":
+		return []url.URL{crawlertest.MakeURL("https://example.com/one"), crawlertest.MakeURL("https://example.com/two")}, nil
+	case "/one":
+		extractor.cancel()
+		close(extractor.cancelled)
+		return []url.URL{crawlertest.MakeURL("https://example.com/three")}, nil
+	case "/two":
+		<-extractor.cancelled
+		return []url.URL{crawlertest.MakeURL("https://example.com/four")}, nil
+	default:
+		return []url.URL{}, nil
+	}
+}
+
+func TestCrawlWithContextDispatchesNothingOnceCancelled(t *testing.T) {
+	for _, deterministic := range []bool{false, true} {
+		ctx, cancel := context.WithCancel(context.Background())
+		extractor := cancellingLinkExtractor{cancel: cancel, mutex: &sync.Mutex{}, fetched: map[url.URL]bool{}, cancelled: make(chan struct{})}
+		configuration := Configuration{
+			MaxConcurrentRequests: 2,
+			Deterministic:         deterministic,
+			SeedURL:               crawlertest.MakeURL("https://example.com/"),
+		}
+
+		got, gotReport := CrawlWithContext(ctx, configuration, extractor)
+		cancel()
+
+		wantFetched := map[url.URL]bool{
+			crawlertest.MakeURL("https://example.com/"):    true,
+			crawlertest.MakeURL("https://example.com/one"): true,
+			crawlertest.MakeURL("https://example.com/two"): true,
+		}
+		if !reflect.DeepEqual(extractor.fetched, wantFetched) {
+			t.Errorf("CrawlWithContext() with deterministic %v fetched %v, want %v", deterministic, extractor.fetched, wantFetched)
+		}
+		if len(got) != 3 || !gotReport.Cancelled {
+			t.Errorf("CrawlWithContext() with deterministic %v = %v with cancelled %v, want the 3 pages fetched and cancelled", deterministic, got, gotReport.Cancelled)
+		}
+	}
+}
+
+type slowPageExtractor struct{}
+
+func (stub slowPageExtractor) ExtractLinks(URL url.URL) ([]url.URL, error) {
+	page, err := stub.ExtractPageWithContext(context.Background(), URL, nil)
+	return page.Links, err
+}
+
+func (stub slowPageExtractor) ExtractPageWithContext(ctx context.Context, URL url.URL, onRetry func(linkextractor.RetryAttempt)) (linkextractor.Page, error) {
+	if URL.Path == "/" {
+		return linkextractor.Page{Links: []url.URL{crawlertest.MakeURL("https://example.com/slow")}, StatusCode: 200}, nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return linkextractor.Page{}, fmt.Errorf("GET request failed: %w", ctx.Err())
+	case <-time.After(time.Minute):
+		return linkextractor.Page{StatusCode: 200}, nil
+	}
+}
+
+func TestCrawlWithContextAbortsRequestsInFlight(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	configuration := Configuration{
+		MaxConcurrentRequests: 1,
+		KeepFailedPages:       true,
+		SeedURL:               crawlertest.MakeURL("https://example.com/"),
+		Observers: []Observer{ObserverFunc(func(event Event) {
+			if fetchStarted, ok := event.(FetchStarted); ok && fetchStarted.Depth > 0 {
+				cancel()
+			}
+		})},
+	}
+
+	startedAt := time.Now()
+	got, gotReport := CrawlWithContext(ctx, configuration, slowPageExtractor{})
+
+	want := sitemap.Sitemap{crawlertest.MakeURL("https://example.com/"): {Depth: 0, URLs: []url.URL{}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CrawlWithContext() = %v, want %v", got, want)
+	}
+	if !gotReport.Cancelled {
+		t.Errorf("CrawlWithContext() report is not marked as cancelled")
+	}
+	if elapsed := time.Since(startedAt); elapsed > 10*time.Second {
+		t.Errorf("CrawlWithContext() took %v to stop", elapsed)
+	}
+}
@@ -0,0 +1,150 @@
+package crawler
+
+import (
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/hilverd/sitemapper/sitemap"
+)
+
+// An Event is one of PageQueued, FetchStarted, FetchFinished, PageCrawled, LinkDiscovered,
+// LinkRejected, Retry, ConcurrencyChanged, MaxBytesReached, FrontierCleared and CrawlFinished.
+type Event interface {
+	event()
+}
+
+type PageQueued struct {
+	URL   url.URL
+	Depth int
+}
+
+type FetchStarted struct {
+	URL   url.URL
+	Depth int
+}
+
+type FetchFinished struct {
+	URL          url.URL
+	Depth        int
+	StatusCode   int // zero if no response was received
+	Err          error
+	Duration     time.Duration
+	Retries      int
+	Links        int
+	Truncated    bool
+	WireBytes    int64
+	DecodedBytes int64
+}
+
+// PageCrawled carries a page as it is added to the sitemap. Its links have not been filtered yet, so
+// they can include pages that are never crawled.
+type PageCrawled struct {
+	URL  url.URL
+	Page sitemap.Page
+}
+
+type LinkDiscovered struct {
+	From  url.URL
+	URL   url.URL
+	Depth int
+}
+
+// Reasons for rejecting links, other than the names of trap rules and budgets.
+const (
+	ReasonMaxDepth          = "max-depth"
+	ReasonOtherHost         = "other-host"
+	ReasonUnsupportedScheme = "unsupported-scheme"
+	ReasonMaxBytes          = "max-bytes"
+	ReasonRobotsTxt         = "robots-txt"
+)
+
+type LinkRejected struct {
+	From   url.URL
+	URL    url.URL
+	Depth  int
+	Reason string
+	Trap   bool
+}
+
+type Retry struct {
+	URL     url.URL
+	Retries int
+	Delay   time.Duration
+	Err     error
+}
+
+type ConcurrencyChanged struct {
+	Limit int
+}
+
+type MaxBytesReached struct {
+	WireBytes int64
+}
+
+// FrontierCleared means that the pages that were queued will not be fetched after all, because the
+// crawl was cancelled or the maximum number of bytes was reached.
+type FrontierCleared struct {
+	Pages int // number of queued pages that were dropped
+}
+
+type CrawlFinished struct {
+	Report   Report
+	Duration time.Duration
+}
+
+func (PageQueued) event()         {}
+func (FetchStarted) event()       {}
+func (FetchFinished) event()      {}
+func (PageCrawled) event()        {}
+func (LinkDiscovered) event()     {}
+func (LinkRejected) event()       {}
+func (Retry) event()              {}
+func (ConcurrencyChanged) event() {}
+func (MaxBytesReached) event()    {}
+func (FrontierCleared) event()    {}
+func (CrawlFinished) event()      {}
+
+// An Observer is told about everything that happens during a crawl. Events are delivered one at a
+// time, so an Observer does not need to do its own locking, but it should return quickly.
+type Observer interface {
+	Observe(event Event)
+}
+
+type ObserverFunc func(event Event)
+
+func (f ObserverFunc) Observe(event Event) {
+	f(event)
+}
+
+// OnPageCrawled returns an Observer that calls handlePage for each page as soon as it is crawled.
+func OnPageCrawled(handlePage func(URL url.URL, page sitemap.Page)) Observer {
+	return ObserverFunc(func(event Event) {
+		if pageCrawled, ok := event.(PageCrawled); ok {
+			handlePage(pageCrawled.URL, pageCrawled.Page)
+		}
+	})
+}
+
+type observers struct {
+	mutex sync.Mutex
+	list  []Observer
+}
+
+func newObservers(configuration Configuration) *observers {
+	list := make([]Observer, 0, len(configuration.Observers)+1)
+	if configuration.Logger != nil {
+		list = append(list, NewLogObserver(configuration.Logger))
+	}
+
+	return &observers{list: append(list, configuration.Observers...)}
+}
+
+func (o *observers) notify(event Event) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	for _, observer := range o.list {
+		observer.Observe(event)
+	}
+}
@@ -0,0 +1,153 @@
+package crawler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+	"github.com/hilverd/sitemapper/logging"
+)
+
+func describeEvent(event Event) string {
+	switch event := event.(type) {
+	case PageQueued:
+		return fmt.Sprintf("queued %s at depth %d", event.URL.String(), event.Depth)
+	case FetchStarted:
+		return fmt.Sprintf("started %s", event.URL.String())
+	case FetchFinished:
+		return fmt.Sprintf("finished %s with status %d and %d links", event.URL.String(), event.StatusCode, event.Links)
+	case PageCrawled:
+		return fmt.Sprintf("crawled %s at depth %d", event.URL.String(), event.Page.Depth)
+	case LinkDiscovered:
+		return fmt.Sprintf("discovered %s on %s", event.URL.String(), event.From.String())
+	case LinkRejected:
+		return fmt.Sprintf("rejected %s (%s)", event.URL.String(), event.Reason)
+	case CrawlFinished:
+		return fmt.Sprintf("crawl finished after %d pages", event.Report.PagesCrawled)
+	default:
+		return fmt.Sprintf("%T", event)
+	}
+}
+
+func TestCrawlNotifiesObservers(t *testing.T) {
+	stub := stubLinkExtractor{
+		urlToLinks: map[url.URL][]url.URL{
+			crawlertest.MakeURL("https://example.com/"): {
+				crawlertest.MakeURL("https://example.com/a"),
+				crawlertest.MakeURL("https://other.example.com/"),
+			},
+			crawlertest.MakeURL("https://example.com/a"): {
+				crawlertest.MakeURL("https://example.com/a/b"),
+			},
+		},
+	}
+
+	got := make([]string, 0)
+	configuration := Configuration{
+		MaxConcurrentRequests: 1,
+		MaxDepth:              1,
+		SeedURL:               crawlertest.MakeURL("https://example.com/"),
+		Observers: []Observer{ObserverFunc(func(event Event) {
+			got = append(got, describeEvent(event))
+		})},
+	}
+
+	Crawl(configuration, stub)
+
+	want := []string{
+		"queued https://example.com/ at depth 0",
+		"started https://example.com/",
+		"finished https://example.com/ with status 200 and 2 links",
+		"crawled https://example.com/ at depth 0",
+		"discovered https://example.com/a on https://example.com/",
+		"discovered https://other.example.com/ on https://example.com/",
+		"rejected https://other.example.com/ (other-host)",
+		"queued https://example.com/a at depth 1",
+		"started https://example.com/a",
+		"finished https://example.com/a with status 200 and 1 links",
+		"crawled https://example.com/a at depth 1",
+		"discovered https://example.com/a/b on https://example.com/a",
+		"rejected https://example.com/a/b (max-depth)",
+		"crawl finished after 2 pages",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Crawl() notified %v, want %v", got, want)
+	}
+}
+
+func TestLogObserver(t *testing.T) {
+	trapURL := crawlertest.MakeURL("https://example.com/a/a/a/")
+
+	tests := []struct {
+		name   string
+		level  logging.Level
+		events []Event
+		want   string
+	}{
+		{
+			name:  "fetches",
+			level: logging.Debug,
+			events: []Event{
+				FetchStarted{URL: crawlertest.MakeURL("https://example.com/")},
+				FetchFinished{URL: crawlertest.MakeURL("https://example.com/"), StatusCode: 200, Duration: time.Second, Retries: 1, Links: 3, Truncated: true},
+				FetchFinished{URL: crawlertest.MakeURL("https://example.com/a"), Depth: 1, StatusCode: 503, Duration: 2 * time.Second, Retries: 2, Err: errors.New("Got a 503 Service Unavailable response")},
+			},
+			want: `level=DEBUG msg="Extracting links" url=https://example.com/ depth=0
+level=DEBUG msg="Extracted links" url=https://example.com/ depth=0 status=200 duration=1s retries=1 links=3
+level=WARN msg="Response body was truncated, so some links may be missing" url=https://example.com/ depth=0
+level=WARN msg="Failed to extract links" url=https://example.com/a depth=1 status=503 duration=2s retries=2 error="Got a 503 Service Unavailable response"
+`,
+		},
+		{
+			name:  "fetches are only logged at the debug level",
+			level: logging.Info,
+			events: []Event{
+				FetchStarted{URL: crawlertest.MakeURL("https://example.com/")},
+				FetchFinished{URL: crawlertest.MakeURL("https://example.com/"), StatusCode: 200},
+			},
+			want: "",
+		},
+		{
+			name:  "retries, concurrency and limits",
+			level: logging.Info,
+			events: []Event{
+				Retry{URL: crawlertest.MakeURL("https://example.com/"), Retries: 1, Delay: 2 * time.Second, Err: errors.New("timeout")},
+				ConcurrencyChanged{Limit: 4},
+				MaxBytesReached{WireBytes: 1000},
+			},
+			want: `level=INFO msg=Retrying url=https://example.com/ retries=1 delay=2s error=timeout
+level=INFO msg="Concurrency limit changed" limit=4
+level=WARN msg="Maximum number of bytes reached, not queueing any more pages" wire_bytes=1000
+`,
+		},
+		{
+			name:  "traps are reported once",
+			level: logging.Info,
+			events: []Event{
+				LinkRejected{URL: trapURL, Depth: 4, Reason: "repeating-path-segments", Trap: true},
+				LinkRejected{URL: trapURL, Depth: 4, Reason: "repeating-path-segments", Trap: true},
+				LinkRejected{URL: crawlertest.MakeURL("https://example.com/deep"), Reason: ReasonMaxDepth},
+			},
+			want: `level=INFO msg="Skipping likely crawler trap" url=https://example.com/a/a/a/ depth=4 rule=repeating-path-segments
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			observer := NewLogObserver(logging.New(&output, logging.Text, tt.level))
+			for _, event := range tt.events {
+				observer.Observe(event)
+			}
+
+			if got := output.String(); got != tt.want {
+				t.Errorf("logObserver wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
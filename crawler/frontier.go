@@ -0,0 +1,128 @@
+package crawler
+
+import (
+	"container/heap"
+	"net/url"
+	"strings"
+)
+
+// A PriorityFunc decides the order in which queued links are crawled: links with lower priorities
+// are crawled first, and links with equal priorities are crawled in order of their URLs.
+type PriorityFunc func(URL url.URL, depth int) int
+
+// BreadthFirst crawls links strictly in order of depth. This is the default.
+func BreadthFirst(URL url.URL, depth int) int {
+	return depth
+}
+
+// ShortestPathFirst crawls links with fewer path segments first, regardless of their depth. With any
+// order other than BreadthFirst, a page's depth is that of the path it was first crawled through,
+// which need not be the shortest one.
+func ShortestPathFirst(URL url.URL, depth int) int {
+	path := strings.Trim(URL.Path, "/")
+	if path == "" {
+		return 0
+	}
+
+	return strings.Count(path, "/") + 1
+}
+
+type frontierItem struct {
+	link     urlAtDepth
+	priority int
+	index    int
+}
+
+type frontierItems []*frontierItem
+
+func (items frontierItems) Len() int { return len(items) }
+
+func (items frontierItems) Less(i, j int) bool {
+	switch {
+	case items[i].priority != items[j].priority:
+		return items[i].priority < items[j].priority
+	case items[i].link.depth != items[j].link.depth:
+		return items[i].link.depth < items[j].link.depth
+	default:
+		return items[i].link.URL.String() < items[j].link.URL.String()
+	}
+}
+
+func (items frontierItems) Swap(i, j int) {
+	items[i], items[j] = items[j], items[i]
+	items[i].index = i
+	items[j].index = j
+}
+
+func (items *frontierItems) Push(x interface{}) {
+	item := x.(*frontierItem)
+	item.index = len(*items)
+	*items = append(*items, item)
+}
+
+func (items *frontierItems) Pop() interface{} {
+	old := *items
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*items = old[:len(old)-1]
+	return item
+}
+
+// frontier is a priority queue of links to be crawled that holds each URL at most once.
+type frontier struct {
+	priority   PriorityFunc
+	items      frontierItems
+	itemsByURL map[url.URL]*frontierItem
+}
+
+func newFrontier(configuration Configuration) *frontier {
+	priority := configuration.Priority
+	if priority == nil {
+		priority = BreadthFirst
+	}
+
+	return &frontier{
+		priority:   priority,
+		items:      frontierItems{},
+		itemsByURL: map[url.URL]*frontierItem{},
+	}
+}
+
+// push queues a link, or moves it to a lower depth if it was already queued at a greater one. It
+// returns false if the frontier did not change.
+func (f *frontier) push(link urlAtDepth) bool {
+	if item, queued := f.itemsByURL[link.URL]; queued {
+		if link.depth >= item.link.depth {
+			return false
+		}
+
+		item.link = link
+		item.priority = f.priority(link.URL, link.depth)
+		heap.Fix(&f.items, item.index)
+		return true
+	}
+
+	item := &frontierItem{link: link, priority: f.priority(link.URL, link.depth)}
+	f.itemsByURL[link.URL] = item
+	heap.Push(&f.items, item)
+	return true
+}
+
+func (f *frontier) peek() urlAtDepth {
+	return f.items[0].link
+}
+
+func (f *frontier) pop() urlAtDepth {
+	item := heap.Pop(&f.items).(*frontierItem)
+	delete(f.itemsByURL, item.link.URL)
+	return item.link
+}
+
+func (f *frontier) len() int {
+	return len(f.items)
+}
+
+func (f *frontier) clear() {
+	f.items = frontierItems{}
+	f.itemsByURL = map[url.URL]*frontierItem{}
+}
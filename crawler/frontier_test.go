@@ -0,0 +1,94 @@
+package crawler
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+func Test_frontier(t *testing.T) {
+	tests := []struct {
+		name     string
+		priority PriorityFunc
+		pushed   []urlAtDepth
+		want     []urlAtDepth
+	}{
+		{
+			name: "breadth first, then by URL",
+			pushed: []urlAtDepth{
+				{crawlertest.MakeURL("https://example.com/b/c"), 2},
+				{crawlertest.MakeURL("https://example.com/z"), 1},
+				{crawlertest.MakeURL("https://example.com/a"), 1},
+			},
+			want: []urlAtDepth{
+				{crawlertest.MakeURL("https://example.com/a"), 1},
+				{crawlertest.MakeURL("https://example.com/z"), 1},
+				{crawlertest.MakeURL("https://example.com/b/c"), 2},
+			},
+		},
+		{
+			name: "a link found again at a lower depth moves forward",
+			pushed: []urlAtDepth{
+				{crawlertest.MakeURL("https://example.com/a"), 2},
+				{crawlertest.MakeURL("https://example.com/b"), 2},
+				{crawlertest.MakeURL("https://example.com/b"), 1},
+				{crawlertest.MakeURL("https://example.com/a"), 3},
+			},
+			want: []urlAtDepth{
+				{crawlertest.MakeURL("https://example.com/b"), 1},
+				{crawlertest.MakeURL("https://example.com/a"), 2},
+			},
+		},
+		{
+			name:     "shortest path first",
+			priority: ShortestPathFirst,
+			pushed: []urlAtDepth{
+				{crawlertest.MakeURL("https://example.com/a/b/c"), 1},
+				{crawlertest.MakeURL("https://example.com/x"), 4},
+				{crawlertest.MakeURL("https://example.com/a/b"), 2},
+			},
+			want: []urlAtDepth{
+				{crawlertest.MakeURL("https://example.com/x"), 4},
+				{crawlertest.MakeURL("https://example.com/a/b"), 2},
+				{crawlertest.MakeURL("https://example.com/a/b/c"), 1},
+			},
+		},
+		{
+			name: "user-defined scorer",
+			priority: func(URL url.URL, depth int) int {
+				if strings.HasPrefix(URL.Path, "/docs/") {
+					return 0
+				}
+				return 1
+			},
+			pushed: []urlAtDepth{
+				{crawlertest.MakeURL("https://example.com/blog/post"), 1},
+				{crawlertest.MakeURL("https://example.com/docs/guide"), 3},
+			},
+			want: []urlAtDepth{
+				{crawlertest.MakeURL("https://example.com/docs/guide"), 3},
+				{crawlertest.MakeURL("https://example.com/blog/post"), 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frontier := newFrontier(Configuration{Priority: tt.priority})
+			for _, link := range tt.pushed {
+				frontier.push(link)
+			}
+
+			got := make([]urlAtDepth, 0)
+			for frontier.len() > 0 {
+				got = append(got, frontier.pop())
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("frontier popped %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package crawler
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/hilverd/sitemapper/linkextractor"
+	"github.com/hilverd/sitemapper/logging"
+)
+
+type logObserver struct {
+	logger       *logging.Logger
+	reportedURLs map[url.URL]bool
+}
+
+// NewLogObserver returns an Observer that logs fetches at the debug level, retries, traps, changes
+// in concurrency and pages that robots.txt disallows at the info level, and failures at the warn
+// level.
+func NewLogObserver(logger *logging.Logger) Observer {
+	return &logObserver{logger: logger, reportedURLs: map[url.URL]bool{}}
+}
+
+func (observer *logObserver) Observe(event Event) {
+	logger := observer.logger
+
+	switch event := event.(type) {
+	case FetchStarted:
+		logger.Debug("Extracting links", "url", event.URL.String(), "depth", event.Depth)
+	case FetchFinished:
+		switch {
+		case errors.Is(event.Err, linkextractor.ErrDisallowedByRobots):
+			logger.Info("Skipping page that robots.txt disallows", "url", event.URL.String(), "depth", event.Depth)
+		case event.Err != nil:
+			logger.Warn("Failed to extract links", "url", event.URL.String(), "depth", event.Depth, "status", event.StatusCode, "duration", event.Duration, "retries", event.Retries, "error", event.Err)
+		default:
+			logger.Debug("Extracted links", "url", event.URL.String(), "depth", event.Depth, "status", event.StatusCode, "duration", event.Duration, "retries", event.Retries, "links", event.Links)
+			if event.Truncated {
+				logger.Warn("Response body was truncated, so some links may be missing", "url", event.URL.String(), "depth", event.Depth)
+			}
+		}
+	case LinkRejected:
+		if event.Trap && !observer.reportedURLs[event.URL] {
+			observer.reportedURLs[event.URL] = true
+			logger.Info("Skipping likely crawler trap", "url", event.URL.String(), "depth", event.Depth, "rule", event.Reason)
+		}
+	case Retry:
+		logger.Info("Retrying", "url", event.URL.String(), "retries", event.Retries, "delay", event.Delay, "error", event.Err)
+	case ConcurrencyChanged:
+		logger.Info("Concurrency limit changed", "limit", event.Limit)
+	case MaxBytesReached:
+		logger.Warn("Maximum number of bytes reached, not queueing any more pages", "wire_bytes", event.WireBytes)
+	case CrawlFinished:
+		logger.Info("Crawl finished", "pages", event.Report.PagesCrawled, "failed", event.Report.PagesFailed, "wire_bytes", event.Report.WireBytes, "decoded_bytes", event.Report.DecodedBytes, "duration", event.Duration)
+	}
+}
@@ -0,0 +1,73 @@
+package crawler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Report struct {
+	PagesCrawled     int
+	PagesFailed      int
+	WireBytes        int64
+	DecodedBytes     int64
+	MaxBytesReached  bool
+	Cancelled        bool
+	BudgetSkips      map[string]int
+	TrapRejections   []TrapRejection
+	RobotsDisallowed int
+}
+
+func (report Report) String() string {
+	lines := []string{
+		fmt.Sprintf("Crawled %d pages (%d failed)", report.PagesCrawled, report.PagesFailed),
+		fmt.Sprintf("Transferred %s (%s after decompression)", FormatBytes(report.WireBytes), FormatBytes(report.DecodedBytes)),
+	}
+
+	if report.Cancelled {
+		lines = append(lines, "The crawl was cancelled before all pages were crawled")
+	}
+
+	if report.MaxBytesReached {
+		lines = append(lines, "Stopped queueing pages because the maximum number of bytes was reached")
+	}
+
+	budgets := make([]string, 0, len(report.BudgetSkips))
+	for budget := range report.BudgetSkips {
+		budgets = append(budgets, budget)
+	}
+	sort.Strings(budgets)
+
+	for _, budget := range budgets {
+		lines = append(lines, fmt.Sprintf("Budget %s was hit: skipped %d URLs", budget, report.BudgetSkips[budget]))
+	}
+
+	if report.RobotsDisallowed > 0 {
+		lines = append(lines, fmt.Sprintf("Skipped %d URLs that robots.txt disallows", report.RobotsDisallowed))
+	}
+
+	if len(report.TrapRejections) > 0 {
+		lines = append(lines, fmt.Sprintf("Skipped %d URLs that look like crawler traps:", len(report.TrapRejections)))
+		for _, rejection := range report.TrapRejections {
+			lines = append(lines, fmt.Sprintf("  %s (%s)", rejection.URL.String(), rejection.Rule))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// FormatBytes formats a number of bytes using binary units, as in 13.6 KiB.
+func FormatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	value, exponent := float64(bytes)/unit, 0
+	for value >= unit && exponent < 4 {
+		value /= unit
+		exponent++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[exponent])
+}
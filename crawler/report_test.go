@@ -0,0 +1,81 @@
+package crawler
+
+import (
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+func TestReport_String(t *testing.T) {
+	tests := []struct {
+		name   string
+		report Report
+		want   string
+	}{
+		{
+			name:   "empty report",
+			report: Report{},
+			want: `Crawled 0 pages (0 failed)
+Transferred 0 B (0 B after decompression)`,
+		},
+		{
+			name: "byte budget reached",
+			report: Report{
+				PagesCrawled:    120,
+				PagesFailed:     3,
+				WireBytes:       1536,
+				DecodedBytes:    5 * 1024 * 1024,
+				MaxBytesReached: true,
+			},
+			want: `Crawled 120 pages (3 failed)
+Transferred 1.5 KiB (5.0 MiB after decompression)
+Stopped queueing pages because the maximum number of bytes was reached`,
+		},
+		{
+			name: "other budgets hit",
+			report: Report{
+				PagesCrawled: 200,
+				BudgetSkips: map[string]int{
+					"max-pages=200":                        12,
+					"max-pages-per-path-prefix /tags/=100": 3,
+				},
+			},
+			want: `Crawled 200 pages (0 failed)
+Transferred 0 B (0 B after decompression)
+Budget max-pages-per-path-prefix /tags/=100 was hit: skipped 3 URLs
+Budget max-pages=200 was hit: skipped 12 URLs`,
+		},
+		{
+			name: "pages disallowed by robots.txt",
+			report: Report{
+				PagesCrawled:     10,
+				RobotsDisallowed: 4,
+			},
+			want: `Crawled 10 pages (0 failed)
+Transferred 0 B (0 B after decompression)
+Skipped 4 URLs that robots.txt disallows`,
+		},
+		{
+			name: "crawler traps",
+			report: Report{
+				PagesCrawled: 4,
+				TrapRejections: []TrapRejection{
+					{URL: crawlertest.MakeURL("https://example.com/a/a/a/"), Rule: "repeating-path-segments"},
+					{URL: crawlertest.MakeURL("https://example.com/shop;sid=1"), Rule: "session-id"},
+				},
+			},
+			want: `Crawled 4 pages (0 failed)
+Transferred 0 B (0 B after decompression)
+Skipped 2 URLs that look like crawler traps:
+  https://example.com/a/a/a/ (repeating-path-segments)
+  https://example.com/shop;sid=1 (session-id)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.report.String(); got != tt.want {
+				t.Errorf("Report.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
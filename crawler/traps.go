@@ -0,0 +1,208 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type TrapRule struct {
+	Name   string
+	IsTrap func(link url.URL, referrer url.URL) bool
+}
+
+type TrapRejection struct {
+	URL  url.URL
+	Rule string
+}
+
+const (
+	defaultMaxURLLength                = 1024
+	defaultMinRepetitions              = 3
+	defaultMinParametersInGrowingQuery = 4
+)
+
+var sessionIDInPathPattern = regexp.MustCompile(`(?i)(;(jsessionid|phpsessid|sid|sessionid)=)|(/\(S\([a-z0-9]+\)\))`)
+
+var sessionIDParameters = map[string]bool{
+	"jsessionid":   true,
+	"phpsessid":    true,
+	"aspsessionid": true,
+	"sessionid":    true,
+	"session_id":   true,
+	"sid":          true,
+}
+
+func DefaultTrapRules() []TrapRule {
+	return []TrapRule{
+		RepeatingPathSegmentsRule(defaultMinRepetitions),
+		GrowingQueryRule(defaultMinParametersInGrowingQuery),
+		RepeatedQueryParameterRule(defaultMinRepetitions),
+		LongURLRule(defaultMaxURLLength),
+		SessionIDRule(),
+	}
+}
+
+// RepeatingPathSegmentsRule catches paths in which a sequence of segments occurs a number of times
+// in a row, like /a/b/a/b/a/b, which typically come from relative links on pages that are served
+// under any path.
+func RepeatingPathSegmentsRule(minRepetitions int) TrapRule {
+	return TrapRule{
+		Name: "repeating-path-segments",
+		IsTrap: func(link url.URL, referrer url.URL) bool {
+			segments := strings.Split(strings.Trim(link.Path, "/"), "/")
+
+			for period := 1; period*minRepetitions <= len(segments); period++ {
+				for start := 0; start+period*minRepetitions <= len(segments); start++ {
+					if sequenceRepeats(segments[start:], period, minRepetitions) {
+						return true
+					}
+				}
+			}
+
+			return false
+		},
+	}
+}
+
+func sequenceRepeats(segments []string, period int, repetitions int) bool {
+	for i := period; i < period*repetitions; i++ {
+		if segments[i] != segments[i%period] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GrowingQueryRule catches links that keep adding parameters to the query string of the page they
+// are on, as happens with some calendars and faceted search pages.
+func GrowingQueryRule(minParameters int) TrapRule {
+	return TrapRule{
+		Name: "growing-query",
+		IsTrap: func(link url.URL, referrer url.URL) bool {
+			if link.Path != referrer.Path || referrer.RawQuery == "" || len(link.RawQuery) <= len(referrer.RawQuery) {
+				return false
+			}
+
+			linkParameters := link.Query()
+			if countValues(linkParameters) < minParameters {
+				return false
+			}
+
+			for name, values := range referrer.Query() {
+				if len(linkParameters[name]) < len(values) {
+					return false
+				}
+			}
+
+			return countValues(linkParameters) > countValues(referrer.Query())
+		},
+	}
+}
+
+// RepeatedQueryParameterRule catches links in which a query parameter is given at least
+// minRepetitions times, like ?tag=a&tag=a&tag=a, which pages that add a parameter to their own URL
+// tend to produce.
+func RepeatedQueryParameterRule(minRepetitions int) TrapRule {
+	return TrapRule{
+		Name: "repeated-query-parameter",
+		IsTrap: func(link url.URL, referrer url.URL) bool {
+			for _, values := range link.Query() {
+				if len(values) >= minRepetitions {
+					return true
+				}
+			}
+
+			return false
+		},
+	}
+}
+
+// LongURLRule catches links that are more than maxLength bytes long, as pages that keep extending
+// their own URLs eventually produce.
+func LongURLRule(maxLength int) TrapRule {
+	return TrapRule{
+		Name: fmt.Sprintf("url-longer-than-%d", maxLength),
+		IsTrap: func(link url.URL, referrer url.URL) bool {
+			return len(link.String()) > maxLength
+		},
+	}
+}
+
+// SessionIDRule catches session IDs in paths, like /shop;jsessionid=42, which give every visitor
+// their own copy of the site.
+func SessionIDRule() TrapRule {
+	return TrapRule{
+		Name: "session-id",
+		IsTrap: func(link url.URL, referrer url.URL) bool {
+			return sessionIDInPathPattern.MatchString(link.Path)
+		},
+	}
+}
+
+// SessionIDParameterRule catches query parameters that are commonly used for session IDs, like
+// ?PHPSESSID=42. It is not one of the default rules, because some sites use names like sid for
+// ordinary parameters.
+func SessionIDParameterRule() TrapRule {
+	return TrapRule{
+		Name: "session-id-parameter",
+		IsTrap: func(link url.URL, referrer url.URL) bool {
+			for name := range link.Query() {
+				if sessionIDParameters[strings.ToLower(name)] {
+					return true
+				}
+			}
+
+			return false
+		},
+	}
+}
+
+func countValues(values url.Values) int {
+	result := 0
+	for _, valuesForName := range values {
+		result += len(valuesForName)
+	}
+
+	return result
+}
+
+func trapRules(configuration Configuration) []TrapRule {
+	if configuration.TrapRules == nil {
+		return DefaultTrapRules()
+	}
+
+	return configuration.TrapRules
+}
+
+func filterOutTraps(state crawlState, referrer url.URL, links []urlAtDepth) (crawlState, []urlAtDepth) {
+	newState := state
+	result := make([]urlAtDepth, 0)
+
+	for _, link := range links {
+		if rule, isTrap := findTrapRule(newState.trapRules, link.URL, referrer); isTrap {
+			if !newState.trapURLs[link.URL] {
+				newState.trapURLs[link.URL] = true
+				newState.report.TrapRejections = append(newState.report.TrapRejections, TrapRejection{URL: link.URL, Rule: rule})
+			}
+			newState.observers.notify(LinkRejected{From: referrer, URL: link.URL, Depth: link.depth, Reason: rule, Trap: true})
+			continue
+		}
+
+		result = append(result, link)
+	}
+
+	return newState, result
+}
+
+func findTrapRule(rules []TrapRule, link url.URL, referrer url.URL) (string, bool) {
+	for _, rule := range rules {
+		if rule.IsTrap(link, referrer) {
+			return rule.Name, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,177 @@
+package crawler
+
+import (
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+	"github.com/hilverd/sitemapper/logging"
+)
+
+func Test_findTrapRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []TrapRule
+		link     string
+		referrer string
+		want     string
+		wantTrap bool
+	}{
+		{
+			name:     "ordinary link",
+			link:     "https://example.com/docs/guide/intro",
+			referrer: "https://example.com/docs/",
+		},
+		{
+			name:     "repeating sequence of path segments",
+			link:     "https://example.com/a/b/a/b/a/b",
+			referrer: "https://example.com/a/b/a/b",
+			want:     "repeating-path-segments",
+			wantTrap: true,
+		},
+		{
+			name:     "repeating single path segment",
+			link:     "https://example.com/x/img/img/img/logo.png",
+			referrer: "https://example.com/x/img/img/",
+			want:     "repeating-path-segments",
+			wantTrap: true,
+		},
+		{
+			name:     "path segment repeated twice",
+			link:     "https://example.com/a/b/a/b",
+			referrer: "https://example.com/",
+		},
+		{
+			name:     "first refinement of a faceted search",
+			link:     "https://example.com/search?q=shoes&colour=red",
+			referrer: "https://example.com/search?q=shoes",
+		},
+		{
+			name:     "growing query string",
+			link:     "https://example.com/search?q=shoes&colour=red&size=9&brand=x",
+			referrer: "https://example.com/search?q=shoes&colour=red&size=9",
+			want:     "growing-query",
+			wantTrap: true,
+		},
+		{
+			name:     "query string on another path",
+			link:     "https://example.com/other?q=shoes&colour=red&size=9&brand=x",
+			referrer: "https://example.com/search?q=shoes&colour=red&size=9",
+		},
+		{
+			name:     "repeated query parameter",
+			link:     "https://example.com/calendar?month=1&month=1&month=1",
+			referrer: "https://example.com/calendar",
+			want:     "repeated-query-parameter",
+			wantTrap: true,
+		},
+		{
+			name:     "very long URL",
+			link:     "https://example.com/" + strings.Repeat("x", 1100),
+			referrer: "https://example.com/",
+			want:     "url-longer-than-1024",
+			wantTrap: true,
+		},
+		{
+			name:     "session ID in path",
+			link:     "https://example.com/shop;jsessionid=0123456789ABCDEF",
+			referrer: "https://example.com/",
+			want:     "session-id",
+			wantTrap: true,
+		},
+		{
+			name:     "cookieless ASP.NET session",
+			link:     "https://example.com/(S(lit3py55t21z5v55vlm25s55))/default.aspx",
+			referrer: "https://example.com/",
+			want:     "session-id",
+			wantTrap: true,
+		},
+		{
+			name:     "session ID in query",
+			link:     "https://example.com/shop?PHPSESSID=0123456789abcdef",
+			referrer: "https://example.com/",
+		},
+		{
+			name:     "session ID in query with the session ID parameter rule",
+			rules:    []TrapRule{SessionIDParameterRule()},
+			link:     "https://example.com/shop?PHPSESSID=0123456789abcdef",
+			referrer: "https://example.com/",
+			want:     "session-id-parameter",
+			wantTrap: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := tt.rules
+			if rules == nil {
+				rules = DefaultTrapRules()
+			}
+			got, gotTrap := findTrapRule(rules, crawlertest.MakeURL(tt.link), crawlertest.MakeURL(tt.referrer))
+			if got != tt.want || gotTrap != tt.wantTrap {
+				t.Errorf("findTrapRule() = %v, %v, want %v, %v", got, gotTrap, tt.want, tt.wantTrap)
+			}
+		})
+	}
+}
+
+func TestCrawlWithTrapDetection(t *testing.T) {
+	stub := stubLinkExtractor{
+		urlToLinks: map[url.URL][]url.URL{
+			crawlertest.MakeURL("https://example.com/"): {
+				crawlertest.MakeURL("https://example.com/a/"),
+				crawlertest.MakeURL("https://example.com/shop;jsessionid=42"),
+			},
+			crawlertest.MakeURL("https://example.com/a/"): {
+				crawlertest.MakeURL("https://example.com/a/a/"),
+			},
+			crawlertest.MakeURL("https://example.com/a/a/"): {
+				crawlertest.MakeURL("https://example.com/a/a/a/"),
+			},
+			crawlertest.MakeURL("https://example.com/a/a/a/"):             {},
+			crawlertest.MakeURL("https://example.com/shop;jsessionid=42"): {},
+		},
+	}
+
+	tests := []struct {
+		name               string
+		trapRules          []TrapRule
+		wantPages          int
+		wantTrapRejections []TrapRejection
+	}{
+		{
+			name:      "default rules",
+			wantPages: 3,
+			wantTrapRejections: []TrapRejection{
+				{URL: crawlertest.MakeURL("https://example.com/shop;jsessionid=42"), Rule: "session-id"},
+				{URL: crawlertest.MakeURL("https://example.com/a/a/a/"), Rule: "repeating-path-segments"},
+			},
+		},
+		{
+			name:      "trap detection disabled",
+			trapRules: []TrapRule{},
+			wantPages: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configuration := Configuration{
+				MaxConcurrentRequests: 1,
+				TrapRules:             tt.trapRules,
+				SeedURL:               crawlertest.MakeURL("https://example.com/"),
+				Logger:                logging.New(ioutil.Discard, logging.Text, logging.Debug),
+			}
+
+			got, gotReport := CrawlWithReport(configuration, stub)
+
+			if len(got) != tt.wantPages {
+				t.Errorf("CrawlWithReport() found %d pages, want %d", len(got), tt.wantPages)
+			}
+			if !reflect.DeepEqual(gotReport.TrapRejections, tt.wantTrapRejections) {
+				t.Errorf("CrawlWithReport() trap rejections = %v, want %v", gotReport.TrapRejections, tt.wantTrapRejections)
+			}
+		})
+	}
+}
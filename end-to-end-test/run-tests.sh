@@ -56,16 +56,48 @@ $server_url/development
   go build github.com/hilverd/sitemapper
 )
 
-actual_output=$(../sitemapper "$server_url/")
+check-output() {
+  local name="$1"
+  local actual_output="$2"
 
-if diff -q >/dev/null <(echo "$expected_output") <(echo "$actual_output"); then
-  log 'ok  	end-to-end tests'
-else
-  fail "FAIL: end-to-end tests. Expected output:
+  if diff -q >/dev/null <(echo "$expected_output") <(echo "$actual_output"); then
+    log "ok  	end-to-end tests ($name)"
+  else
+    fail "FAIL: end-to-end tests ($name). Expected output:
 
 $expected_output
 
 Actual output:
 
 $actual_output"
-fi
+  fi
+}
+
+check-output crawl "$(../sitemapper "$server_url/")"
+
+start-api-server() {
+  api_log_file="$tmp_dir/api.log"
+  ../sitemapper serve -addr 127.0.0.1:0 -log-level info 2> "$api_log_file" &
+
+  i=0
+  until grep -q 'Serving crawl API' "$api_log_file"; do
+    i=$(($i + 1))
+    [[ $i -lt $max_tries ]] || fail 'Timed out waiting for API server to start'
+    sleep 0.5
+  done
+
+  api_url=$(sed -n 's/.*Serving crawl API url=//p' "$api_log_file")
+}
+
+start-api-server
+
+job_id=$(curl -sf -d "{\"seed_url\": \"$server_url/\"}" "$api_url" | sed -n 's/.*"id": "\(.*\)".*/\1/p')
+
+i=0
+until curl -sf "$api_url/$job_id" | grep -q '"state": "finished"'; do
+  i=$(($i + 1))
+  [[ $i -lt $max_tries ]] || fail 'Timed out waiting for crawl job to finish'
+  sleep 0.5
+done
+
+check-output serve "$(curl -sf "$api_url/$job_id/result?format=text")"
@@ -1,5 +1,13 @@
 module github.com/hilverd/sitemapper
 
-go 1.16
+go 1.26.0
 
-require github.com/PuerkitoBio/goquery v1.7.0
+require (
+	github.com/PuerkitoBio/goquery v1.7.0
+	github.com/andybalholm/brotli v1.0.4
+	golang.org/x/net v0.60.0
+	golang.org/x/text v0.42.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require github.com/andybalholm/cascadia v1.1.0 // indirect
@@ -0,0 +1,49 @@
+package httpclient
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+func ParseHeader(line string) (string, string, error) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return "", "", fmt.Errorf("expected a header of the form 'Name: value'")
+	}
+
+	return http.CanonicalHeaderKey(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1]), nil
+}
+
+func BasicAuthorizationFromEnv(variable string) (string, error) {
+	credentials, err := lookupEnv(variable)
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.Contains(credentials, ":") {
+		return "", fmt.Errorf("environment variable %s should contain credentials of the form 'username:password'", variable)
+	}
+
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials)), nil
+}
+
+func BearerAuthorizationFromEnv(variable string) (string, error) {
+	token, err := lookupEnv(variable)
+	if err != nil {
+		return "", err
+	}
+
+	return "Bearer " + token, nil
+}
+
+func lookupEnv(variable string) (string, error) {
+	value, ok := os.LookupEnv(variable)
+	if !ok || value == "" {
+		return "", fmt.Errorf("environment variable %s is not set", variable)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,93 @@
+package httpclient
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		wantName  string
+		wantValue string
+		wantErr   bool
+	}{
+		{
+			name:      "name and value are trimmed and canonicalised",
+			line:      "x-api-key:  secret ",
+			wantName:  "X-Api-Key",
+			wantValue: "secret",
+			wantErr:   false,
+		},
+		{
+			name:      "value may contain colons",
+			line:      "Referer: https://example.com/",
+			wantName:  "Referer",
+			wantValue: "https://example.com/",
+			wantErr:   false,
+		},
+		{
+			name:    "missing colon",
+			line:    "Referer",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotName, gotValue, err := ParseHeader(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseHeader() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotName != tt.wantName || gotValue != tt.wantValue {
+				t.Errorf("ParseHeader() = %v, %v, want %v, %v", gotName, gotValue, tt.wantName, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestBasicAuthorizationFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "credentials are encoded",
+			value:   "alice:s3cret",
+			want:    "Basic YWxpY2U6czNjcmV0",
+			wantErr: false,
+		},
+		{
+			name:    "credentials without a colon are rejected without revealing them",
+			value:   "s3cret",
+			wantErr: true,
+		},
+		{
+			name:    "unset variable",
+			value:   "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("SITEMAPPER_TEST_CREDENTIALS", tt.value)
+			defer os.Unsetenv("SITEMAPPER_TEST_CREDENTIALS")
+
+			got, err := BasicAuthorizationFromEnv("SITEMAPPER_TEST_CREDENTIALS")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("BasicAuthorizationFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && tt.value != "" && strings.Contains(err.Error(), tt.value) {
+				t.Errorf("BasicAuthorizationFromEnv() error = %v, reveals credentials", err)
+			}
+			if got != tt.want {
+				t.Errorf("BasicAuthorizationFromEnv() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type Configuration struct {
+	Timeout            time.Duration
+	Jar                http.CookieJar
+	ProxyURL           *url.URL
+	CACertFile         string
+	ClientCertFile     string
+	ClientKeyFile      string
+	InsecureSkipVerify bool
+	Resolve            map[string]string
+}
+
+func New(configuration Configuration) (*http.Client, error) {
+	transport, err := newTransport(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Timeout:   configuration.Timeout,
+		Jar:       configuration.Jar,
+		Transport: transport,
+	}, nil
+}
+
+func newTransport(configuration Configuration) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if configuration.ProxyURL != nil {
+		switch configuration.ProxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+			transport.Proxy = http.ProxyURL(configuration.ProxyURL)
+		default:
+			return nil, fmt.Errorf("Unsupported proxy scheme: %s", configuration.ProxyURL.Scheme)
+		}
+	}
+
+	tlsConfig, err := newTLSConfig(configuration)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if len(configuration.Resolve) > 0 {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		resolve := configuration.Resolve
+
+		transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			if overriddenAddress, ok := resolve[strings.ToLower(address)]; ok {
+				address = overriddenAddress
+			}
+			return dialer.DialContext(ctx, network, address)
+		}
+	}
+
+	return transport, nil
+}
+
+func newTLSConfig(configuration Configuration) (*tls.Config, error) {
+	result := &tls.Config{InsecureSkipVerify: configuration.InsecureSkipVerify}
+
+	if configuration.CACertFile != "" {
+		pem, err := ioutil.ReadFile(configuration.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read CA certificates: %s", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No CA certificates found in %s", configuration.CACertFile)
+		}
+		result.RootCAs = pool
+	}
+
+	switch {
+	case configuration.ClientCertFile != "" && configuration.ClientKeyFile != "":
+		certificate, err := tls.LoadX509KeyPair(configuration.ClientCertFile, configuration.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load client certificate: %s", err)
+		}
+		result.Certificates = []tls.Certificate{certificate}
+	case configuration.ClientCertFile != "" || configuration.ClientKeyFile != "":
+		return nil, fmt.Errorf("A client certificate and key must be given together")
+	}
+
+	return result, nil
+}
+
+// ParseResolve parses an override of the form host:port:address, as accepted by curl's --resolve
+// option. It returns the address to override (host:port) and the one to connect to instead.
+func ParseResolve(entry string) (string, string, error) {
+	parts := strings.SplitN(entry, ":", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", fmt.Errorf("expected an entry of the form host:port:address")
+	}
+
+	host, port := parts[0], parts[1]
+	address := strings.TrimSuffix(strings.TrimPrefix(parts[2], "["), "]")
+
+	if net.ParseIP(address) == nil {
+		return "", "", fmt.Errorf("%q is not an IP address", address)
+	}
+
+	return strings.ToLower(net.JoinHostPort(host, port)), net.JoinHostPort(address, port), nil
+}
@@ -0,0 +1,126 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseResolve(t *testing.T) {
+	tests := []struct {
+		name                  string
+		entry                 string
+		wantAddress           string
+		wantOverriddenAddress string
+		wantErr               bool
+	}{
+		{
+			name:                  "IPv4 address",
+			entry:                 "Example.com:443:10.0.0.1",
+			wantAddress:           "example.com:443",
+			wantOverriddenAddress: "10.0.0.1:443",
+			wantErr:               false,
+		},
+		{
+			name:                  "IPv6 address",
+			entry:                 "example.com:80:[::1]",
+			wantAddress:           "example.com:80",
+			wantOverriddenAddress: "[::1]:80",
+			wantErr:               false,
+		},
+		{
+			name:    "host name instead of address",
+			entry:   "example.com:80:localhost",
+			wantErr: true,
+		},
+		{
+			name:    "missing port",
+			entry:   "example.com:10.0.0.1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotAddress, gotOverriddenAddress, err := ParseResolve(tt.entry)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseResolve() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotAddress != tt.wantAddress || gotOverriddenAddress != tt.wantOverriddenAddress {
+				t.Errorf("ParseResolve() = %v, %v, want %v, %v", gotAddress, gotOverriddenAddress, tt.wantAddress, tt.wantOverriddenAddress)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	caCertFile := filepath.Join(t.TempDir(), "ca.pem")
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caCertFile, caCert, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	serverAddress := strings.TrimPrefix(server.URL, "https://")
+	_, port, _ := net.SplitHostPort(serverAddress)
+
+	tests := []struct {
+		name          string
+		configuration Configuration
+		URL           string
+		wantErr       bool
+	}{
+		{
+			name:          "self-signed certificate is rejected by default",
+			configuration: Configuration{},
+			URL:           server.URL,
+			wantErr:       true,
+		},
+		{
+			name:          "self-signed certificate is accepted in insecure mode",
+			configuration: Configuration{InsecureSkipVerify: true},
+			URL:           server.URL,
+			wantErr:       false,
+		},
+		{
+			name:          "certificate signed by a custom CA is accepted",
+			configuration: Configuration{CACertFile: caCertFile},
+			URL:           server.URL,
+			wantErr:       false,
+		},
+		{
+			name: "resolve overrides DNS",
+			configuration: Configuration{
+				InsecureSkipVerify: true,
+				Resolve:            map[string]string{"new-deployment.invalid:" + port: serverAddress},
+			},
+			URL:     "https://new-deployment.invalid:" + port + "/",
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := New(tt.configuration)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			response, err := client.Get(tt.URL)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("client.Get() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				response.Body.Close()
+			}
+		})
+	}
+}
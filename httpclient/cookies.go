@@ -0,0 +1,97 @@
+package httpclient
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const httpOnlyPrefix = "#HttpOnly_"
+
+func LoadCookiesFile(jar http.CookieJar, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return LoadCookies(jar, file)
+}
+
+// LoadCookies reads cookies in the Netscape cookies.txt format used by curl, wget and browser
+// extensions, and adds them to the jar.
+func LoadCookies(jar http.CookieJar, reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		if httpOnly {
+			line = strings.TrimPrefix(line, httpOnlyPrefix)
+		}
+
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		cookieURL, cookie, err := parseCookieLine(line)
+		if err != nil {
+			return fmt.Errorf("Invalid cookie on line %d: %s", lineNumber, err)
+		}
+
+		cookie.HttpOnly = httpOnly
+		jar.SetCookies(cookieURL, []*http.Cookie{cookie})
+	}
+
+	return scanner.Err()
+}
+
+func parseCookieLine(line string) (*url.URL, *http.Cookie, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) == 6 {
+		fields = append(fields, "")
+	}
+	if len(fields) != 7 {
+		return nil, nil, fmt.Errorf("expected 7 tab-separated fields but found %d", len(fields))
+	}
+
+	domain, includeSubdomains, path, secure, expiry, name, value :=
+		fields[0], fields[1], fields[2], fields[3], fields[4], fields[5], fields[6]
+
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid expiry time %q", expiry)
+	}
+
+	scheme := "http"
+	if strings.EqualFold(secure, "TRUE") {
+		scheme = "https"
+	}
+
+	cookie := &http.Cookie{
+		Name:   name,
+		Value:  value,
+		Path:   path,
+		Secure: scheme == "https",
+	}
+
+	if strings.EqualFold(includeSubdomains, "TRUE") {
+		cookie.Domain = domain
+	}
+
+	if expiresAt > 0 {
+		cookie.Expires = time.Unix(expiresAt, 0)
+	}
+
+	cookieURL := &url.URL{Scheme: scheme, Host: strings.TrimPrefix(domain, "."), Path: path}
+	return cookieURL, cookie, nil
+}
@@ -0,0 +1,72 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+func TestLoadCookies(t *testing.T) {
+	type request struct {
+		URL  url.URL
+		want []string
+	}
+	tests := []struct {
+		name     string
+		contents string
+		requests []request
+		wantErr  bool
+	}{
+		{
+			name: "cookies are sent to matching URLs",
+			contents: strings.Join([]string{
+				"# Netscape HTTP Cookie File",
+				"",
+				".example.com\tTRUE\t/\tFALSE\t0\tsession\tabc123",
+				"#HttpOnly_intranet.example.com\tFALSE\t/docs\tTRUE\t4102444800\ttoken\txyz",
+			}, "\n"),
+			requests: []request{
+				{crawlertest.MakeURL("http://www.example.com/"), []string{"session=abc123"}},
+				{crawlertest.MakeURL("https://intranet.example.com/docs/a"), []string{"token=xyz", "session=abc123"}},
+				{crawlertest.MakeURL("http://intranet.example.com/docs/a"), []string{"session=abc123"}},
+				{crawlertest.MakeURL("https://example.org/"), []string{}},
+			},
+			wantErr: false,
+		},
+		{
+			name:     "malformed lines are rejected",
+			contents: "example.com\tFALSE\t/\n",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jar, _ := cookiejar.New(nil)
+			err := LoadCookies(jar, strings.NewReader(tt.contents))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadCookies() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			for _, request := range tt.requests {
+				URL := request.URL
+				got := cookieStrings(jar.Cookies(&URL))
+				if !reflect.DeepEqual(got, request.want) {
+					t.Errorf("cookies for %s = %v, want %v", URL.String(), got, request.want)
+				}
+			}
+		})
+	}
+}
+
+func cookieStrings(cookies []*http.Cookie) []string {
+	result := make([]string, 0)
+	for _, cookie := range cookies {
+		result = append(result, cookie.String())
+	}
+	return result
+}
@@ -0,0 +1,54 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+type LoginForm struct {
+	URL    url.URL
+	Fields url.Values
+	Header http.Header
+}
+
+func ParseLoginField(field string) (string, string, error) {
+	parts := strings.SplitN(field, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", fmt.Errorf("expected a form field of the form 'name=value'")
+	}
+
+	return parts[0], os.ExpandEnv(parts[1]), nil
+}
+
+// Submit posts the login form. Any session cookies that are set in response end up in the cookie
+// jar of the client that do belongs to. Field values are never included in errors, as they
+// usually contain credentials.
+func (form LoginForm) Submit(do func(req *http.Request) (*http.Response, error)) error {
+	request, err := http.NewRequest("POST", form.URL.String(), strings.NewReader(form.Fields.Encode()))
+	if err != nil {
+		return fmt.Errorf("Login failed: %s", err)
+	}
+
+	for name, values := range form.Header {
+		request.Header[name] = append([]string(nil), values...)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := do(request)
+	if err != nil {
+		return fmt.Errorf("Login failed: %s", err)
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode >= 400 {
+		return fmt.Errorf("Login failed: got a %s response", response.Status)
+	}
+
+	return nil
+}
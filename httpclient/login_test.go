@@ -0,0 +1,72 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+func TestLoginForm_Submit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.FormValue("username") != "alice" || r.FormValue("password") != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123", Path: "/"})
+	}))
+	defer server.Close()
+
+	os.Setenv("SITEMAPPER_TEST_PASSWORD", "s3cret")
+	defer os.Unsetenv("SITEMAPPER_TEST_PASSWORD")
+
+	tests := []struct {
+		name        string
+		fields      []string
+		wantCookies int
+		wantErr     bool
+	}{
+		{
+			name:        "session cookies are kept after logging in",
+			fields:      []string{"username=alice", "password=${SITEMAPPER_TEST_PASSWORD}"},
+			wantCookies: 1,
+			wantErr:     false,
+		},
+		{
+			name:        "failed login does not reveal credentials",
+			fields:      []string{"username=alice", "password=wrong-password"},
+			wantCookies: 0,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jar, _ := cookiejar.New(nil)
+			client := &http.Client{Jar: jar}
+			form := LoginForm{URL: crawlertest.MakeURL(server.URL + "/login"), Fields: url.Values{}}
+			for _, field := range tt.fields {
+				name, value, _ := ParseLoginField(field)
+				form.Fields.Add(name, value)
+			}
+
+			err := form.Submit(client.Do)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoginForm.Submit() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && strings.Contains(err.Error(), "wrong-password") {
+				t.Errorf("LoginForm.Submit() error = %v, reveals credentials", err)
+			}
+
+			serverURL := crawlertest.MakeURL(server.URL + "/")
+			if got := len(jar.Cookies(&serverURL)); got != tt.wantCookies {
+				t.Errorf("number of cookies = %v, want %v", got, tt.wantCookies)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package linkextractor
+
+import (
+	"io"
+)
+
+// truncatingReader reads at most limit bytes, and remembers whether there was more to read.
+type truncatingReader struct {
+	reader    io.Reader
+	remaining int64
+	truncated bool
+}
+
+func newTruncatingReader(reader io.Reader, limit int64) *truncatingReader {
+	return &truncatingReader{reader: reader, remaining: limit}
+}
+
+func (reader *truncatingReader) Read(p []byte) (int, error) {
+	if reader.remaining <= 0 {
+		if !reader.truncated {
+			var probe [1]byte
+			if n, _ := io.ReadFull(reader.reader, probe[:]); n > 0 {
+				reader.truncated = true
+			}
+		}
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > reader.remaining {
+		p = p[:reader.remaining]
+	}
+
+	n, err := reader.reader.Read(p)
+	reader.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,113 @@
+package linkextractor
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+const charsetPreviewSize = 1024
+
+var xmlEncodingDeclarationPattern = regexp.MustCompile(`^\s*<\?xml[^>]*\sencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+// decodeToUTF8 works out the character set of a response body from (in order of precedence) its
+// byte order mark, the charset parameter of its Content-Type header and any declaration in the
+// document itself, such as <meta charset> or <?xml encoding?>. Without any of those, the body is
+// taken to be UTF-8 if it looks like it, and windows-1252 otherwise. Bodies of media types that
+// are not text are passed through untouched.
+func decodeToUTF8(body io.Reader, mediaType string, contentTypeCharset string) (io.Reader, string, error) {
+	if !isTextMediaType(mediaType) {
+		return body, "", nil
+	}
+
+	bufferedBody := bufio.NewReaderSize(body, charsetPreviewSize)
+	preview, err := bufferedBody.Peek(charsetPreviewSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", err
+	}
+
+	encoding, name := detectCharset(preview, mediaType, contentTypeCharset)
+	if name == "utf-8" {
+		return skipUTF8ByteOrderMark(bufferedBody), name, nil
+	}
+
+	return transform.NewReader(bufferedBody, encoding.NewDecoder()), name, nil
+}
+
+func detectCharset(preview []byte, mediaType string, contentTypeCharset string) (encoding.Encoding, string) {
+	switch {
+	case bytes.HasPrefix(preview, []byte{0xef, 0xbb, 0xbf}):
+		return unicode.UTF8, "utf-8"
+	case bytes.HasPrefix(preview, []byte{0xfe, 0xff}):
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), "utf-16be"
+	case bytes.HasPrefix(preview, []byte{0xff, 0xfe}):
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), "utf-16le"
+	}
+
+	if encoding, name := charset.Lookup(contentTypeCharset); encoding != nil {
+		return encoding, name
+	}
+
+	if isXMLMediaType(mediaType) {
+		if match := xmlEncodingDeclarationPattern.FindSubmatch(preview); match != nil {
+			if encoding, name := charset.Lookup(string(match[1])); encoding != nil {
+				return encoding, name
+			}
+		}
+	}
+
+	if mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+		// DetermineEncoding falls back to windows-1252 if there is no <meta> declaration, so
+		// only trust that answer if the document does mention a character set.
+		encoding, name, _ := charset.DetermineEncoding(preview, "")
+		if name != "windows-1252" || bytes.Contains(bytes.ToLower(preview), []byte("charset")) {
+			return encoding, name
+		}
+	}
+
+	if looksLikeUTF8(preview) {
+		return unicode.UTF8, "utf-8"
+	}
+
+	encoding, name := charset.Lookup("windows-1252")
+	return encoding, name
+}
+
+func looksLikeUTF8(preview []byte) bool {
+	// Ignore a rune that might have been cut off at the end of the preview
+	for i := len(preview) - 1; i >= 0 && i > len(preview)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(preview[i]) {
+			if !utf8.FullRune(preview[i:]) {
+				preview = preview[:i]
+			}
+			break
+		}
+	}
+
+	return utf8.Valid(preview)
+}
+
+func skipUTF8ByteOrderMark(bufferedBody *bufio.Reader) io.Reader {
+	if prefix, err := bufferedBody.Peek(3); err == nil && bytes.Equal(prefix, []byte{0xef, 0xbb, 0xbf}) {
+		_, _ = bufferedBody.Discard(3)
+	}
+
+	return bufferedBody
+}
+
+func isTextMediaType(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/") || isXMLMediaType(mediaType) ||
+		mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func isXMLMediaType(mediaType string) bool {
+	return mediaType == "application/xml" || strings.HasSuffix(mediaType, "+xml")
+}
@@ -0,0 +1,76 @@
+package linkextractor
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+const acceptEncoding = "gzip, deflate, br"
+
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (reader *countingReader) Read(p []byte) (int, error) {
+	n, err := reader.reader.Read(p)
+	reader.count += int64(n)
+	return n, err
+}
+
+// decompress undoes the content codings listed in a Content-Encoding header, in reverse order of
+// application.
+func decompress(body io.Reader, contentEncoding string) (io.Reader, error) {
+	codings := strings.Split(contentEncoding, ",")
+
+	for i := len(codings) - 1; i >= 0; i-- {
+		var err error
+
+		switch coding := strings.ToLower(strings.TrimSpace(codings[i])); coding {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			body, err = gzip.NewReader(body)
+		case "deflate":
+			body, err = newDeflateReader(body)
+		case "br":
+			body = brotli.NewReader(body)
+		default:
+			return nil, fmt.Errorf("Unsupported content encoding: %s", coding)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decompress response body: %w", err)
+		}
+	}
+
+	return body, nil
+}
+
+// newDeflateReader handles "deflate" as specified (zlib format), but also the raw DEFLATE data
+// that some servers send instead.
+func newDeflateReader(body io.Reader) (io.Reader, error) {
+	bufferedBody := bufio.NewReader(body)
+
+	header, err := bufferedBody.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+
+	if looksLikeZlibHeader(header) {
+		return zlib.NewReader(bufferedBody)
+	}
+
+	return flate.NewReader(bufferedBody), nil
+}
+
+func looksLikeZlibHeader(header []byte) bool {
+	compressionMethod := header[0] & 0x0f
+	return compressionMethod == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
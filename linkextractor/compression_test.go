@@ -0,0 +1,110 @@
+package linkextractor
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+func TestHTTPClient_ExtractPage_decompresses(t *testing.T) {
+	body := `<html><body>` + strings.Repeat(`<p>Lorem ipsum dolor sit amet.</p>`, 100) + `<a href="/one">One</a></body></html>`
+
+	tests := []struct {
+		name            string
+		contentEncoding string
+		compress        func(w io.Writer) io.WriteCloser
+		wantErr         bool
+	}{
+		{
+			name:            "no compression",
+			contentEncoding: "",
+			compress:        nopWriteCloser,
+		},
+		{
+			name:            "gzip",
+			contentEncoding: "gzip",
+			compress:        func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		},
+		{
+			name:            "deflate",
+			contentEncoding: "deflate",
+			compress:        func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+		},
+		{
+			name:            "raw deflate",
+			contentEncoding: "deflate",
+			compress: func(w io.Writer) io.WriteCloser {
+				writer, _ := flate.NewWriter(w, flate.DefaultCompression)
+				return writer
+			},
+		},
+		{
+			name:            "brotli",
+			contentEncoding: "br",
+			compress:        func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) },
+		},
+		{
+			name:            "unknown encoding",
+			contentEncoding: "compress",
+			compress:        nopWriteCloser,
+			wantErr:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var compressed bytes.Buffer
+			writer := tt.compress(&compressed)
+			_, _ = writer.Write([]byte(body))
+			writer.Close()
+
+			client := HTTPClient{
+				Do: func(req *http.Request) (*http.Response, error) {
+					if req.Header.Get("Accept-Encoding") != "gzip, deflate, br" {
+						t.Errorf("Accept-Encoding = %v", req.Header.Get("Accept-Encoding"))
+					}
+					return &http.Response{
+						Status:     http.StatusText(http.StatusOK),
+						StatusCode: http.StatusOK,
+						Header:     map[string][]string{"Content-Type": {"text/html"}, "Content-Encoding": {tt.contentEncoding}},
+						Body:       ioutil.NopCloser(bytes.NewReader(compressed.Bytes())),
+					}, nil
+				},
+			}
+
+			got, err := client.ExtractPage(crawlertest.MakeURL("https://example.com/"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HTTPClient.ExtractPage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if want := []url.URL{crawlertest.MakeURL("https://example.com/one")}; !reflect.DeepEqual(got.Links, want) {
+				t.Errorf("HTTPClient.ExtractPage() links = %v, want %v", got.Links, want)
+			}
+			if got.WireBytes != int64(compressed.Len()) || got.DecodedBytes != int64(len(body)) {
+				t.Errorf("HTTPClient.ExtractPage() bytes = %v on the wire, %v decoded, want %v, %v", got.WireBytes, got.DecodedBytes, compressed.Len(), len(body))
+			}
+		})
+	}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func nopWriteCloser(w io.Writer) io.WriteCloser {
+	return nopCloser{w}
+}
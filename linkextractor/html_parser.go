@@ -0,0 +1,43 @@
+package linkextractor
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// HTMLParser handles both HTML and XHTML, as the HTML parser is lenient enough to deal with the
+// latter.
+type HTMLParser struct{}
+
+func (HTMLParser) Parse(pageURL url.URL, body io.Reader) (Document, error) {
+	document, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return Document{}, fmt.Errorf("Failed to parse response body: %w", err)
+	}
+
+	result := make([]url.URL, 0)
+	details := make([]LinkDetails, 0)
+
+	document.Find("a").Each(func(index int, selection *goquery.Selection) {
+		href, hrefExists := selection.Attr("href")
+		if hrefExists {
+			parsedUrl, err := pageURL.Parse(strings.TrimSpace(href))
+			if err == nil {
+				rel, _ := selection.Attr("rel")
+				result = append(result, *parsedUrl)
+				details = append(details, LinkDetails{Text: collapseWhitespace(selection.Text()), Element: "a", Rel: collapseWhitespace(rel)})
+			}
+		}
+	})
+
+	return Document{Links: result, LinkDetails: details, Title: collapseWhitespace(document.Find("title").First().Text())}, nil
+}
+
+// collapseWhitespace trims text and replaces any runs of whitespace in it with a single space.
+func collapseWhitespace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,124 @@
+package linkextractor
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+func TestHTMLParsers_Parse(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantLinks []string
+		wantTitle string
+	}{
+		{
+			name:      "simple page",
+			body:      `<html><body><a href="/one">One</a> <A HREF="two">Two</A> <a name="anchor">No href</a></body></html>`,
+			wantLinks: []string{"https://example.com/one", "https://example.com/about/two"},
+		},
+		{
+			name: "links in scripts and comments are ignored",
+			body: `<html><head><script>document.write('<a href="/script">')</script></head>
+			<body><!-- <a href="/comment"> --><a class="x" href="/three?a=1&amp;b=2">Three</a></body></html>`,
+			wantLinks: []string{"https://example.com/three?a=1&b=2"},
+		},
+		{
+			name:      "unclosed tags and self-closing links",
+			body:      `<p><a href="/four"><p><a href="/five"/>`,
+			wantLinks: []string{"https://example.com/four", "https://example.com/five"},
+		},
+		{
+			name: "whitespace around hrefs is ignored",
+			body: `<a href=" /six ">Six</a> <a href="
+			  seven	">Seven</a> <a href="  ">Here</a>`,
+			wantLinks: []string{"https://example.com/six", "https://example.com/about/seven", "https://example.com/about/"},
+		},
+		{
+			name: "titles",
+			body: `<html><head><title>
+			  Caf&eacute; &amp; <b>more</b>  </title></head><body><svg><title>Icon</title></svg><a href="/eight">Eight</a></body></html>`,
+			wantLinks: []string{"https://example.com/eight"},
+			wantTitle: "Café & <b>more</b>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, parser := range []Parser{HTMLParser{}, HTMLTokenizerParser{}} {
+				got, err := parser.Parse(crawlertest.MakeURL("https://example.com/about/"), strings.NewReader(tt.body))
+				if err != nil {
+					t.Fatalf("%T.Parse() error = %v", parser, err)
+				}
+
+				gotLinks := make([]string, 0, len(got.Links))
+				for _, link := range got.Links {
+					gotLinks = append(gotLinks, link.String())
+				}
+				if !reflect.DeepEqual(gotLinks, tt.wantLinks) || got.Title != tt.wantTitle {
+					t.Errorf("%T.Parse() = %v with title %q, want %v with title %q", parser, gotLinks, got.Title, tt.wantLinks, tt.wantTitle)
+				}
+			}
+		})
+	}
+}
+
+func TestHTMLParsers_linkDetails(t *testing.T) {
+	body := `<p><a href="/one" rel=" nofollow
+	noopener">One <span>and  a half</span></a> <a href="/two"><img alt="Two"></a> <a href="/three">Three`
+	want := []LinkDetails{
+		{Text: "One and a half", Element: "a", Rel: "nofollow noopener"},
+		{Element: "a"},
+		{Text: "Three", Element: "a"},
+	}
+
+	for _, parser := range []Parser{HTMLParser{}, HTMLTokenizerParser{}} {
+		document, err := parser.Parse(crawlertest.MakeURL("https://example.com/"), strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("%T.Parse() error = %v", parser, err)
+		}
+		if !reflect.DeepEqual(document.LinkDetails, want) {
+			t.Errorf("%T.Parse() link details = %v, want %v", parser, document.LinkDetails, want)
+		}
+	}
+}
+
+func BenchmarkHTMLParser_Parse(b *testing.B) {
+	benchmarkParser(b, HTMLParser{})
+}
+
+func BenchmarkHTMLTokenizerParser_Parse(b *testing.B) {
+	benchmarkParser(b, HTMLTokenizerParser{})
+}
+
+func benchmarkParser(b *testing.B, parser Parser) {
+	body := largeHTMLPage(5000)
+	pageURL := crawlertest.MakeURL("https://example.com/")
+
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := parser.Parse(pageURL, strings.NewReader(body)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func largeHTMLPage(sections int) string {
+	var builder strings.Builder
+	builder.WriteString("<!DOCTYPE html><html><head><title>Large page</title></head><body>")
+
+	for i := 0; i < sections; i++ {
+		fmt.Fprintf(&builder, `<div class="section"><h2 id="s%d">Section %d</h2>`, i, i)
+		fmt.Fprintf(&builder, `<p>Some <em>text</em> with a <a href="/pages/%d">link</a> and <a href="https://example.org/%d#top">another</a>.</p>`, i, i)
+		builder.WriteString(`<table><tr><td>1</td><td>2</td><td><img src="/image.png" alt=""></td></tr></table></div>`)
+	}
+
+	builder.WriteString("</body></html>")
+	return builder.String()
+}
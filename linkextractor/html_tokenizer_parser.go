@@ -0,0 +1,86 @@
+package linkextractor
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// HTMLTokenizerParser finds the same links as HTMLParser, but looks at one tag at a time instead of
+// building a DOM first. This makes it faster and keeps memory use flat for very large pages.
+type HTMLTokenizerParser struct{}
+
+func (HTMLTokenizerParser) Parse(pageURL url.URL, body io.Reader) (Document, error) {
+	tokenizer := html.NewTokenizer(body)
+	result := make([]url.URL, 0)
+	details := make([]LinkDetails, 0)
+	var title, linkText strings.Builder
+	inTitle, titleSeen, inLink := false, false, false
+
+	endLink := func() {
+		if inLink {
+			details[len(details)-1].Text = collapseWhitespace(linkText.String())
+			linkText.Reset()
+			inLink = false
+		}
+	}
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return Document{}, fmt.Errorf("Failed to parse response body: %w", err)
+			}
+			endLink()
+			return Document{Links: result, LinkDetails: details, Title: collapseWhitespace(title.String())}, nil
+		case html.TextToken:
+			switch {
+			case inTitle:
+				title.Write(tokenizer.Text())
+			case inLink:
+				linkText.Write(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch {
+			case strings.EqualFold(string(name), "title"):
+				inTitle = false
+			case strings.EqualFold(string(name), "a"):
+				endLink()
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttributes := tokenizer.TagName()
+			if strings.EqualFold(string(name), "title") && !titleSeen {
+				inTitle, titleSeen = true, true
+			}
+			if !strings.EqualFold(string(name), "a") {
+				continue
+			}
+			endLink()
+
+			href, hrefExists, rel := "", false, ""
+			for hasAttributes {
+				var key, value []byte
+				key, value, hasAttributes = tokenizer.TagAttr()
+				switch {
+				case strings.EqualFold(string(key), "href") && !hrefExists:
+					href, hrefExists = string(value), true
+				case strings.EqualFold(string(key), "rel") && rel == "":
+					rel = collapseWhitespace(string(value))
+				}
+			}
+
+			if !hrefExists {
+				continue
+			}
+			if parsedURL, err := pageURL.Parse(strings.TrimSpace(href)); err == nil {
+				result = append(result, *parsedURL)
+				details = append(details, LinkDetails{Element: "a", Rel: rel})
+				inLink = true
+			}
+		}
+	}
+}
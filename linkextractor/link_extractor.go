@@ -1,81 +1,264 @@
 package linkextractor
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
-
-	"github.com/PuerkitoBio/goquery"
+	"time"
 )
 
+const defaultUserAgent = "Mozilla/5.0 (compatible; sitemapper)"
+
 type HTTPClient struct {
-	Do func(req *http.Request) (*http.Response, error)
+	Do           func(req *http.Request) (*http.Response, error)
+	UserAgent    string
+	RobotsToken  string       // product token to match robots.txt groups against; derived from UserAgent if empty
+	Robots       *RobotsCache // if set, pages that robots.txt disallows are not fetched
+	From         string
+	Header       http.Header
+	RetryPolicy  RetryPolicy
+	Parsers      *Registry
+	MaxBodyBytes int64
 }
 
 type LinkExtractor interface {
 	ExtractLinks(URL url.URL) ([]url.URL, error)
 }
 
+type Page struct {
+	Links        []url.URL
+	LinkDetails  []LinkDetails // either empty, or the details of each of Links, in the same order
+	Title        string
+	ContentType  string
+	StatusCode   int // of the last response, or zero if no response was received
+	Retries      int
+	Charset      string
+	Truncated    bool
+	WireBytes    int64
+	DecodedBytes int64
+}
+
+type PageExtractor interface {
+	ExtractPage(URL url.URL) (Page, error)
+}
+
+type RetryAttempt struct {
+	URL     url.URL
+	Retries int // number of retries so far, including this one
+	Delay   time.Duration
+	Err     error
+}
+
+// A RetryObservingPageExtractor calls onRetry each time it is about to wait and try a page again.
+type RetryObservingPageExtractor interface {
+	ExtractPageObservingRetries(URL url.URL, onRetry func(RetryAttempt)) (Page, error)
+}
+
+// A ContextPageExtractor is a RetryObservingPageExtractor that gives up on a page, including any
+// request in flight for it, once ctx is done.
+type ContextPageExtractor interface {
+	ExtractPageWithContext(ctx context.Context, URL url.URL, onRetry func(RetryAttempt)) (Page, error)
+}
+
+func UserAgentForVersion(version string) string {
+	return fmt.Sprintf("Mozilla/5.0 (compatible; sitemapper/%s)", version)
+}
+
 func (client HTTPClient) ExtractLinks(URL url.URL) ([]url.URL, error) {
-	request, err := http.NewRequest("GET", URL.String(), nil)
+	page, err := client.ExtractPage(URL)
+	return page.Links, err
+}
+
+func (client HTTPClient) ExtractPage(URL url.URL) (Page, error) {
+	return client.ExtractPageObservingRetries(URL, nil)
+}
+
+func (client HTTPClient) ExtractPageObservingRetries(URL url.URL, onRetry func(RetryAttempt)) (Page, error) {
+	return client.ExtractPageWithContext(context.Background(), URL, onRetry)
+}
+
+// ExtractPageWithContext is like ExtractPageObservingRetries, but aborts the request in flight, or
+// the wait before a retry, once ctx is done.
+func (client HTTPClient) ExtractPageWithContext(ctx context.Context, URL url.URL, onRetry func(RetryAttempt)) (Page, error) {
+	page := Page{Links: []url.URL{}}
+
+	if client.Robots != nil && !client.robotsAllowed(URL) {
+		return page, ErrDisallowedByRobots
+	}
+
+	for {
+		attempt, err := client.extractPageOnce(ctx, URL)
+		page.WireBytes += attempt.WireBytes
+		page.DecodedBytes += attempt.DecodedBytes
+
+		if err == nil {
+			attempt.Retries = page.Retries
+			attempt.WireBytes = page.WireBytes
+			attempt.DecodedBytes = page.DecodedBytes
+			return attempt, nil
+		}
+
+		delay, retry := client.RetryPolicy.delayBeforeRetry(page.Retries, err)
+		if !retry || ctx.Err() != nil {
+			page.ContentType = attempt.ContentType
+			page.StatusCode = attempt.StatusCode
+			return page, err
+		}
+
+		page.Retries++
+		if onRetry != nil {
+			onRetry(RetryAttempt{URL: URL, Retries: page.Retries, Delay: delay, Err: err})
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return page, ctx.Err()
+		}
+	}
+}
+
+func (client HTTPClient) extractPageOnce(ctx context.Context, URL url.URL) (page Page, err error) {
+	page = Page{Links: []url.URL{}}
+
+	request, err := http.NewRequestWithContext(ctx, "GET", URL.String(), nil)
 	if err != nil {
-		return []url.URL{}, err
+		return page, err
 	}
 
-	request.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml")
+	request.Header.Set("Accept", strings.Join(client.parsers().MediaTypes(), ","))
+	request.Header.Set("Accept-Encoding", acceptEncoding)
 	request.Header.Set("Cache-Control", "no-cache")
-	request.Header.Set("User-Agent", "Mozilla/5.0 (compatible; sitemapper/0.1)")
+	request.Header.Set("User-Agent", client.userAgent())
+
+	if client.From != "" {
+		request.Header.Set("From", client.From)
+	}
+
+	for name, values := range client.Header {
+		request.Header[name] = append([]string(nil), values...)
+	}
 
 	response, err := client.Do(request)
 	if err != nil {
-		return []url.URL{}, fmt.Errorf("GET request failed: %s", err)
+		return page, fmt.Errorf("GET request failed: %w", err)
 	}
 	defer response.Body.Close()
 
-	switch {
-	case response.StatusCode != http.StatusOK:
-		return []url.URL{}, fmt.Errorf("Got a %s response", response.Status)
-	case !strings.HasPrefix(response.Header.Get("Content-Type"), "text/html"):
-		return []url.URL{}, fmt.Errorf("Content type is not HTML")
+	page.StatusCode = response.StatusCode
+	if response.StatusCode != http.StatusOK {
+		return page, newStatusError(response)
 	}
 
-	return extractLinksFromBody(URL, response.Body)
-}
+	mediaType, params, err := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if err != nil {
+		return page, fmt.Errorf("%w: %s", ErrUnsupportedContentType, response.Header.Get("Content-Type"))
+	}
+
+	page.ContentType = mediaType
+
+	parser, ok := client.parsers().Lookup(mediaType)
+	if !ok {
+		return page, fmt.Errorf("%w: %s", ErrUnsupportedContentType, mediaType)
+	}
+
+	wireBody := &countingReader{reader: response.Body}
+	defer func() { page.WireBytes = wireBody.count }()
 
-func extractLinksFromBody(URL url.URL, readCloser io.ReadCloser) ([]url.URL, error) {
-	document, err := goquery.NewDocumentFromReader(readCloser)
+	decompressedBody, err := decompress(wireBody, response.Header.Get("Content-Encoding"))
 	if err != nil {
-		return []url.URL{}, fmt.Errorf("Failed to parse response body: %s", err)
+		return page, err
 	}
 
-	result := make([]url.URL, 0)
+	decodedBody := &countingReader{reader: decompressedBody}
+	defer func() { page.DecodedBytes = decodedBody.count }()
 
-	document.Find("a").Each(func(index int, selection *goquery.Selection) {
-		href, hrefExists := selection.Attr("href")
-		if hrefExists {
-			parsedUrl, err := URL.Parse(href)
-			if err == nil {
-				parsedUrl.Fragment = ""
-				result = append(result, *parsedUrl)
-			}
-		}
-	})
+	var rawBody io.Reader = decodedBody
+	var truncatingBody *truncatingReader
+	if client.MaxBodyBytes > 0 {
+		truncatingBody = newTruncatingReader(decodedBody, client.MaxBodyBytes)
+		rawBody = truncatingBody
+	}
+
+	body, charset, err := decodeToUTF8(rawBody, mediaType, params["charset"])
+	if err != nil {
+		return page, fmt.Errorf("Failed to read response body: %w", err)
+	}
+	page.Charset = charset
+
+	document, err := parser.Parse(URL, body)
+	if err != nil {
+		return page, err
+	}
+
+	page.Truncated = truncatingBody != nil && truncatingBody.truncated
+
+	page.Links, page.LinkDetails = removeFragmentsAndDuplicates(URL, document.Links, document.LinkDetails)
+	page.Title = document.Title
+	return page, nil
+}
+
+func (client HTTPClient) parsers() *Registry {
+	if client.Parsers == nil {
+		return defaultRegistry
+	}
+
+	return client.Parsers
+}
 
-	return removeDuplicates(URL, result), nil
+func (client HTTPClient) userAgent() string {
+	if client.UserAgent == "" {
+		return defaultUserAgent
+	}
+
+	return client.UserAgent
 }
 
-func removeDuplicates(pageURL url.URL, linkURLs []url.URL) []url.URL {
+func newStatusError(response *http.Response) *StatusError {
+	result := &StatusError{StatusCode: response.StatusCode, Status: response.Status}
+
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		result.RetryAfter = parseRetryAfter(response.Header.Get("Retry-After"), time.Now())
+	}
+
+	return result
+}
+
+// removeFragmentsAndDuplicates also returns the details of the links that are kept, if there are any.
+// A link that appears more than once keeps the details of its first appearance.
+func removeFragmentsAndDuplicates(pageURL url.URL, linkURLs []url.URL, details []LinkDetails) ([]url.URL, []LinkDetails) {
 	seen := map[url.URL]bool{}
 	result := make([]url.URL, 0)
+	var resultDetails []LinkDetails
+	if len(details) > 0 {
+		resultDetails = make([]LinkDetails, 0, len(details))
+	}
+
+	for i, linkURL := range linkURLs {
+		linkURL.Fragment = ""
+		linkURL.RawFragment = ""
+
+		// Parsing the URL's string representation again turns, say, /café and /caf%C3%A9 into the
+		// same url.URL, so they are not treated as different pages.
+		if canonicalURL, err := url.Parse(linkURL.String()); err == nil {
+			linkURL = *canonicalURL
+		}
 
-	for _, linkURL := range linkURLs {
 		if linkURL != pageURL && !seen[linkURL] {
 			seen[linkURL] = true
 			result = append(result, linkURL)
+			if resultDetails != nil {
+				resultDetails = append(resultDetails, details[i])
+			}
 		}
 	}
 
-	return result
+	return result, resultDetails
 }
@@ -10,6 +10,10 @@ import (
 	"testing"
 
 	"github.com/hilverd/sitemapper/crawlertest"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
 )
 
 func TestHTTPClient_ExtractLinks(t *testing.T) {
@@ -51,6 +55,23 @@ func TestHTTPClient_ExtractLinks(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "XHTML is parsed like HTML",
+			fields: fields{
+				Do: stubHttpClientDo(http.StatusOK, "application/xhtml+xml; charset=utf-8", `<?xml version="1.0" encoding="UTF-8"?>
+				<html xmlns="http://www.w3.org/1999/xhtml">
+				  <body><p><a href="/contact">Contact</a></p></body>
+				</html>
+			`),
+			},
+			args: args{
+				URL: crawlertest.MakeURL("https://example.com/about/"),
+			},
+			want: []url.URL{
+				crawlertest.MakeURL("https://example.com/contact"),
+			},
+			wantErr: false,
+		},
 		{
 			name: "no response means no links are returned",
 			fields: fields{
@@ -111,7 +132,7 @@ func TestHTTPClient_ExtractLinks(t *testing.T) {
 func stubHttpClientDo(statusCode int, contentType string, responseBody string) func(req *http.Request) (*http.Response, error) {
 	return func(req *http.Request) (*http.Response, error) {
 		switch {
-		case len(req.Header["Accept"]) == 0 || req.Header["Accept"][0] != "text/html,application/xhtml+xml,application/xml":
+		case len(req.Header["Accept"]) == 0 || !strings.Contains(req.Header["Accept"][0], "/"):
 			return makeHttpResponse(statusCode, contentType, `
 				<html><body><a href="/expected-accept-header-not-present">Error</a></body></html>
 				`)
@@ -119,7 +140,7 @@ func stubHttpClientDo(statusCode int, contentType string, responseBody string) f
 			return makeHttpResponse(statusCode, contentType, `
 				<html><body><a href="/expected-cache-control-header-not-present">Error</a></body></html>
 				`)
-		case len(req.Header["User-Agent"]) == 0 || req.Header["User-Agent"][0] != "Mozilla/5.0 (compatible; sitemapper/0.1)":
+		case len(req.Header["User-Agent"]) == 0 || req.Header["User-Agent"][0] != "Mozilla/5.0 (compatible; sitemapper)":
 			return makeHttpResponse(statusCode, contentType, `
 					<html><body><a href="/expected-user-agent-header-not-present">Error</a></body></html>
 					`)
@@ -137,3 +158,283 @@ func makeHttpResponse(statusCode int, contentType string, responseBody string) (
 		Body:       ioutil.NopCloser(strings.NewReader(responseBody)),
 	}, nil
 }
+
+func TestHTTPClient_ExtractLinks_sendsHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		from      string
+		header    http.Header
+		parsers   *Registry
+		want      http.Header
+	}{
+		{
+			name: "extra headers are added and can override the defaults",
+			header: http.Header{
+				"Authorization": {"Bearer token"},
+				"Accept":        {"text/html"},
+			},
+			want: http.Header{
+				"Authorization":   {"Bearer token"},
+				"Accept":          {"text/html"},
+				"Accept-Encoding": {"gzip, deflate, br"},
+				"Cache-Control":   {"no-cache"},
+				"User-Agent":      {"Mozilla/5.0 (compatible; sitemapper)"},
+			},
+		},
+		{
+			name:      "user agent and From header can be configured",
+			userAgent: "ExampleBot/1.0 (+https://example.com/bot)",
+			from:      "bot@example.com",
+			want: http.Header{
+				"Accept":          {"application/atom+xml,application/rss+xml,application/xhtml+xml,application/xml,text/html,text/plain,text/xml"},
+				"Accept-Encoding": {"gzip, deflate, br"},
+				"Cache-Control":   {"no-cache"},
+				"User-Agent":      {"ExampleBot/1.0 (+https://example.com/bot)"},
+				"From":            {"bot@example.com"},
+			},
+		},
+		{
+			name:    "Accept header lists the media types that have parsers",
+			parsers: registryFor("text/html", "application/json"),
+			want: http.Header{
+				"Accept":          {"application/json,text/html"},
+				"Accept-Encoding": {"gzip, deflate, br"},
+				"Cache-Control":   {"no-cache"},
+				"User-Agent":      {"Mozilla/5.0 (compatible; sitemapper)"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got http.Header
+			client := HTTPClient{
+				Do: func(req *http.Request) (*http.Response, error) {
+					got = req.Header
+					return makeHttpResponse(http.StatusOK, "text/html", "<html></html>")
+				},
+				UserAgent: tt.userAgent,
+				From:      tt.from,
+				Header:    tt.header,
+				Parsers:   tt.parsers,
+			}
+			_, _ = client.ExtractLinks(crawlertest.MakeURL("https://example.com/"))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("request headers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func registryFor(mediaTypes ...string) *Registry {
+	registry := NewRegistry()
+	for _, mediaType := range mediaTypes {
+		registry.Register(mediaType, TextParser{})
+	}
+	return registry
+}
+
+func TestHTTPClient_RobotsMatchingToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		userAgent   string
+		robotsToken string
+		want        string
+	}{
+		{
+			name: "default user agent",
+			want: "sitemapper",
+		},
+		{
+			name:      "compatible user agent with a version",
+			userAgent: "Mozilla/5.0 (compatible; sitemapper/1.2.0)",
+			want:      "sitemapper",
+		},
+		{
+			name:      "user agent with a contact URL",
+			userAgent: "ExampleBot/1.0 (+https://example.com/bot)",
+			want:      "ExampleBot",
+		},
+		{
+			name:      "token with digits",
+			userAgent: "S3Crawler/2.1",
+			want:      "S3Crawler",
+		},
+		{
+			name:        "token set explicitly",
+			userAgent:   "ExampleBot/1.0 (+https://example.com/bot)",
+			robotsToken: "example-crawler",
+			want:        "example-crawler",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := HTTPClient{UserAgent: tt.userAgent, RobotsToken: tt.robotsToken}
+			if got := client.RobotsMatchingToken(); got != tt.want {
+				t.Errorf("RobotsMatchingToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPClient_ExtractPage_decodesCharacterSets(t *testing.T) {
+	type args struct {
+		contentType string
+		body        []byte
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantPaths   []string
+		wantCharset string
+	}{
+		{
+			name: "charset from the Content-Type header",
+			args: args{
+				contentType: "text/html; charset=Shift_JIS",
+				body:        encode(japanese.ShiftJIS, `<html><body><a href="/製品/">製品</a></body></html>`),
+			},
+			wantPaths:   []string{"/製品/"},
+			wantCharset: "shift_jis",
+		},
+		{
+			name: "charset from a meta element",
+			args: args{
+				contentType: "text/html",
+				body:        encode(charmap.Windows1252, `<html><head><meta charset="windows-1252"></head><body><a href="/café">Café</a></body></html>`),
+			},
+			wantPaths:   []string{"/café"},
+			wantCharset: "windows-1252",
+		},
+		{
+			name: "charset from a meta http-equiv element",
+			args: args{
+				contentType: "text/html",
+				body:        encode(charmap.ISO8859_2, `<html><head><meta http-equiv="Content-Type" content="text/html; charset=iso-8859-2"></head><body><a href="/łódź">Łódź</a></body></html>`),
+			},
+			wantPaths:   []string{"/łódź"},
+			wantCharset: "iso-8859-2",
+		},
+		{
+			name: "byte order mark takes precedence over the Content-Type header",
+			args: args{
+				contentType: "text/html; charset=iso-8859-1",
+				body:        encode(unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), `<html><body><a href="/ñandú">Ñandú</a></body></html>`),
+			},
+			wantPaths:   []string{"/ñandú"},
+			wantCharset: "utf-16le",
+		},
+		{
+			name: "UTF-8 byte order mark",
+			args: args{
+				contentType: "text/html",
+				body:        append([]byte{0xef, 0xbb, 0xbf}, []byte(`<html><body><a href="/über">Über</a></body></html>`)...),
+			},
+			wantPaths:   []string{"/über"},
+			wantCharset: "utf-8",
+		},
+		{
+			name: "undeclared UTF-8",
+			args: args{
+				contentType: "text/html",
+				body:        []byte(`<html><body><a href="/naïve">Naïve</a></body></html>`),
+			},
+			wantPaths:   []string{"/naïve"},
+			wantCharset: "utf-8",
+		},
+		{
+			name: "undeclared and not UTF-8 falls back to windows-1252",
+			args: args{
+				contentType: "text/html",
+				body:        encode(charmap.Windows1252, `<html><body><a href="/façade">Façade</a></body></html>`),
+			},
+			wantPaths:   []string{"/façade"},
+			wantCharset: "windows-1252",
+		},
+		{
+			name: "encoding declared in an XML feed",
+			args: args{
+				contentType: "application/rss+xml",
+				body:        encode(charmap.ISO8859_15, `<?xml version="1.0" encoding="ISO-8859-15"?><rss><channel><item><link>https://example.com/€</link></item></channel></rss>`),
+			},
+			wantPaths:   []string{"/€"},
+			wantCharset: "iso-8859-15",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := HTTPClient{
+				Do: func(req *http.Request) (*http.Response, error) {
+					return makeHttpResponse(http.StatusOK, tt.args.contentType, string(tt.args.body))
+				},
+			}
+			got, err := client.ExtractPage(crawlertest.MakeURL("https://example.com/"))
+			if err != nil {
+				t.Fatalf("HTTPClient.ExtractPage() error = %v", err)
+			}
+			gotPaths := make([]string, 0)
+			for _, link := range got.Links {
+				gotPaths = append(gotPaths, link.Path)
+			}
+			if !reflect.DeepEqual(gotPaths, tt.wantPaths) {
+				t.Errorf("HTTPClient.ExtractPage() link paths = %v, want %v", gotPaths, tt.wantPaths)
+			}
+			if got.Charset != tt.wantCharset {
+				t.Errorf("HTTPClient.ExtractPage() charset = %v, want %v", got.Charset, tt.wantCharset)
+			}
+		})
+	}
+}
+
+func encode(encoding encoding.Encoding, s string) []byte {
+	result, err := encoding.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+func TestHTTPClient_ExtractPage_limitsBodySize(t *testing.T) {
+	body := `<html><body><a href="/one">One</a><a href="/two">Two</a></body></html>`
+
+	tests := []struct {
+		name          string
+		maxBodyBytes  int64
+		wantLinks     []url.URL
+		wantTruncated bool
+	}{
+		{
+			name:          "no limit",
+			maxBodyBytes:  0,
+			wantLinks:     []url.URL{crawlertest.MakeURL("https://example.com/one"), crawlertest.MakeURL("https://example.com/two")},
+			wantTruncated: false,
+		},
+		{
+			name:          "body exactly at the limit",
+			maxBodyBytes:  int64(len(body)),
+			wantLinks:     []url.URL{crawlertest.MakeURL("https://example.com/one"), crawlertest.MakeURL("https://example.com/two")},
+			wantTruncated: false,
+		},
+		{
+			name:          "body over the limit is truncated",
+			maxBodyBytes:  int64(strings.Index(body, `<a href="/two">`)),
+			wantLinks:     []url.URL{crawlertest.MakeURL("https://example.com/one")},
+			wantTruncated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := HTTPClient{
+				Do:           stubHttpClientDo(http.StatusOK, "text/html", body),
+				MaxBodyBytes: tt.maxBodyBytes,
+			}
+			got, err := client.ExtractPage(crawlertest.MakeURL("https://example.com/"))
+			if err != nil {
+				t.Fatalf("HTTPClient.ExtractPage() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Links, tt.wantLinks) || got.Truncated != tt.wantTruncated {
+				t.Errorf("HTTPClient.ExtractPage() = %v (truncated: %v), want %v (truncated: %v)", got.Links, got.Truncated, tt.wantLinks, tt.wantTruncated)
+			}
+		})
+	}
+}
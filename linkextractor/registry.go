@@ -0,0 +1,92 @@
+package linkextractor
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrUnsupportedContentType = errors.New("Content type is not supported")
+
+type Document struct {
+	Links       []url.URL
+	LinkDetails []LinkDetails // either empty, or the details of each of Links, in the same order
+	Title       string
+}
+
+// LinkDetails describes where in a document a link was found.
+type LinkDetails struct {
+	Text    string // text of the link, with runs of whitespace collapsed
+	Element string // name of the element the link was found in, such as a or loc
+	Rel     string // relationship given by the link's rel attribute
+}
+
+type Parser interface {
+	Parse(pageURL url.URL, body io.Reader) (Document, error)
+}
+
+type ParserFunc func(pageURL url.URL, body io.Reader) (Document, error)
+
+func (parse ParserFunc) Parse(pageURL url.URL, body io.Reader) (Document, error) {
+	return parse(pageURL, body)
+}
+
+type Registry struct {
+	mutex   sync.RWMutex
+	parsers map[string]Parser
+}
+
+var defaultRegistry = NewDefaultRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{parsers: map[string]Parser{}}
+}
+
+func NewDefaultRegistry() *Registry {
+	registry := NewRegistry()
+	registry.Register("text/html", HTMLParser{})
+	registry.Register("application/xhtml+xml", HTMLParser{})
+	registry.Register("application/rss+xml", FeedParser{})
+	registry.Register("application/atom+xml", FeedParser{})
+	registry.Register("application/xml", XMLParser{})
+	registry.Register("text/xml", XMLParser{})
+	registry.Register("text/plain", TextParser{})
+	return registry
+}
+
+// Register makes a parser available for a media type (such as "application/json") to all
+// HTTPClients that do not have their own registry.
+func Register(mediaType string, parser Parser) {
+	defaultRegistry.Register(mediaType, parser)
+}
+
+func (registry *Registry) Register(mediaType string, parser Parser) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.parsers[strings.ToLower(mediaType)] = parser
+}
+
+func (registry *Registry) Lookup(mediaType string) (Parser, bool) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	parser, ok := registry.parsers[strings.ToLower(mediaType)]
+	return parser, ok
+}
+
+func (registry *Registry) MediaTypes() []string {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	result := make([]string, 0, len(registry.parsers))
+	for mediaType := range registry.parsers {
+		result = append(result, mediaType)
+	}
+
+	sort.Strings(result)
+	return result
+}
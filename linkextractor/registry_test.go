@@ -0,0 +1,82 @@
+package linkextractor
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+func TestRegistry_Lookup(t *testing.T) {
+	registry := NewDefaultRegistry()
+	registry.Register("Application/JSON", TextParser{})
+
+	tests := []struct {
+		name      string
+		mediaType string
+		want      Parser
+		wantOk    bool
+	}{
+		{
+			name:      "built-in parser",
+			mediaType: "application/xhtml+xml",
+			want:      HTMLParser{},
+			wantOk:    true,
+		},
+		{
+			name:      "registered parser, case-insensitively",
+			mediaType: "application/json",
+			want:      TextParser{},
+			wantOk:    true,
+		},
+		{
+			name:      "unknown media type",
+			mediaType: "image/png",
+			want:      nil,
+			wantOk:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotOk := registry.Lookup(tt.mediaType)
+			if gotOk != tt.wantOk || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Registry.Lookup() = %v, %v, want %v, %v", got, gotOk, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestHTTPClient_ExtractLinks_usesRegisteredParsers(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("application/x-link-list", ParserFunc(func(pageURL url.URL, body io.Reader) (Document, error) {
+		contents, _ := ioutil.ReadAll(body)
+		result := make([]url.URL, 0)
+		for _, line := range strings.Fields(string(contents)) {
+			result = appendLink(result, pageURL, line)
+		}
+		return Document{Links: result}, nil
+	}))
+
+	client := HTTPClient{
+		Do:      stubHttpClientDo(http.StatusOK, "application/x-link-list; version=2", "/a /b#top /a"),
+		Parsers: registry,
+	}
+
+	got, err := client.ExtractLinks(crawlertest.MakeURL("https://example.com/"))
+	if err != nil {
+		t.Fatalf("HTTPClient.ExtractLinks() error = %v", err)
+	}
+
+	want := []url.URL{
+		crawlertest.MakeURL("https://example.com/a"),
+		crawlertest.MakeURL("https://example.com/b"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("HTTPClient.ExtractLinks() = %v, want %v", got, want)
+	}
+}
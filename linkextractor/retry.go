@@ -0,0 +1,132 @@
+package linkextractor
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RetryPolicy struct {
+	MaxRetries             int
+	InitialBackoff         time.Duration
+	MaxBackoff             time.Duration
+	RetryStatusCodes       []int
+	RetryOnTimeout         bool
+	RetryOnConnectionError bool
+	HonourRetryAfter       bool
+	MaxRetryAfter          time.Duration
+}
+
+type StatusError struct {
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("Got a %s response", err.Status)
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:     2,
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     30 * time.Second,
+		RetryStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RetryOnTimeout:         true,
+		RetryOnConnectionError: true,
+		HonourRetryAfter:       true,
+		MaxRetryAfter:          2 * time.Minute,
+	}
+}
+
+func (policy RetryPolicy) delayBeforeRetry(retriesSoFar int, err error) (time.Duration, bool) {
+	if retriesSoFar >= policy.MaxRetries || !policy.shouldRetry(err) {
+		return 0, false
+	}
+
+	var statusError *StatusError
+	if errors.As(err, &statusError) && policy.HonourRetryAfter && statusError.RetryAfter > 0 {
+		if 0 < policy.MaxRetryAfter && policy.MaxRetryAfter < statusError.RetryAfter {
+			return 0, false
+		}
+		return statusError.RetryAfter, true
+	}
+
+	return policy.backoff(retriesSoFar), true
+}
+
+func (policy RetryPolicy) shouldRetry(err error) bool {
+	var statusError *StatusError
+	if errors.As(err, &statusError) {
+		for _, statusCode := range policy.RetryStatusCodes {
+			if statusCode == statusError.StatusCode {
+				return true
+			}
+		}
+		return false
+	}
+
+	var netError net.Error
+	if errors.As(err, &netError) && netError.Timeout() {
+		return policy.RetryOnTimeout
+	}
+
+	var urlError *url.Error
+	if errors.As(err, &urlError) {
+		return policy.RetryOnConnectionError
+	}
+
+	return false
+}
+
+func (policy RetryPolicy) backoff(retriesSoFar int) time.Duration {
+	delay := policy.InitialBackoff
+	for i := 0; i < retriesSoFar && (policy.MaxBackoff <= 0 || delay < policy.MaxBackoff); i++ {
+		delay *= 2
+	}
+
+	if 0 < policy.MaxBackoff && policy.MaxBackoff < delay {
+		delay = policy.MaxBackoff
+	}
+
+	if delay <= 1 {
+		return delay
+	}
+
+	// "Equal jitter": wait at least half the delay, plus a random part of the other half.
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)))
+}
+
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
@@ -0,0 +1,265 @@
+package linkextractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryPolicy_delayBeforeRetry(t *testing.T) {
+	policy := RetryPolicy{
+		MaxRetries:             2,
+		InitialBackoff:         time.Second,
+		MaxBackoff:             time.Minute,
+		RetryStatusCodes:       []int{http.StatusServiceUnavailable},
+		RetryOnTimeout:         true,
+		RetryOnConnectionError: false,
+		HonourRetryAfter:       true,
+		MaxRetryAfter:          time.Minute,
+	}
+
+	type args struct {
+		retriesSoFar int
+		err          error
+	}
+	tests := []struct {
+		name      string
+		args      args
+		wantMin   time.Duration
+		wantMax   time.Duration
+		wantRetry bool
+	}{
+		{
+			name:      "status codes in the policy are retried with backoff",
+			args:      args{0, &StatusError{StatusCode: http.StatusServiceUnavailable}},
+			wantMin:   500 * time.Millisecond,
+			wantMax:   time.Second,
+			wantRetry: true,
+		},
+		{
+			name:      "backoff grows exponentially",
+			args:      args{1, &StatusError{StatusCode: http.StatusServiceUnavailable}},
+			wantMin:   time.Second,
+			wantMax:   2 * time.Second,
+			wantRetry: true,
+		},
+		{
+			name:      "Retry-After replaces the backoff",
+			args:      args{0, &StatusError{StatusCode: http.StatusServiceUnavailable, RetryAfter: 10 * time.Second}},
+			wantMin:   10 * time.Second,
+			wantMax:   10 * time.Second,
+			wantRetry: true,
+		},
+		{
+			name:      "Retry-After longer than the maximum means giving up",
+			args:      args{0, &StatusError{StatusCode: http.StatusServiceUnavailable, RetryAfter: time.Hour}},
+			wantRetry: false,
+		},
+		{
+			name:      "status codes not in the policy are not retried",
+			args:      args{0, &StatusError{StatusCode: http.StatusNotFound}},
+			wantRetry: false,
+		},
+		{
+			name:      "no retries beyond the maximum",
+			args:      args{2, &StatusError{StatusCode: http.StatusServiceUnavailable}},
+			wantRetry: false,
+		},
+		{
+			name:      "timeouts are retried",
+			args:      args{0, &url.Error{Op: "Get", URL: "https://example.com/", Err: timeoutError{}}},
+			wantMin:   500 * time.Millisecond,
+			wantMax:   time.Second,
+			wantRetry: true,
+		},
+		{
+			name:      "connection errors are not retried if the policy says so",
+			args:      args{0, &url.Error{Op: "Get", URL: "https://example.com/", Err: fmt.Errorf("connection refused")}},
+			wantRetry: false,
+		},
+		{
+			name:      "other errors are not retried",
+			args:      args{0, fmt.Errorf("Content type is not HTML")},
+			wantRetry: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotRetry := policy.delayBeforeRetry(tt.args.retriesSoFar, tt.args.err)
+			if gotRetry != tt.wantRetry {
+				t.Errorf("RetryPolicy.delayBeforeRetry() retry = %v, want %v", gotRetry, tt.wantRetry)
+			}
+			if gotRetry && (got < tt.wantMin || got > tt.wantMax) {
+				t.Errorf("RetryPolicy.delayBeforeRetry() = %v, want between %v and %v", got, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{
+			name:  "delay in seconds",
+			value: "120",
+			want:  2 * time.Minute,
+		},
+		{
+			name:  "HTTP date",
+			value: "Tue, 01 Jun 2021 12:00:30 GMT",
+			want:  30 * time.Second,
+		},
+		{
+			name:  "HTTP date in the past",
+			value: "Tue, 01 Jun 2021 11:00:00 GMT",
+			want:  0,
+		},
+		{
+			name:  "invalid value",
+			value: "soon",
+			want:  0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value, now); got != tt.want {
+				t.Errorf("parseRetryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPClient_ExtractPage(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusCodes []int
+		want        Page
+		wantErr     bool
+		wantRetries []int
+	}{
+		{
+			name:        "page is retrieved after retries",
+			statusCodes: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+			want: Page{
+				Links:        []url.URL{crawlertest.MakeURL("https://example.com/main")},
+				LinkDetails:  []LinkDetails{{Text: "Main", Element: "a"}},
+				ContentType:  "text/html",
+				StatusCode:   http.StatusOK,
+				Retries:      2,
+				Charset:      "utf-8",
+				WireBytes:    49,
+				DecodedBytes: 49,
+			},
+			wantErr:     false,
+			wantRetries: []int{1, 2},
+		},
+		{
+			name:        "retries are recorded when giving up",
+			statusCodes: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK},
+			want: Page{
+				Links:      []url.URL{},
+				StatusCode: http.StatusServiceUnavailable,
+				Retries:    2,
+			},
+			wantErr:     true,
+			wantRetries: []int{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			client := HTTPClient{
+				Do: func(req *http.Request) (*http.Response, error) {
+					statusCode := tt.statusCodes[requests]
+					requests++
+					return &http.Response{
+						Status:     http.StatusText(statusCode),
+						StatusCode: statusCode,
+						Header:     map[string][]string{"Content-Type": {"text/html"}, "Retry-After": {"0"}},
+						Body:       ioutil.NopCloser(strings.NewReader(`<html><body><a href="main">Main</a></body></html>`)),
+					}, nil
+				},
+				RetryPolicy: RetryPolicy{
+					MaxRetries:       2,
+					InitialBackoff:   time.Millisecond,
+					RetryStatusCodes: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+					HonourRetryAfter: true,
+				},
+			}
+			gotRetries := []int{}
+			got, err := client.ExtractPageObservingRetries(crawlertest.MakeURL("https://example.com/"), func(attempt RetryAttempt) {
+				gotRetries = append(gotRetries, attempt.Retries)
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HTTPClient.ExtractPage() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("HTTPClient.ExtractPage() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(gotRetries, tt.wantRetries) {
+				t.Errorf("HTTPClient.ExtractPage() retried %v, want %v", gotRetries, tt.wantRetries)
+			}
+		})
+	}
+}
+
+func TestHTTPClient_ExtractPageWithContext_stopsWhenCancelled(t *testing.T) {
+	tests := []struct {
+		name string
+		do   func(req *http.Request) (*http.Response, error)
+	}{
+		{
+			name: "request in flight",
+			do: func(req *http.Request) (*http.Response, error) {
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			},
+		},
+		{
+			name: "waiting to retry",
+			do: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					Status:     http.StatusText(http.StatusServiceUnavailable),
+					StatusCode: http.StatusServiceUnavailable,
+					Header:     map[string][]string{"Retry-After": {"3600"}},
+					Body:       ioutil.NopCloser(strings.NewReader("")),
+				}, nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			client := HTTPClient{Do: tt.do, RetryPolicy: DefaultRetryPolicy()}
+			client.RetryPolicy.MaxRetryAfter = 0
+
+			_, err := client.ExtractPageWithContext(ctx, crawlertest.MakeURL("https://example.com/"), nil)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("HTTPClient.ExtractPageWithContext() error = %v, want %v", err, context.DeadlineExceeded)
+			}
+		})
+	}
+}
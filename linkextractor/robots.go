@@ -0,0 +1,249 @@
+package linkextractor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDisallowedByRobots is returned for pages that robots.txt does not allow to be crawled.
+var ErrDisallowedByRobots = errors.New("Disallowed by robots.txt")
+
+const (
+	// maxRobotsBytes is how much of a robots.txt file is read; the rest is ignored.
+	maxRobotsBytes = 500 * 1024
+
+	// robotsCacheDuration is how long the rules from a robots.txt file are used before it is fetched
+	// again.
+	robotsCacheDuration = 24 * time.Hour
+)
+
+// RobotsTokenForUserAgent returns the product token in a User-Agent header that robots.txt groups
+// are matched against: the crawler's name in "Mozilla/5.0 (compatible; name/1.0)", or the first
+// product otherwise.
+func RobotsTokenForUserAgent(userAgent string) string {
+	product := userAgent
+	if i := strings.Index(product, "(compatible;"); i >= 0 {
+		product = strings.TrimLeft(product[i+len("(compatible;"):], " ")
+	}
+
+	end := strings.IndexFunc(product, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+	})
+	if end >= 0 {
+		product = product[:end]
+	}
+
+	return product
+}
+
+// RobotsMatchingToken returns the product token to match robots.txt groups against.
+func (client HTTPClient) RobotsMatchingToken() string {
+	if client.RobotsToken != "" {
+		return client.RobotsToken
+	}
+
+	return RobotsTokenForUserAgent(client.userAgent())
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+	regexp  *regexp.Regexp
+}
+
+// RobotsRules are the allow and disallow rules from a robots.txt file that apply to a crawler.
+type RobotsRules struct {
+	rules []robotsRule
+}
+
+// disallowAll is used for sites whose robots.txt file cannot be fetched because of a server or
+// network error, as there is no telling what it would allow.
+var disallowAll = RobotsRules{rules: []robotsRule{newRobotsRule(false, "/")}}
+
+// ParseRobots reads a robots.txt file as described in RFC 9309, and returns the rules of the groups
+// for token, or those of the groups for * if there are none for token.
+func ParseRobots(reader io.Reader, token string) RobotsRules {
+	var forToken, forAnyone []robotsRule
+	var groupMatchesToken, groupMatchesAnyone, inRules, tokenHasGroup bool
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:colon]))
+		value := strings.TrimSpace(line[colon+1:])
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				groupMatchesToken, groupMatchesAnyone, inRules = false, false, false
+			}
+			groupMatchesToken = groupMatchesToken || strings.EqualFold(value, token)
+			tokenHasGroup = tokenHasGroup || groupMatchesToken
+			groupMatchesAnyone = groupMatchesAnyone || value == "*"
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue
+			}
+			rule := newRobotsRule(key == "allow", value)
+			if groupMatchesToken {
+				forToken = append(forToken, rule)
+			}
+			if groupMatchesAnyone {
+				forAnyone = append(forAnyone, rule)
+			}
+		}
+	}
+
+	if tokenHasGroup {
+		return RobotsRules{rules: forToken}
+	}
+
+	return RobotsRules{rules: forAnyone}
+}
+
+// newRobotsRule compiles a path pattern, in which * matches any sequence of characters and a $ at
+// the end anchors the pattern to the end of the path.
+func newRobotsRule(allow bool, pattern string) robotsRule {
+	anchored := strings.HasSuffix(pattern, "$")
+	expression := regexp.QuoteMeta(strings.TrimSuffix(pattern, "$"))
+	expression = "^" + strings.ReplaceAll(expression, `\*`, ".*")
+	if anchored {
+		expression += "$"
+	}
+
+	return robotsRule{allow: allow, pattern: pattern, regexp: regexp.MustCompile(expression)}
+}
+
+// Allowed reports whether the rules allow a URL to be crawled. The rule with the longest pattern
+// that matches its path and query decides; if an allow and a disallow rule are equally long, the
+// allow rule wins.
+func (rules RobotsRules) Allowed(URL url.URL) bool {
+	path := URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	if URL.RawQuery != "" {
+		path += "?" + URL.RawQuery
+	}
+
+	allowed, longest := true, -1
+	for _, rule := range rules.rules {
+		if !rule.regexp.MatchString(path) {
+			continue
+		}
+		if len(rule.pattern) > longest || len(rule.pattern) == longest && rule.allow {
+			allowed, longest = rule.allow, len(rule.pattern)
+		}
+	}
+
+	return allowed
+}
+
+// A RobotsCache keeps the robots.txt rules of each site, so that each robots.txt file is only
+// fetched once a day. It can be shared between crawls.
+type RobotsCache struct {
+	mutex   sync.Mutex
+	entries map[string]*robotsEntry
+}
+
+type robotsEntry struct {
+	ready     chan struct{} // closed once rules and fetchedAt are set
+	rules     RobotsRules
+	fetchedAt time.Time
+}
+
+func NewRobotsCache() *RobotsCache {
+	return &RobotsCache{entries: map[string]*robotsEntry{}}
+}
+
+// rules returns the rules for the site at origin, calling fetch to get them if they are not known
+// yet or have expired. Concurrent callers for the same site wait for a single fetch.
+func (cache *RobotsCache) rules(origin string, fetch func() RobotsRules) RobotsRules {
+	cache.mutex.Lock()
+	entry, ok := cache.entries[origin]
+	if ok {
+		select {
+		case <-entry.ready:
+			ok = time.Since(entry.fetchedAt) < robotsCacheDuration
+		default:
+		}
+	}
+	if ok {
+		cache.mutex.Unlock()
+		<-entry.ready
+		return entry.rules
+	}
+
+	entry = &robotsEntry{ready: make(chan struct{})}
+	cache.entries[origin] = entry
+	cache.mutex.Unlock()
+
+	entry.rules = fetch()
+	entry.fetchedAt = time.Now()
+	close(entry.ready)
+	return entry.rules
+}
+
+// robotsAllowed reports whether robots.txt allows URL to be crawled, fetching it if need be.
+func (client HTTPClient) robotsAllowed(URL url.URL) bool {
+	origin := url.URL{Scheme: URL.Scheme, Host: URL.Host}
+	rules := client.Robots.rules(origin.String(), func() RobotsRules {
+		return client.fetchRobots(origin)
+	})
+
+	return rules.Allowed(URL)
+}
+
+// fetchRobots fetches the robots.txt file of a site. As RFC 9309 asks, a site without one (one that
+// responds with a client error) may be crawled in full, while one whose robots.txt cannot be fetched
+// because of a server or network error may not be crawled at all. The request is not tied to any
+// crawl's context, as the result is shared between crawls.
+func (client HTTPClient) fetchRobots(origin url.URL) RobotsRules {
+	robotsURL := origin
+	robotsURL.Path = "/robots.txt"
+
+	request, err := http.NewRequestWithContext(context.Background(), "GET", robotsURL.String(), nil)
+	if err != nil {
+		return disallowAll
+	}
+
+	request.Header.Set("User-Agent", client.userAgent())
+	if client.From != "" {
+		request.Header.Set("From", client.From)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return disallowAll
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode <= 299:
+		return ParseRobots(io.LimitReader(response.Body, maxRobotsBytes), client.RobotsMatchingToken())
+	case response.StatusCode >= 400 && response.StatusCode <= 499:
+		return RobotsRules{}
+	default:
+		return disallowAll
+	}
+}
@@ -0,0 +1,98 @@
+package linkextractor
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+func TestParseRobots(t *testing.T) {
+	const robotsTxt = `
+# Rules for everyone
+User-agent: *
+Disallow: /private/
+Allow: /private/public-*.html$
+
+User-agent: ExampleBot
+user-agent: OtherBot
+Disallow: /  # nothing at all
+Allow: /blog/
+
+User-agent: S3Crawler
+Disallow:
+`
+
+	tests := []struct {
+		name  string
+		token string
+		url   string
+		want  bool
+	}{
+		{name: "no matching rule", token: "sitemapper", url: "https://example.com/about", want: true},
+		{name: "disallowed for anyone", token: "sitemapper", url: "https://example.com/private/report", want: false},
+		{name: "longer allow rule wins", token: "sitemapper", url: "https://example.com/private/public-report.html", want: true},
+		{name: "anchored pattern", token: "sitemapper", url: "https://example.com/private/public-report.html?page=2", want: false},
+		{name: "group for the token", token: "ExampleBot", url: "https://example.com/private/report", want: false},
+		{name: "group for the token replaces the one for anyone", token: "ExampleBot", url: "https://example.com/about", want: false},
+		{name: "token is matched case-insensitively", token: "examplebot", url: "https://example.com/blog/post", want: true},
+		{name: "group with several user agents", token: "OtherBot", url: "https://example.com/about", want: false},
+		{name: "empty disallow rule", token: "S3Crawler", url: "https://example.com/private/report", want: true},
+		{name: "robots.txt itself", token: "ExampleBot", url: "https://example.com/robots.txt", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := ParseRobots(strings.NewReader(robotsTxt), tt.token)
+			if got := rules.Allowed(crawlertest.MakeURL(tt.url)); got != tt.want {
+				t.Errorf("Allowed(%v) = %v, want %v", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPClient_ExtractPage_obeysRobots(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		url        string
+		wantErr    error
+	}{
+		{name: "allowed", statusCode: http.StatusOK, body: "User-agent: *\nDisallow: /private/", url: "https://example.com/", wantErr: nil},
+		{name: "disallowed", statusCode: http.StatusOK, body: "User-agent: *\nDisallow: /private/", url: "https://example.com/private/", wantErr: ErrDisallowedByRobots},
+		{name: "no robots.txt", statusCode: http.StatusNotFound, url: "https://example.com/private/", wantErr: nil},
+		{name: "server error", statusCode: http.StatusServiceUnavailable, url: "https://example.com/", wantErr: ErrDisallowedByRobots},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mutex sync.Mutex
+			robotsFetches := 0
+			pageDo := stubHttpClientDo(http.StatusOK, "text/html", `<html><body></body></html>`)
+
+			client := HTTPClient{
+				Do: func(req *http.Request) (*http.Response, error) {
+					if req.URL.Path != "/robots.txt" {
+						return pageDo(req)
+					}
+					mutex.Lock()
+					robotsFetches++
+					mutex.Unlock()
+					return makeHttpResponse(tt.statusCode, "text/plain", tt.body)
+				},
+				Robots: NewRobotsCache(),
+			}
+
+			for i := 0; i < 2; i++ {
+				if _, err := client.ExtractPage(crawlertest.MakeURL(tt.url)); !errors.Is(err, tt.wantErr) {
+					t.Errorf("ExtractPage() error = %v, want %v", err, tt.wantErr)
+				}
+			}
+			if robotsFetches != 1 {
+				t.Errorf("robots.txt was fetched %d times, want once", robotsFetches)
+			}
+		})
+	}
+}
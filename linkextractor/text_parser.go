@@ -0,0 +1,51 @@
+package linkextractor
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// TextParser finds absolute http(s) URLs in plain text.
+type TextParser struct{}
+
+var urlInTextPattern = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `{}|\\^]+`)
+
+func (TextParser) Parse(pageURL url.URL, body io.Reader) (Document, error) {
+	contents, err := ioutil.ReadAll(body)
+	if err != nil {
+		return Document{}, fmt.Errorf("Failed to read response body: %w", err)
+	}
+
+	result := make([]url.URL, 0)
+
+	for _, match := range urlInTextPattern.FindAllString(string(contents), -1) {
+		result = appendLink(result, pageURL, trimTrailingPunctuation(match))
+	}
+
+	return Document{Links: result}, nil
+}
+
+// trimTrailingPunctuation removes characters that are more likely to belong to the surrounding
+// sentence than to the URL, such as a full stop or a closing parenthesis without an opening one.
+func trimTrailingPunctuation(match string) string {
+	for len(match) > 0 {
+		last := match[len(match)-1]
+
+		switch {
+		case strings.IndexByte(".,;:!?", last) >= 0:
+			match = match[:len(match)-1]
+		case last == ')' && strings.Count(match, "(") < strings.Count(match, ")"):
+			match = match[:len(match)-1]
+		case last == ']' && strings.Count(match, "[") < strings.Count(match, "]"):
+			match = match[:len(match)-1]
+		default:
+			return match
+		}
+	}
+
+	return match
+}
@@ -0,0 +1,47 @@
+package linkextractor
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+func TestTextParser_Parse(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []url.URL
+	}{
+		{
+			name: "URLs are found and trailing punctuation is removed",
+			body: `See https://example.com/docs/a, or (for details) https://example.com/docs/b).
+Also: http://example.org/wiki/Go_(language) and "https://example.com/q?x=1&y=2".
+Not a link: ftp://example.com/file and example.com/no-scheme`,
+			want: []url.URL{
+				crawlertest.MakeURL("https://example.com/docs/a"),
+				crawlertest.MakeURL("https://example.com/docs/b"),
+				crawlertest.MakeURL("http://example.org/wiki/Go_(language)"),
+				crawlertest.MakeURL("https://example.com/q?x=1&y=2"),
+			},
+		},
+		{
+			name: "no URLs",
+			body: "Nothing to see here.",
+			want: []url.URL{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TextParser{}.Parse(crawlertest.MakeURL("https://example.com/"), strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("TextParser.Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Links, tt.want) {
+				t.Errorf("TextParser.Parse() = %v, want %v", got.Links, tt.want)
+			}
+		})
+	}
+}
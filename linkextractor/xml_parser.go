@@ -0,0 +1,181 @@
+package linkextractor
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+// FeedParser finds the links of the items in RSS (0.9x, 1.0 and 2.0) and Atom feeds.
+type FeedParser struct{}
+
+// SitemapParser finds the locations listed in XML sitemaps and sitemap index files.
+type SitemapParser struct{}
+
+// XMLParser looks at the root element of a generic XML document to decide whether it is a feed
+// or a sitemap.
+type XMLParser struct{}
+
+func (FeedParser) Parse(pageURL url.URL, body io.Reader) (Document, error) {
+	return parseXML(pageURL, body, func(path []xml.Name, element xml.StartElement) (string, bool) {
+		switch {
+		case isElement(element.Name, "link") && len(path) >= 1 && isElement(path[len(path)-1], "item"):
+			return "", true
+		case isElement(element.Name, "link") && len(path) >= 1 && isElement(path[len(path)-1], "entry"):
+			rel := attribute(element, "rel")
+			if rel == "" || rel == "alternate" {
+				return attribute(element, "href"), false
+			}
+		}
+		return "", false
+	})
+}
+
+func (SitemapParser) Parse(pageURL url.URL, body io.Reader) (Document, error) {
+	return parseXML(pageURL, body, func(path []xml.Name, element xml.StartElement) (string, bool) {
+		if isElement(element.Name, "loc") && len(path) >= 1 &&
+			(isElement(path[len(path)-1], "url") || isElement(path[len(path)-1], "sitemap")) {
+			return "", true
+		}
+		return "", false
+	})
+}
+
+func (XMLParser) Parse(pageURL url.URL, body io.Reader) (Document, error) {
+	contents, err := ioutil.ReadAll(body)
+	if err != nil {
+		return Document{}, fmt.Errorf("Failed to read response body: %w", err)
+	}
+
+	rootElement, err := findRootElement(contents)
+	if err != nil {
+		return Document{}, fmt.Errorf("Failed to parse response body: %w", err)
+	}
+
+	switch strings.ToLower(rootElement.Local) {
+	case "rss", "rdf", "feed":
+		return FeedParser{}.Parse(pageURL, bytes.NewReader(contents))
+	case "urlset", "sitemapindex":
+		return SitemapParser{}.Parse(pageURL, bytes.NewReader(contents))
+	default:
+		return Document{}, fmt.Errorf("%w: XML document with root element %s", ErrUnsupportedContentType, rootElement.Local)
+	}
+}
+
+// A linkSelector decides what to do with an element, given the path of elements leading up to
+// it. It can return a link directly (typically from an attribute), or ask for the text content of
+// the element to be used as a link.
+type linkSelector func(path []xml.Name, element xml.StartElement) (link string, useText bool)
+
+func parseXML(pageURL url.URL, body io.Reader, selectLink linkSelector) (Document, error) {
+	decoder := newXMLDecoder(body)
+	path := make([]xml.Name, 0)
+	result := make([]url.URL, 0)
+	details := make([]LinkDetails, 0)
+
+	var text *strings.Builder
+	var textDetails LinkDetails
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Document{}, fmt.Errorf("Failed to parse response body: %w", err)
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			link, useText := selectLink(path, token)
+			linkDetails := LinkDetails{Element: token.Name.Local, Rel: attribute(token, "rel")}
+			switch {
+			case useText:
+				text, textDetails = &strings.Builder{}, linkDetails
+			case link != "":
+				result, details = appendLinkWithDetails(result, details, pageURL, link, linkDetails)
+			}
+			path = append(path, token.Name)
+		case xml.CharData:
+			if text != nil {
+				text.Write(token)
+			}
+		case xml.EndElement:
+			path = path[:len(path)-1]
+			if text != nil {
+				result, details = appendLinkWithDetails(result, details, pageURL, text.String(), textDetails)
+				text = nil
+			}
+		}
+	}
+
+	return Document{Links: result, LinkDetails: details}, nil
+}
+
+// newXMLDecoder expects bodies that have already been decoded to UTF-8, so it ignores any encoding
+// that is declared in the document.
+func newXMLDecoder(body io.Reader) *xml.Decoder {
+	decoder := xml.NewDecoder(body)
+	decoder.Strict = false
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder
+}
+
+func findRootElement(contents []byte) (xml.Name, error) {
+	decoder := newXMLDecoder(bytes.NewReader(contents))
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.Name{}, err
+		}
+
+		if element, ok := token.(xml.StartElement); ok {
+			return element.Name, nil
+		}
+	}
+}
+
+func appendLink(links []url.URL, pageURL url.URL, rawLink string) []url.URL {
+	rawLink = strings.TrimSpace(rawLink)
+	if rawLink == "" {
+		return links
+	}
+
+	parsedURL, err := pageURL.Parse(rawLink)
+	if err != nil {
+		return links
+	}
+
+	return append(links, *parsedURL)
+}
+
+// appendLinkWithDetails is like appendLink, but also appends the details of the link if it was valid.
+func appendLinkWithDetails(links []url.URL, details []LinkDetails, pageURL url.URL, rawLink string, linkDetails LinkDetails) ([]url.URL, []LinkDetails) {
+	result := appendLink(links, pageURL, rawLink)
+	if len(result) > len(links) {
+		details = append(details, linkDetails)
+	}
+
+	return result, details
+}
+
+func isElement(name xml.Name, local string) bool {
+	return strings.EqualFold(name.Local, local)
+}
+
+func attribute(element xml.StartElement, local string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Space == "" && strings.EqualFold(attr.Name.Local, local) {
+			return attr.Value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,111 @@
+package linkextractor
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+func TestXMLParser_Parse(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []url.URL
+		wantErr bool
+	}{
+		{
+			name: "RSS 2.0 item links",
+			body: `<?xml version="1.0"?>
+				<rss version="2.0">
+				  <channel>
+				    <title>News</title>
+				    <link>https://example.com/news/</link>
+				    <item><title>One</title><link>https://example.com/news/one</link></item>
+				    <item><title>Two</title><link> /news/two </link></item>
+				  </channel>
+				</rss>`,
+			want: []url.URL{
+				crawlertest.MakeURL("https://example.com/news/one"),
+				crawlertest.MakeURL("https://example.com/news/two"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "RSS 1.0 item links",
+			body: `<?xml version="1.0"?>
+				<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/">
+				  <channel rdf:about="https://example.com/"><link>https://example.com/</link></channel>
+				  <item rdf:about="https://example.com/one"><link>https://example.com/one</link></item>
+				</rdf:RDF>`,
+			want: []url.URL{
+				crawlertest.MakeURL("https://example.com/one"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "Atom entry links",
+			body: `<?xml version="1.0" encoding="utf-8"?>
+				<feed xmlns="http://www.w3.org/2005/Atom">
+				  <link href="https://example.com/" />
+				  <entry>
+				    <link href="https://example.com/posts/1" />
+				    <link rel="edit" href="https://example.com/edit/1" />
+				  </entry>
+				  <entry><link rel="alternate" href="posts/2"/></entry>
+				</feed>`,
+			want: []url.URL{
+				crawlertest.MakeURL("https://example.com/posts/1"),
+				crawlertest.MakeURL("https://example.com/feeds/posts/2"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "XML sitemap",
+			body: `<?xml version="1.0" encoding="UTF-8"?>
+				<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+				  <url><loc>https://example.com/</loc><lastmod>2021-01-01</lastmod></url>
+				  <url><loc>https://example.com/about</loc></url>
+				</urlset>`,
+			want: []url.URL{
+				crawlertest.MakeURL("https://example.com/"),
+				crawlertest.MakeURL("https://example.com/about"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "XML sitemap index",
+			body: `<?xml version="1.0" encoding="UTF-8"?>
+				<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+				  <sitemap><loc>https://example.com/sitemap-1.xml</loc></sitemap>
+				</sitemapindex>`,
+			want: []url.URL{
+				crawlertest.MakeURL("https://example.com/sitemap-1.xml"),
+			},
+			wantErr: false,
+		},
+		{
+			name:    "other XML documents are not supported",
+			body:    `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"><a href="main">Main</a></svg>`,
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := XMLParser{}.Parse(crawlertest.MakeURL("https://example.com/feeds/"), strings.NewReader(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("XMLParser.Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got.Links, tt.want) {
+				t.Errorf("XMLParser.Parse() = %v, want %v", got.Links, tt.want)
+			}
+			if !tt.wantErr && len(got.LinkDetails) != len(got.Links) {
+				t.Errorf("XMLParser.Parse() gave details for %d of %d links", len(got.LinkDetails), len(got.Links))
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// Package logging writes levelled log messages with key-value fields through log/slog, either as
+// text for people or as JSON objects, one per line, for log pipelines.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+type Level = slog.Level
+
+const (
+	Debug = slog.LevelDebug
+	Info  = slog.LevelInfo
+	Warn  = slog.LevelWarn
+	Error = slog.LevelError
+)
+
+func ParseLevel(name string) (Level, error) {
+	switch name {
+	case "debug":
+		return Debug, nil
+	case "info":
+		return Info, nil
+	case "warn":
+		return Warn, nil
+	case "error":
+		return Error, nil
+	default:
+		return Info, fmt.Errorf("Unknown log level %q", name)
+	}
+}
+
+type Format int
+
+const (
+	Text Format = iota
+	JSON
+)
+
+func ParseFormat(name string) (Format, error) {
+	switch name {
+	case "text":
+		return Text, nil
+	case "json":
+		return JSON, nil
+	default:
+		return Text, fmt.Errorf("Unknown log format %q", name)
+	}
+}
+
+// A Logger writes messages at or above its level. Each message can be followed by fields given as
+// alternating keys and values, as in logger.Warn("Failed to extract links", "url", URL, "error", err).
+// Errors, durations and other values that have a String method are written as strings.
+type Logger struct {
+	*slog.Logger
+	writer io.Writer
+	format Format
+	level  Level
+}
+
+func New(writer io.Writer, format Format, level Level) *Logger {
+	var handler slog.Handler
+	switch format {
+	case JSON:
+		handler = slog.NewJSONHandler(writer, &slog.HandlerOptions{Level: level, ReplaceAttr: replaceJSONAttr})
+	default:
+		handler = slog.NewTextHandler(writer, &slog.HandlerOptions{Level: level, ReplaceAttr: replaceTextAttr})
+	}
+
+	return &Logger{Logger: slog.New(handler), writer: writer, format: format, level: level}
+}
+
+// WithWriter returns a Logger with the same format and level that writes to writer instead.
+func (logger *Logger) WithWriter(writer io.Writer) *Logger {
+	return New(writer, logger.format, logger.level)
+}
+
+func (logger *Logger) Format() Format {
+	return logger.format
+}
+
+func (logger *Logger) Enabled(level Level) bool {
+	return logger.Logger.Enabled(context.Background(), level)
+}
+
+// Fatal logs a message at the error level and exits with status 1.
+func (logger *Logger) Fatal(message string, keysAndValues ...interface{}) {
+	logger.Error(message, keysAndValues...)
+	os.Exit(1)
+}
+
+// replaceTextAttr leaves out the time, which people watching a crawl do not need on every line.
+func replaceTextAttr(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) == 0 && attr.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+
+	return stringify(attr)
+}
+
+// replaceJSONAttr writes levels in lower case, and times in UTC.
+func replaceJSONAttr(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) == 0 {
+		switch attr.Key {
+		case slog.LevelKey:
+			return slog.String(slog.LevelKey, strings.ToLower(attr.Value.String()))
+		case slog.TimeKey:
+			return slog.String(slog.TimeKey, attr.Value.Time().UTC().Format(time.RFC3339Nano))
+		}
+	}
+
+	return stringify(attr)
+}
+
+// stringify converts errors, durations and values with a String method to strings.
+func stringify(attr slog.Attr) slog.Attr {
+	switch attr.Value.Kind() {
+	case slog.KindDuration:
+		return slog.String(attr.Key, attr.Value.Duration().String())
+	case slog.KindAny:
+		switch value := attr.Value.Any().(type) {
+		case error:
+			return slog.String(attr.Key, value.Error())
+		case fmt.Stringer:
+			return slog.String(attr.Key, value.String())
+		}
+	}
+
+	return attr
+}
@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLogger_text(t *testing.T) {
+	var output bytes.Buffer
+	logger := New(&output, Text, Info)
+
+	logger.Debug("Extracting links", "url", "https://example.com/")
+	logger.Info("Retrying", "url", &url.URL{Scheme: "https", Host: "example.com", Path: "/"}, "delay", 2*time.Second)
+	logger.Warn("Failed to extract links", "status", 503, "error", errors.New("Got a 503 Service Unavailable response"), "charset", "")
+
+	want := `level=INFO msg=Retrying url=https://example.com/ delay=2s
+level=WARN msg="Failed to extract links" status=503 error="Got a 503 Service Unavailable response" charset=""
+`
+	if got := output.String(); got != want {
+		t.Errorf("Logger wrote %q, want %q", got, want)
+	}
+}
+
+func TestLogger_JSON(t *testing.T) {
+	var output bytes.Buffer
+	logger := New(&output, JSON, Debug)
+
+	logger.Warn("Failed to extract links", "url", "https://example.com/", "depth", 1, "duration", 1500*time.Millisecond, "error", errors.New("timeout"))
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(output.Bytes(), &got); err != nil {
+		t.Fatalf("Logger wrote invalid JSON %q: %v", output.String(), err)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, got["time"].(string)); err != nil {
+		t.Errorf("Logger wrote invalid time: %v", err)
+	}
+	delete(got, "time")
+
+	want := map[string]interface{}{
+		"level":    "warn",
+		"msg":      "Failed to extract links",
+		"url":      "https://example.com/",
+		"depth":    1.0,
+		"duration": "1.5s",
+		"error":    "timeout",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Logger wrote %v, want %v", got, want)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Level
+		wantErr bool
+	}{
+		{name: "debug", want: Debug},
+		{name: "warn", want: Warn},
+		{name: "verbose", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLevel(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseLevel() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
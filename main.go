@@ -3,82 +3,576 @@ package main
 import (
 	"flag"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
+	"net/mail"
 	"net/url"
 	"os"
 	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hilverd/sitemapper/crawler"
+	"github.com/hilverd/sitemapper/httpclient"
 	"github.com/hilverd/sitemapper/linkextractor"
+	"github.com/hilverd/sitemapper/logging"
+	"github.com/hilverd/sitemapper/metrics"
+	"github.com/hilverd/sitemapper/progress"
+	"github.com/hilverd/sitemapper/sitemap"
 )
 
+// version is set at build time using -ldflags "-X main.version=..."
+var version = ""
+
+// logger is replaced by one that uses -log-format and -log-level once the options have been parsed.
+var logger = logging.New(os.Stderr, logging.Text, logging.Info)
+
+type repeatedFlag []string
+
+func (values *repeatedFlag) String() string {
+	return strings.Join(*values, ", ")
+}
+
+func (values *repeatedFlag) Set(value string) error {
+	*values = append(*values, value)
+	return nil
+}
+
 func main() {
-	configuration, httpClient := parseCommandLineOptions(nil)
-	sitemap := crawler.Crawl(configuration, httpClient)
-	fmt.Fprintln(configuration.SitemapWriter, sitemap.PrettyPrint())
+	os.Exit(run(os.Args[1:], os.Stdout))
 }
 
-func parseCommandLineOptions(arguments []string) (crawler.Configuration, linkextractor.HTTPClient) {
-	flag.Usage = func() {
-		fmt.Fprint(os.Stderr, `Usage: sitemapper [OPTIONS] SEED_URL
-Crawl web pages starting from SEED_URL and print a basic site map to standard output.
+func parseCommandLineOptions(command string, arguments []string) (crawler.Configuration, linkextractor.HTTPClient, *httpclient.LoginForm, outputOptions) {
+	return parseOptions(newFlagSet(command), arguments)
+}
+
+// parseOptions adds the options for crawling to flags, which may already have options of its own, and
+// parses arguments. The serve command takes no seed URL, as each crawl job has its own.
+func parseOptions(flags *flag.FlagSet, arguments []string) (crawler.Configuration, linkextractor.HTTPClient, *httpclient.LoginForm, outputOptions) {
+	command := flags.Name()
+
+	verbose := flags.Bool("v", false, "verbosely list pages as they are being processed (short for -log-level debug)")
+	logLevel := flags.String("log-level", "warn", "minimum level of messages to log: debug, info, warn or error")
+	showProgress := flags.Bool("progress", false, "show pages done, queue length, requests in flight, pages per second, errors, bytes transferred and depth, refreshed in place if standard error is a terminal")
+	progressInterval := flags.Duration("progress-interval", 10*time.Second, "how often to write a progress line if standard error is not a terminal")
+	metricsAddr := flags.String("metrics-addr", "", "address, such as :9090, to serve Prometheus metrics on at /metrics while crawling")
+	logFormat := flags.String("log-format", "text", "format of log messages written to standard error: text or json (one object per line, without the summary printed at the end of a crawl)")
+	requestTimeoutSeconds := flags.Int("request-timeout", 30, "HTTP request timeout in seconds (zero means no timeout)")
+	maxConcurrentRequests := flags.Int("max-concurrent-requests", runtime.GOMAXPROCS(0), "maximum number of concurrent requests")
+	adaptiveConcurrency := flags.Bool("adaptive-concurrency", false, "raise the number of concurrent requests while response times are stable and cut it when the server slows down")
+	minConcurrentRequests := flags.Int("min-concurrent-requests", 1, "minimum number of concurrent requests when using adaptive concurrency")
+	maxDepth := flags.Int("max-depth", 0, "maximum crawl depth, i.e. distance from seed URL (zero means no maximum)")
+	defaultRetryPolicy := linkextractor.DefaultRetryPolicy()
+	maxRetries := flags.Int("max-retries", defaultRetryPolicy.MaxRetries, "maximum number of times to retry a failed request (zero means no retries)")
+	retryBackoff := flags.Duration("retry-backoff", defaultRetryPolicy.InitialBackoff, "delay before the first retry, doubled for each further retry and jittered")
+	retryMaxBackoff := flags.Duration("retry-max-backoff", defaultRetryPolicy.MaxBackoff, "maximum delay between retries")
+	retryStatusCodes := flags.String("retry-status-codes", formatStatusCodes(defaultRetryPolicy.RetryStatusCodes), "comma-separated HTTP status codes to retry")
+	retryErrors := flags.String("retry-errors", "timeout,connection", "comma-separated kinds of request errors to retry (timeout, connection)")
+	honourRetryAfter := flags.Bool("honour-retry-after", defaultRetryPolicy.HonourRetryAfter, "wait as long as a Retry-After header asks for on 429 and 503 responses")
+	maxRetryAfter := flags.Duration("max-retry-after", defaultRetryPolicy.MaxRetryAfter, "give up instead of retrying if Retry-After asks for a longer wait (zero means no maximum)")
 
-Options:
-`)
-		flag.PrintDefaults()
+	var headers, loginFields, resolveEntries, pathPrefixBudgets repeatedFlag
+	flags.Var(&headers, "header", "extra request header of the form 'Name: value' (can be repeated)")
+	cookiesFile := flags.String("cookies", "", "file with cookies in the Netscape cookies.txt format to send with requests")
+	basicAuthEnv := flags.String("basic-auth-env", "", "name of an environment variable holding 'username:password' for HTTP basic authentication")
+	bearerTokenEnv := flags.String("bearer-token-env", "", "name of an environment variable holding a bearer token")
+	loginURL := flags.String("login-url", "", "URL to POST a login form to before crawling; session cookies are kept")
+	flags.Var(&loginFields, "login-field", "login form field of the form 'name=value', where value may refer to environment variables as ${NAME} (can be repeated)")
+
+	proxy := flags.String("proxy", "", "proxy URL to send requests through (http, https, socks5 or socks5h)")
+	caCertFile := flags.String("ca-cert", "", "file with PEM-encoded CA certificates to trust in addition to the system ones")
+	clientCertFile := flags.String("client-cert", "", "file with a PEM-encoded client certificate for mutual TLS")
+	clientKeyFile := flags.String("client-key", "", "file with the PEM-encoded private key belonging to client-cert")
+	insecure := flags.Bool("insecure", false, "do not verify TLS certificates (only use this for self-signed development servers)")
+	flags.Var(&resolveEntries, "resolve", "connect to ADDRESS instead of resolving HOST:PORT, in the form HOST:PORT:ADDRESS (can be repeated)")
+
+	maxPages := flags.Int("max-pages", 0, "maximum number of pages to crawl (zero means no maximum)")
+	maxPagesPerHost := flags.Int("max-pages-per-host", 0, "maximum number of pages to crawl per host (zero means no maximum)")
+	flags.Var(&pathPrefixBudgets, "max-pages-per-path-prefix", "maximum number of pages to crawl under a path prefix, in the form PREFIX=N, e.g. /tags/=200 (can be repeated)")
+	crawlOrder := flags.String("crawl-order", "breadth-first", "order in which to crawl queued pages: breadth-first (strictly by depth) or shortest-path-first (fewest path segments first)")
+	deterministic := flags.Bool("deterministic", false, "crawl one depth at a time and handle each level in order of URL, so repeated crawls of an unchanged site give the same result")
+	format, keepUncrawledLinks, keepFailedPages := new(string), new(bool), new(bool)
+	outputPath, xmlBaseURL, outputPaths := new(string), new(string), make(map[string]*string)
+	tables, pageColumns, edgeColumns := new(string), new(string), new(string)
+	if command == "crawl" {
+		format = flags.String("format", "text", "output format: text (printed at the end), ndjson (written while crawling), csv or tsv (tables of pages and of links between them, see -tables), xml (a sitemaps.org sitemap, written at the end) or html (a self-contained report for browsing the crawl, written at the end); use ndjson to save a crawl for the other commands")
+		outputPath = flags.String("output", "", "file or directory to write the output in -format to instead of standard output; files are replaced only once the crawl has finished, and a directory gets a file named sitemap with the format's extension")
+		for _, outputFormat := range outputFormats {
+			outputPaths[outputFormat] = flags.String("output-"+outputFormat, "", "file or directory to write "+outputFormat+" output to, in addition to other outputs")
+		}
+		flags.StringVar(outputPaths["ndjson"], "output-json", "", "alias for -output-ndjson")
+		tables = flags.String("tables", "", "comma-separated tables to write for csv and tsv output: pages and edges (default both for files, named like pages.csv and edges.csv in a directory or crawl-pages.csv and crawl-edges.csv for crawl.csv, and pages for standard output)")
+		pageColumns = flags.String("page-columns", "", "comma-separated columns of the pages table, out of "+strings.Join(sitemap.PageColumns, ",")+"; with inbound, rows are only written once all pages have been crawled (default "+strings.Join(sitemap.DefaultPageColumns, ",")+")")
+		edgeColumns = flags.String("edge-columns", "", "comma-separated columns of the edges table (default "+strings.Join(sitemap.EdgeColumns, ",")+")")
+		xmlBaseURL = flags.String("xml-base-url", "", "URL of the directory the XML sitemap will be published in, used to refer to the parts of a sitemap that is split over several files (default the root of the seed URL)")
+		keepUncrawledLinks = flags.Bool("keep-uncrawled-links", false, "keep links to pages that were not crawled in the text output")
+		keepFailedPages = flags.Bool("keep-failed-pages", false, "include pages that could not be crawled in the output, with their status codes and errors")
+	}
+	detectTraps := flags.Bool("detect-traps", true, "skip URLs that look like crawler traps, such as repeating path segments, growing query strings, very long URLs and session IDs in paths")
+	skipSessionIDParameters := flags.Bool("skip-session-id-parameters", false, "also skip URLs with query parameters that are commonly used for session IDs, such as PHPSESSID and sid")
+	maxBytes := flags.Int64("max-bytes", 0, "stop queueing pages once this many bytes have been transferred (zero means no maximum)")
+	maxBodyBytes := flags.Int64("max-body-size", 10*1024*1024, "maximum number of bytes to read from a response body; longer bodies are truncated (zero means no maximum)")
+	htmlParser := flags.String("html-parser", "dom", "how to find links in HTML: dom (build a full document tree) or tokenizer (faster, streaming)")
+	userAgent := flags.String("user-agent", "", "User-Agent header to send (default \""+linkextractor.UserAgentForVersion(currentVersion())+"\")")
+	respectRobotsTxt := flags.Bool("respect-robots-txt", false, "do not fetch pages that the site's robots.txt disallows")
+	robotsToken := flags.String("robots-token", "", "product token to match robots.txt rules against (default the crawler name in -user-agent)")
+	from := flags.String("from", "", "e-mail address to send in the From header, so site owners can contact you")
+	showVersion := flags.Bool("version", false, "print the version and exit")
+	configFile := flags.String("config", "", "YAML file with settings named after these options, plus seed-url; options given on the command line take precedence")
+	profile := flags.String("profile", "", "named profile from the config file whose settings override the file's top-level ones")
+	printConfig := flags.Bool("print-config", false, "print the effective settings in the config file format, with secrets redacted, and exit")
+
+	_ = flags.Parse(arguments)
+	configureLogger(*logFormat, *logLevel, *verbose)
+
+	if *showVersion {
+		fmt.Println(currentVersion())
+		os.Exit(0)
+	}
+
+	rawSeedURL := ""
+	settings := configSettings{}
+	if *configFile != "" {
+		var err error
+		if settings, err = readConfigFile(*configFile, *profile); err != nil {
+			logger.Fatal(err.Error())
+		}
+		if rawSeedURL, err = applyConfigSettings(flags, settings); err != nil {
+			logger.Fatal(err.Error())
+		}
+		configureLogger(*logFormat, *logLevel, *verbose)
+	} else if *profile != "" {
+		logger.Fatal("profile can only be used together with config")
 	}
 
-	verbose := flag.Bool("v", false, "verbosely list pages as they are being processed")
-	requestTimeoutSeconds := flag.Int("request-timeout", 30, "HTTP request timeout in seconds (zero means no timeout)")
-	maxConcurrentRequests := flag.Int("max-concurrent-requests", runtime.GOMAXPROCS(0), "maximum number of concurrent requests")
-	maxDepth := flag.Int("max-depth", 0, "maximum crawl depth, i.e. distance from seed URL (zero means no maximum)")
+	switch {
+	case command == "serve":
+		if flags.NArg() > 0 {
+			flags.Usage()
+			os.Exit(1)
+		}
+		rawSeedURL = ""
+	case flags.NArg() == 1:
+		rawSeedURL = flags.Arg(0)
+	case flags.NArg() > 1 || rawSeedURL == "":
+		flags.Usage()
+		os.Exit(1)
+	}
 
-	if arguments == nil {
-		flag.Parse()
-	} else {
-		_ = flag.CommandLine.Parse(arguments)
+	if *printConfig {
+		if err := printEffectiveSettings(os.Stdout, flags, rawSeedURL, settings.secretValues); err != nil {
+			logger.Fatal(err.Error())
+		}
+		os.Exit(0)
 	}
 
 	switch {
 	case *requestTimeoutSeconds < 0:
-		log.Fatal("request-timeout must be at least zero")
+		logger.Fatal("request-timeout must be at least zero")
 	case *maxConcurrentRequests <= 0:
-		log.Fatal("max-concurrent-requests must be greater than zero")
+		logger.Fatal("max-concurrent-requests must be greater than zero")
+	case *minConcurrentRequests <= 0 || *maxConcurrentRequests < *minConcurrentRequests:
+		logger.Fatal("min-concurrent-requests must be greater than zero and at most max-concurrent-requests")
 	case *maxDepth < 0:
-		log.Fatal("max-depth must be at least zero")
+		logger.Fatal("max-depth must be at least zero")
+	case *maxPages < 0 || *maxPagesPerHost < 0:
+		logger.Fatal("max-pages and max-pages-per-host must be at least zero")
+	case *maxBytes < 0:
+		logger.Fatal("max-bytes must be at least zero")
+	case *maxBodyBytes < 0:
+		logger.Fatal("max-body-size must be at least zero")
+	case *maxRetries < 0:
+		logger.Fatal("max-retries must be at least zero")
+	case *retryBackoff < 0 || *retryMaxBackoff < 0 || *maxRetryAfter < 0:
+		logger.Fatal("retry-backoff, retry-max-backoff and max-retry-after must be at least zero")
+	case *progressInterval <= 0:
+		logger.Fatal("progress-interval must be greater than zero")
 	}
 
-	seedURLStrings := flag.Args()
-	if len(seedURLStrings) != 1 {
-		flag.Usage()
-		os.Exit(1)
+	var maxPagesPerPathPrefix map[string]int
+	for _, budget := range pathPrefixBudgets {
+		prefix, maxPagesForPrefix, err := parsePathPrefixBudget(budget)
+		if err != nil {
+			logger.Fatal("Invalid max-pages-per-path-prefix", "error", err)
+		}
+		if maxPagesPerPathPrefix == nil {
+			maxPagesPerPathPrefix = map[string]int{}
+		}
+		maxPagesPerPathPrefix[prefix] = maxPagesForPrefix
+	}
+
+	var trapRules []crawler.TrapRule
+	if !*detectTraps {
+		trapRules = []crawler.TrapRule{}
+	}
+	if *skipSessionIDParameters {
+		if trapRules == nil {
+			trapRules = crawler.DefaultTrapRules()
+		}
+		trapRules = append(trapRules, crawler.SessionIDParameterRule())
+	}
+
+	retryPolicy := linkextractor.RetryPolicy{
+		MaxRetries:       *maxRetries,
+		InitialBackoff:   *retryBackoff,
+		MaxBackoff:       *retryMaxBackoff,
+		HonourRetryAfter: *honourRetryAfter,
+		MaxRetryAfter:    *maxRetryAfter,
+	}
+
+	statusCodes, err := parseStatusCodes(*retryStatusCodes)
+	if err != nil {
+		logger.Fatal("Invalid retry-status-codes", "error", err)
+	}
+	retryPolicy.RetryStatusCodes = statusCodes
+
+	for _, kind := range splitCommaSeparated(*retryErrors) {
+		switch kind {
+		case "timeout":
+			retryPolicy.RetryOnTimeout = true
+		case "connection":
+			retryPolicy.RetryOnConnectionError = true
+		default:
+			logger.Fatal("Invalid retry-errors: unknown kind of error", "kind", kind)
+		}
+	}
+
+	seedURL := &url.URL{}
+	if command != "serve" {
+		var err error
+		if seedURL, err = normaliseURL(rawSeedURL); err != nil || seedURL.Scheme != "http" && seedURL.Scheme != "https" {
+			logger.Fatal("Invalid seed URL", "url", rawSeedURL)
+		}
+	}
+
+	parsers := linkextractor.NewDefaultRegistry()
+	switch *htmlParser {
+	case "dom":
+	case "tokenizer":
+		parsers.Register("text/html", linkextractor.HTMLTokenizerParser{})
+		parsers.Register("application/xhtml+xml", linkextractor.HTMLTokenizerParser{})
+	default:
+		logger.Fatal("html-parser must be either dom or tokenizer")
+	}
+
+	switch *format {
+	case "", "text", "ndjson", "csv", "tsv", "xml", "html":
+	default:
+		logger.Fatal("format must be one of text, ndjson, csv, tsv, xml or html")
+	}
+
+	tableOptions, err := parseTableOptions(*tables, *pageColumns, *edgeColumns)
+	if err != nil {
+		logger.Fatal(err.Error())
+	}
+
+	outputs := outputOptions{xmlBaseURL: url.URL{Scheme: seedURL.Scheme, Host: seedURL.Host, Path: "/"}, tables: tableOptions}
+	if *outputPath != "" {
+		outputs.outputs = append(outputs.outputs, output{format: *format, path: *outputPath})
+	}
+	for _, outputFormat := range outputFormats {
+		if path, ok := outputPaths[outputFormat]; ok && *path != "" {
+			outputs.outputs = append(outputs.outputs, output{format: outputFormat, path: *path})
+		}
+	}
+	for i := range outputs.outputs {
+		for j := i + 1; j < len(outputs.outputs); j++ {
+			first, second := outputs.outputs[i], outputs.outputs[j]
+			if first.path == second.path && (first.format == second.format || !first.isDirectory()) {
+				logger.Fatal("More than one output is written to the same path", "path", first.path)
+			}
+		}
+	}
+	if len(outputs.outputs) == 0 {
+		outputs.outputs = append(outputs.outputs, output{format: *format})
+	}
+	for _, output := range outputs.outputs {
+		if _, ok := tableSeparators[output.format]; ok {
+			if _, err := tableOptions.tablesFor(output); err != nil {
+				logger.Fatal(err.Error())
+			}
+		}
+	}
+	if *xmlBaseURL != "" {
+		baseURL, err := url.Parse(*xmlBaseURL)
+		if err != nil || baseURL.Scheme == "" || baseURL.Host == "" {
+			logger.Fatal("Invalid XML base URL", "url", *xmlBaseURL)
+		}
+		outputs.xmlBaseURL = *baseURL
+	}
+
+	var priority crawler.PriorityFunc
+	switch *crawlOrder {
+	case "breadth-first":
+	case "shortest-path-first":
+		priority = crawler.ShortestPathFirst
+	default:
+		logger.Fatal("crawl-order must be either breadth-first or shortest-path-first")
 	}
 
-	rawSeedURL := seedURLStrings[0]
-	seedURL, err := normaliseURL(rawSeedURL)
-	if err != nil || seedURL.Scheme != "http" && seedURL.Scheme != "https" {
-		log.Fatalf("Invalid seed URL: %s", rawSeedURL)
+	if *userAgent == "" {
+		*userAgent = linkextractor.UserAgentForVersion(currentVersion())
 	}
 
-	progressWriter := ioutil.Discard
-	if *verbose {
-		progressWriter = os.Stderr
+	if *from != "" {
+		if _, err := mail.ParseAddress(*from); err != nil {
+			logger.Fatal("from must be an e-mail address")
+		}
+	}
+
+	var robots *linkextractor.RobotsCache
+	if *respectRobotsTxt {
+		robots = linkextractor.NewRobotsCache()
+	}
+
+	header := http.Header{}
+	for _, line := range headers {
+		name, value, err := httpclient.ParseHeader(line)
+		if err != nil {
+			logger.Fatal("Invalid header", "error", err)
+		}
+		header.Add(name, value)
+	}
+
+	switch {
+	case *basicAuthEnv != "" && *bearerTokenEnv != "":
+		logger.Fatal("basic-auth-env and bearer-token-env cannot be used together")
+	case *basicAuthEnv != "":
+		authorization, err := httpclient.BasicAuthorizationFromEnv(*basicAuthEnv)
+		if err != nil {
+			logger.Fatal("Invalid basic-auth-env", "error", err)
+		}
+		header.Set("Authorization", authorization)
+	case *bearerTokenEnv != "":
+		authorization, err := httpclient.BearerAuthorizationFromEnv(*bearerTokenEnv)
+		if err != nil {
+			logger.Fatal("Invalid bearer-token-env", "error", err)
+		}
+		header.Set("Authorization", authorization)
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		logger.Fatal(err.Error())
+	}
+
+	if *cookiesFile != "" {
+		if err := httpclient.LoadCookiesFile(jar, *cookiesFile); err != nil {
+			logger.Fatal("Failed to load cookies", "error", err)
+		}
+	}
+
+	httpClientConfiguration := httpclient.Configuration{
+		Timeout:            time.Duration(*requestTimeoutSeconds) * time.Second,
+		Jar:                jar,
+		CACertFile:         *caCertFile,
+		ClientCertFile:     *clientCertFile,
+		ClientKeyFile:      *clientKeyFile,
+		InsecureSkipVerify: *insecure,
+		Resolve:            map[string]string{},
+	}
+
+	if *proxy != "" {
+		proxyURL, err := url.Parse(*proxy)
+		if err != nil || proxyURL.Host == "" {
+			logger.Fatal("Invalid proxy URL")
+		}
+		httpClientConfiguration.ProxyURL = proxyURL
+	}
+
+	for _, entry := range resolveEntries {
+		address, overriddenAddress, err := httpclient.ParseResolve(entry)
+		if err != nil {
+			logger.Fatal("Invalid resolve", "error", err)
+		}
+		httpClientConfiguration.Resolve[address] = overriddenAddress
+	}
+
+	client, err := httpclient.New(httpClientConfiguration)
+	if err != nil {
+		logger.Fatal(err.Error())
+	}
+
+	var loginForm *httpclient.LoginForm
+	if *loginURL != "" {
+		parsedLoginURL, err := seedURL.Parse(*loginURL)
+		if err != nil || parsedLoginURL.Scheme != "http" && parsedLoginURL.Scheme != "https" {
+			logger.Fatal("Invalid login-url")
+		}
+
+		loginHeader := header.Clone()
+		loginHeader.Set("User-Agent", *userAgent)
+		if *from != "" {
+			loginHeader.Set("From", *from)
+		}
+
+		loginForm = &httpclient.LoginForm{URL: *parsedLoginURL, Fields: url.Values{}, Header: loginHeader}
+		for _, field := range loginFields {
+			name, value, err := httpclient.ParseLoginField(field)
+			if err != nil {
+				logger.Fatal("Invalid login-field", "error", err)
+			}
+			loginForm.Fields.Add(name, value)
+		}
+	} else if len(loginFields) > 0 {
+		logger.Fatal("login-field can only be used together with login-url")
+	}
+
+	var observers []crawler.Observer
+	crawlLogger := logger
+	if *showProgress {
+		display := progress.New(os.Stderr, progress.IsTerminal(os.Stderr), *progressInterval)
+		display.MaxPages = *maxPages
+		observers = append(observers, display)
+		crawlLogger = logger.WithWriter(display)
+	}
+
+	if *metricsAddr != "" {
+		outputs.metricsAddr = *metricsAddr
+		outputs.metrics = metrics.NewCollector()
+		observers = append(observers, outputs.metrics)
 	}
 
 	return crawler.Configuration{
-			MaxConcurrentRequests: *maxConcurrentRequests,
-			MaxDepth:              *maxDepth,
-			SeedURL:               *seedURL,
-			ProgressWriter:        progressWriter,
-			SitemapWriter:         os.Stdout,
-		}, linkextractor.HTTPClient{
-			Do: (&http.Client{
-				Timeout: time.Duration(*requestTimeoutSeconds) * time.Second,
-			}).Do,
+		MaxConcurrentRequests: *maxConcurrentRequests,
+		MinConcurrentRequests: *minConcurrentRequests,
+		AdaptiveConcurrency:   *adaptiveConcurrency,
+		MaxDepth:              *maxDepth,
+		MaxBytes:              *maxBytes,
+		MaxPages:              *maxPages,
+		MaxPagesPerHost:       *maxPagesPerHost,
+		MaxPagesPerPathPrefix: maxPagesPerPathPrefix,
+		TrapRules:             trapRules,
+		Priority:              priority,
+		Deterministic:         *deterministic,
+		KeepUncrawledLinks:    *keepUncrawledLinks,
+		KeepFailedPages:       *keepFailedPages || command == "check" || command == "watch",
+		SeedURL:               *seedURL,
+		Observers:             observers,
+		Logger:                crawlLogger,
+		SitemapWriter:         os.Stdout,
+	}, linkextractor.HTTPClient{
+		Do:           client.Do,
+		UserAgent:    *userAgent,
+		RobotsToken:  *robotsToken,
+		Robots:       robots,
+		From:         *from,
+		Header:       header,
+		RetryPolicy:  retryPolicy,
+		Parsers:      parsers,
+		MaxBodyBytes: *maxBodyBytes,
+	}, loginForm, outputs
+}
+
+func currentVersion() string {
+	if version != "" {
+		return version
+	}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok && buildInfo.Main.Version != "" && buildInfo.Main.Version != "(devel)" {
+		return buildInfo.Main.Version
+	}
+
+	return "dev"
+}
+
+func parsePathPrefixBudget(budget string) (string, int, error) {
+	separator := strings.LastIndex(budget, "=")
+	if separator <= 0 {
+		return "", 0, fmt.Errorf("expected a budget of the form PREFIX=N")
+	}
+
+	prefix := budget[:separator]
+	if !strings.HasPrefix(prefix, "/") {
+		return "", 0, fmt.Errorf("path prefix %q should start with /", prefix)
+	}
+
+	maxPages, err := strconv.Atoi(budget[separator+1:])
+	if err != nil || maxPages <= 0 {
+		return "", 0, fmt.Errorf("%q is not a positive number of pages", budget[separator+1:])
+	}
+
+	return prefix, maxPages, nil
+}
+
+func parseStatusCodes(commaSeparated string) ([]int, error) {
+	result := make([]int, 0)
+
+	for _, field := range splitCommaSeparated(commaSeparated) {
+		statusCode, err := strconv.Atoi(field)
+		if err != nil || statusCode < 100 || statusCode > 599 {
+			return nil, fmt.Errorf("%q is not an HTTP status code", field)
 		}
+		result = append(result, statusCode)
+	}
+
+	return result, nil
+}
+
+// serveMetrics serves the metrics gathered while crawling, if an address was given for them, until
+// the returned function is called.
+func serveMetrics(outputs outputOptions) func() {
+	if outputs.metricsAddr == "" {
+		return func() {}
+	}
+
+	listener, err := net.Listen("tcp", outputs.metricsAddr)
+	if err != nil {
+		logger.Fatal("Failed to serve metrics", "address", outputs.metricsAddr, "error", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", outputs.metrics)
+
+	logger.Info("Serving metrics", "url", "http://"+listener.Addr().String()+"/metrics")
+	go func() {
+		_ = http.Serve(listener, mux)
+	}()
+
+	return func() {
+		_ = listener.Close()
+	}
+}
+
+func configureLogger(logFormat string, logLevel string, verbose bool) {
+	format, err := logging.ParseFormat(logFormat)
+	if err != nil {
+		logger.Fatal("log-format must be either text or json")
+	}
+
+	level, err := logging.ParseLevel(logLevel)
+	if err != nil {
+		logger.Fatal("log-level must be one of debug, info, warn or error")
+	}
+	if verbose {
+		level = logging.Debug
+	}
+
+	logger = logging.New(os.Stderr, format, level)
+}
+
+func formatStatusCodes(statusCodes []int) string {
+	fields := make([]string, 0)
+	for _, statusCode := range statusCodes {
+		fields = append(fields, strconv.Itoa(statusCode))
+	}
+
+	return strings.Join(fields, ",")
+}
+
+func splitCommaSeparated(commaSeparated string) []string {
+	result := make([]string, 0)
+
+	for _, field := range strings.Split(commaSeparated, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			result = append(result, field)
+		}
+	}
+
+	return result
 }
 
 func normaliseURL(rawurl string) (*url.URL, error) {
@@ -8,6 +8,7 @@ import (
 
 	"github.com/hilverd/sitemapper/crawler"
 	"github.com/hilverd/sitemapper/crawlertest"
+	"github.com/hilverd/sitemapper/logging"
 )
 
 func Test_parseCommandLineOptions(t *testing.T) {
@@ -26,22 +27,32 @@ func Test_parseCommandLineOptions(t *testing.T) {
 					"-v",
 					"-request-timeout", "10",
 					"-max-concurrent-requests", "2",
+					"-adaptive-concurrency",
 					"-max-depth", "3",
+					"-max-pages", "100",
+					"-max-pages-per-path-prefix", "/tags/=20",
 					"apple.com",
 				},
 			},
 			want: crawler.Configuration{
 				MaxConcurrentRequests: 2,
+				MinConcurrentRequests: 1,
+				AdaptiveConcurrency:   true,
 				MaxDepth:              3,
+				MaxPages:              100,
+				MaxPagesPerPathPrefix: map[string]int{"/tags/": 20},
 				SeedURL:               crawlertest.MakeURL("https://apple.com/"),
-				ProgressWriter:        os.Stderr,
 				SitemapWriter:         os.Stdout,
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, _ := parseCommandLineOptions(tt.args.arguments)
+			got, _, _, _ := parseCommandLineOptions("crawl", tt.args.arguments)
+			if got.Logger == nil || !got.Logger.Enabled(logging.Debug) || got.Logger.Format() != logging.Text {
+				t.Errorf("parseCommandLineOptions() got a logger that does not log debug messages as text")
+			}
+			got.Logger = nil
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("parseCommandLineOptions() got = %v, want %v", got, tt.want)
 			}
@@ -49,6 +60,38 @@ func Test_parseCommandLineOptions(t *testing.T) {
 	}
 }
 
+func Test_parseCommandLineOptions_outputs(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments []string
+		want      []output
+	}{
+		{
+			name:      "default output",
+			arguments: []string{"apple.com"},
+			want:      []output{{format: "text"}},
+		},
+		{
+			name:      "output files",
+			arguments: []string{"-output-xml", "public/", "-output-ndjson", "today.ndjson", "apple.com"},
+			want:      []output{{format: "ndjson", path: "today.ndjson"}, {format: "xml", path: "public/"}},
+		},
+		{
+			name:      "json is an alias for ndjson",
+			arguments: []string{"-output-json", "today.json", "apple.com"},
+			want:      []output{{format: "ndjson", path: "today.json"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, got := parseCommandLineOptions("crawl", tt.arguments)
+			if !reflect.DeepEqual(got.outputs, tt.want) {
+				t.Errorf("parseCommandLineOptions() outputs = %v, want %v", got.outputs, tt.want)
+			}
+		})
+	}
+}
+
 func Test_normaliseURL(t *testing.T) {
 	type args struct {
 		rawurl string
@@ -89,3 +132,84 @@ func Test_normaliseURL(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseStatusCodes(t *testing.T) {
+	tests := []struct {
+		name           string
+		commaSeparated string
+		want           []int
+		wantErr        bool
+	}{
+		{
+			name:           "valid status codes",
+			commaSeparated: "429, 503,",
+			want:           []int{429, 503},
+			wantErr:        false,
+		},
+		{
+			name:           "invalid status code",
+			commaSeparated: "429,oops",
+			want:           nil,
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStatusCodes(tt.commaSeparated)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseStatusCodes() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseStatusCodes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parsePathPrefixBudget(t *testing.T) {
+	tests := []struct {
+		name         string
+		budget       string
+		wantPrefix   string
+		wantMaxPages int
+		wantErr      bool
+	}{
+		{
+			name:         "valid budget",
+			budget:       "/tags/=200",
+			wantPrefix:   "/tags/",
+			wantMaxPages: 200,
+			wantErr:      false,
+		},
+		{
+			name:         "prefix containing an equals sign",
+			budget:       "/a=b/=5",
+			wantPrefix:   "/a=b/",
+			wantMaxPages: 5,
+			wantErr:      false,
+		},
+		{
+			name:    "relative prefix",
+			budget:  "tags/=200",
+			wantErr: true,
+		},
+		{
+			name:    "missing number",
+			budget:  "/tags/",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotPrefix, gotMaxPages, err := parsePathPrefixBudget(tt.budget)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parsePathPrefixBudget() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotPrefix != tt.wantPrefix || gotMaxPages != tt.wantMaxPages {
+				t.Errorf("parsePathPrefixBudget() = %v, %v, want %v, %v", gotPrefix, gotMaxPages, tt.wantPrefix, tt.wantMaxPages)
+			}
+		})
+	}
+}
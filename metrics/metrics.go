@@ -0,0 +1,166 @@
+// Package metrics keeps track of a crawl in the form of Prometheus metrics, which it serves in the
+// Prometheus text format.
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hilverd/sitemapper/crawler"
+	"github.com/hilverd/sitemapper/linkextractor"
+)
+
+// LatencyBuckets are the upper bounds, in seconds, of the buckets of the fetch latency histogram.
+var LatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// A Collector is an Observer that turns crawl events into metrics. It is also an http.Handler that
+// serves these metrics.
+type Collector struct {
+	mutex            sync.Mutex
+	fetches          map[string]int64
+	latencyBuckets   []int64
+	latencySum       float64
+	latencyCount     int64
+	queued           map[url.URL]bool
+	inFlight         int
+	concurrencyLimit int
+	wireBytes        int64
+	decodedBytes     int64
+	retries          int64
+	rejectedLinks    map[string]int64
+}
+
+func NewCollector() *Collector {
+	return &Collector{
+		fetches:        make(map[string]int64),
+		latencyBuckets: make([]int64, len(LatencyBuckets)),
+		queued:         make(map[url.URL]bool),
+		rejectedLinks:  make(map[string]int64),
+	}
+}
+
+func (collector *Collector) Observe(event crawler.Event) {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+
+	switch event := event.(type) {
+	case crawler.PageQueued:
+		collector.queued[event.URL] = true
+	case crawler.FetchStarted:
+		delete(collector.queued, event.URL)
+		collector.inFlight++
+	case crawler.FetchFinished:
+		collector.inFlight--
+		if errors.Is(event.Err, linkextractor.ErrDisallowedByRobots) {
+			break
+		}
+		collector.fetches[statusClass(event.StatusCode)]++
+		collector.wireBytes += event.WireBytes
+		collector.decodedBytes += event.DecodedBytes
+
+		seconds := event.Duration.Seconds()
+		for i, upperBound := range LatencyBuckets {
+			if seconds <= upperBound {
+				collector.latencyBuckets[i]++
+			}
+		}
+		collector.latencySum += seconds
+		collector.latencyCount++
+	case crawler.LinkRejected:
+		collector.rejectedLinks[event.Reason]++
+	case crawler.Retry:
+		collector.retries++
+	case crawler.ConcurrencyChanged:
+		collector.concurrencyLimit = event.Limit
+	case crawler.FrontierCleared:
+		collector.queued = make(map[url.URL]bool)
+	}
+}
+
+// statusClass returns the class of an HTTP status code, such as 2xx, or error if there was no response.
+func statusClass(statusCode int) string {
+	if statusCode < 100 || statusCode > 599 {
+		return "error"
+	}
+
+	return fmt.Sprintf("%dxx", statusCode/100)
+}
+
+func (collector *Collector) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = writer.Write(collector.Format())
+}
+
+// Format returns the metrics in the Prometheus text format.
+func (collector *Collector) Format() []byte {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+
+	var output bytes.Buffer
+
+	writeHeader(&output, "sitemapper_fetches_total", "counter", "Pages fetched, by HTTP status class (error if there was no response).")
+	for _, class := range sortedKeys(collector.fetches) {
+		fmt.Fprintf(&output, "sitemapper_fetches_total{status_class=%s} %d\n", quoteLabel(class), collector.fetches[class])
+	}
+
+	writeHeader(&output, "sitemapper_fetch_duration_seconds", "histogram", "Time taken to fetch a page, including retries.")
+	for i, upperBound := range LatencyBuckets {
+		fmt.Fprintf(&output, "sitemapper_fetch_duration_seconds_bucket{le=\"%s\"} %d\n", strconv.FormatFloat(upperBound, 'g', -1, 64), collector.latencyBuckets[i])
+	}
+	fmt.Fprintf(&output, "sitemapper_fetch_duration_seconds_bucket{le=\"+Inf\"} %d\n", collector.latencyCount)
+	fmt.Fprintf(&output, "sitemapper_fetch_duration_seconds_sum %s\n", strconv.FormatFloat(collector.latencySum, 'g', -1, 64))
+	fmt.Fprintf(&output, "sitemapper_fetch_duration_seconds_count %d\n", collector.latencyCount)
+
+	writeHeader(&output, "sitemapper_frontier_size", "gauge", "Pages queued to be fetched.")
+	fmt.Fprintf(&output, "sitemapper_frontier_size %d\n", len(collector.queued))
+
+	writeHeader(&output, "sitemapper_requests_in_flight", "gauge", "Pages being fetched.")
+	fmt.Fprintf(&output, "sitemapper_requests_in_flight %d\n", collector.inFlight)
+
+	if collector.concurrencyLimit > 0 {
+		writeHeader(&output, "sitemapper_concurrency_limit", "gauge", "Current limit on the number of concurrent requests set by adaptive concurrency.")
+		fmt.Fprintf(&output, "sitemapper_concurrency_limit %d\n", collector.concurrencyLimit)
+	}
+
+	writeHeader(&output, "sitemapper_transferred_bytes_total", "counter", "Bytes of response bodies transferred, before decompression.")
+	fmt.Fprintf(&output, "sitemapper_transferred_bytes_total %d\n", collector.wireBytes)
+
+	writeHeader(&output, "sitemapper_decoded_bytes_total", "counter", "Bytes of response bodies after decompression.")
+	fmt.Fprintf(&output, "sitemapper_decoded_bytes_total %d\n", collector.decodedBytes)
+
+	writeHeader(&output, "sitemapper_retries_total", "counter", "Requests that were retried.")
+	fmt.Fprintf(&output, "sitemapper_retries_total %d\n", collector.retries)
+
+	writeHeader(&output, "sitemapper_rejected_links_total", "counter", "Links that were not followed, by reason.")
+	for _, reason := range sortedKeys(collector.rejectedLinks) {
+		fmt.Fprintf(&output, "sitemapper_rejected_links_total{reason=%s} %d\n", quoteLabel(reason), collector.rejectedLinks[reason])
+	}
+
+	return output.Bytes()
+}
+
+func writeHeader(output *bytes.Buffer, name string, metricType string, help string) {
+	fmt.Fprintf(output, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func quoteLabel(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+func sortedKeys(counts map[string]int64) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
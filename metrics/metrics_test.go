@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hilverd/sitemapper/crawler"
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+func TestCollector(t *testing.T) {
+	home := crawlertest.MakeURL("https://example.com/")
+	about := crawlertest.MakeURL("https://example.com/about")
+	gone := crawlertest.MakeURL("https://example.com/gone")
+	blog := crawlertest.MakeURL("https://example.com/blog")
+
+	collector := NewCollector()
+	for _, event := range []crawler.Event{
+		crawler.PageQueued{URL: home},
+		crawler.FetchStarted{URL: home},
+		crawler.FetchFinished{URL: home, StatusCode: 200, Duration: 80 * time.Millisecond, WireBytes: 100, DecodedBytes: 300},
+		crawler.PageQueued{URL: about, Depth: 1},
+		crawler.PageQueued{URL: gone, Depth: 1},
+		crawler.LinkRejected{From: home, URL: crawlertest.MakeURL("https://example.org/"), Depth: 1, Reason: crawler.ReasonOtherHost},
+		crawler.LinkRejected{From: home, URL: crawlertest.MakeURL("https://example.com/a/a/a/"), Depth: 1, Reason: `repeating "path" segments`, Trap: true},
+		crawler.FetchStarted{URL: gone, Depth: 1},
+		crawler.Retry{URL: gone, Retries: 1, Delay: time.Second, Err: errors.New("timeout")},
+		crawler.FetchFinished{URL: gone, Depth: 1, StatusCode: 404, Duration: 3 * time.Second, Err: errors.New("Got a 404 Not Found response")},
+		crawler.FetchStarted{URL: about, Depth: 1},
+		crawler.PageQueued{URL: blog, Depth: 2},
+		crawler.FrontierCleared{Pages: 1},
+	} {
+		collector.Observe(event)
+	}
+
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	response, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := response.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	for _, want := range []string{
+		`sitemapper_fetches_total{status_class="2xx"} 1`,
+		`sitemapper_fetches_total{status_class="4xx"} 1`,
+		`sitemapper_fetch_duration_seconds_bucket{le="0.05"} 0`,
+		`sitemapper_fetch_duration_seconds_bucket{le="0.1"} 1`,
+		`sitemapper_fetch_duration_seconds_bucket{le="5"} 2`,
+		`sitemapper_fetch_duration_seconds_bucket{le="+Inf"} 2`,
+		`sitemapper_fetch_duration_seconds_sum 3.08`,
+		`sitemapper_fetch_duration_seconds_count 2`,
+		"sitemapper_frontier_size 0",
+		"sitemapper_requests_in_flight 1",
+		"sitemapper_transferred_bytes_total 100",
+		"sitemapper_decoded_bytes_total 300",
+		"sitemapper_retries_total 1",
+		`sitemapper_rejected_links_total{reason="other-host"} 1`,
+		`sitemapper_rejected_links_total{reason="repeating \"path\" segments"} 1`,
+		"# TYPE sitemapper_fetch_duration_seconds histogram",
+	} {
+		if !strings.Contains(string(body), want+"\n") {
+			t.Errorf("metrics do not contain %q:\n%s", want, body)
+		}
+	}
+
+	if strings.Contains(string(body), "sitemapper_concurrency_limit") {
+		t.Errorf("metrics contain a concurrency limit without adaptive concurrency:\n%s", body)
+	}
+}
+
+func Test_statusClass(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		want       string
+	}{
+		{statusCode: 0, want: "error"},
+		{statusCode: 200, want: "2xx"},
+		{statusCode: 301, want: "3xx"},
+		{statusCode: 503, want: "5xx"},
+	}
+	for _, tt := range tests {
+		if got := statusClass(tt.statusCode); got != tt.want {
+			t.Errorf("statusClass(%d) = %v, want %v", tt.statusCode, got, tt.want)
+		}
+	}
+}
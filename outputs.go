@@ -0,0 +1,341 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hilverd/sitemapper/metrics"
+	"github.com/hilverd/sitemapper/sitemap"
+)
+
+// An output is a file, or standard output if path is empty, that a crawl is written to in the given
+// format.
+type output struct {
+	format string
+	path   string
+}
+
+type outputOptions struct {
+	outputs     []output
+	xmlBaseURL  url.URL
+	tables      tableOptions
+	metricsAddr string             // address to serve metrics on while crawling, if any
+	metrics     *metrics.Collector // gathers the metrics to serve; already one of the observers
+}
+
+var outputFormats = []string{"text", "ndjson", "csv", "tsv", "xml", "html"}
+
+var outputExtensions = map[string]string{
+	"text":   ".txt",
+	"ndjson": ".ndjson",
+	"csv":    ".csv",
+	"tsv":    ".tsv",
+	"xml":    ".xml",
+	"html":   ".html",
+}
+
+var tableSeparators = map[string]rune{
+	"csv": ',',
+	"tsv": '\t',
+}
+
+// tableOptions says which tables csv and tsv outputs consist of, and which columns the tables have.
+// If no tables are given, files get both the pages and the edges table, while standard output only
+// gets the pages table.
+type tableOptions struct {
+	tables      []string
+	pageColumns []string
+	edgeColumns []string
+}
+
+func parseTableOptions(tables string, pageColumns string, edgeColumns string) (tableOptions, error) {
+	var result tableOptions
+	var err error
+
+	if tables != "" {
+		if result.tables, err = sitemap.ParseColumns(tables, []string{sitemap.PagesTable, sitemap.EdgesTable}, nil); err != nil {
+			return result, fmt.Errorf("Invalid tables: %w", err)
+		}
+	}
+	if result.pageColumns, err = sitemap.ParseColumns(pageColumns, sitemap.PageColumns, sitemap.DefaultPageColumns); err != nil {
+		return result, fmt.Errorf("Invalid page columns: %w", err)
+	}
+	if result.edgeColumns, err = sitemap.ParseColumns(edgeColumns, sitemap.EdgeColumns, sitemap.EdgeColumns); err != nil {
+		return result, fmt.Errorf("Invalid edge columns: %w", err)
+	}
+
+	return result, nil
+}
+
+func (options tableOptions) tablesFor(output output) ([]string, error) {
+	switch {
+	case len(options.tables) > 1 && output.path == "":
+		return nil, fmt.Errorf("Only one table can be written to standard output, so tables must be either pages or edges")
+	case len(options.tables) > 0:
+		return options.tables, nil
+	case output.path == "":
+		return []string{sitemap.PagesTable}, nil
+	default:
+		return []string{sitemap.PagesTable, sitemap.EdgesTable}, nil
+	}
+}
+
+// tableWriter returns a writer for one of the tables of a csv or tsv output.
+func (options tableOptions) tableWriter(table string, format string, writer io.Writer) sitemap.PageWriter {
+	if table == sitemap.EdgesTable {
+		return sitemap.NewEdgesTableWriter(writer, tableSeparators[format], options.edgeColumns)
+	}
+
+	return sitemap.NewPagesTableWriter(writer, tableSeparators[format], options.pageColumns)
+}
+
+func (output output) isDirectory() bool {
+	if strings.HasSuffix(output.path, "/") || strings.HasSuffix(output.path, string(os.PathSeparator)) {
+		return true
+	}
+
+	info, err := os.Stat(output.path)
+	return err == nil && info.IsDir()
+}
+
+// fileName returns the name of the file to write to, which is named sitemap with the extension of the
+// format if path is a directory. Directories that do not exist yet are created.
+func (output output) fileName() (string, error) {
+	if !output.isDirectory() {
+		return output.path, nil
+	}
+
+	if err := os.MkdirAll(output.path, 0755); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(output.path, "sitemap"+outputExtensions[output.format]), nil
+}
+
+// tableFileName returns the name of the file to write a table to. In a directory, tables are named
+// after themselves, while a file name such as crawl.csv becomes crawl-pages.csv and crawl-edges.csv
+// if there is more than one table.
+func (output output) tableFileName(table string, tables int) (string, error) {
+	switch {
+	case output.isDirectory():
+		if err := os.MkdirAll(output.path, 0755); err != nil {
+			return "", err
+		}
+		return filepath.Join(output.path, table+outputExtensions[output.format]), nil
+	case tables == 1:
+		return output.path, nil
+	default:
+		extension := filepath.Ext(output.path)
+		return strings.TrimSuffix(output.path, extension) + "-" + table + extension, nil
+	}
+}
+
+// An atomicFile is written under a temporary name in the same directory, and only renamed to its
+// real name when committed. This way an existing file is replaced in one go, and a crawl that fails
+// or is interrupted does not leave a half-written file behind.
+type atomicFile struct {
+	*os.File
+	name string
+}
+
+// outputFiles keeps track of the atomic files created for a crawl, so they can all be committed at
+// the end or removed if something goes wrong.
+type outputFiles struct {
+	mutex sync.Mutex
+	files []*atomicFile
+	done  bool
+}
+
+func (files *outputFiles) create(name string) (*atomicFile, error) {
+	files.mutex.Lock()
+	defer files.mutex.Unlock()
+
+	if files.done {
+		return nil, fmt.Errorf("Failed to create %s: output files were already closed", name)
+	}
+
+	file, err := createTempFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &atomicFile{File: file, name: name}
+	files.files = append(files.files, result)
+	return result, nil
+}
+
+// createTempFile creates a file with a random name next to the file called name. Unlike
+// ioutil.TempFile, it creates the file with the permissions that new files normally get, taking the
+// umask into account.
+func createTempFile(name string) (*os.File, error) {
+	for {
+		tempName := filepath.Join(filepath.Dir(name), "."+filepath.Base(name)+".tmp-"+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		file, err := os.OpenFile(tempName, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if !errors.Is(err, os.ErrExist) {
+			return file, err
+		}
+	}
+}
+
+func (files *outputFiles) commit() error {
+	files.mutex.Lock()
+	defer files.mutex.Unlock()
+
+	files.done = true
+
+	for i, file := range files.files {
+		err := file.Close()
+		if errors.Is(err, os.ErrClosed) {
+			err = nil
+		}
+		if existing, statErr := os.Stat(file.name); err == nil && statErr == nil {
+			err = os.Chmod(file.Name(), existing.Mode().Perm())
+		}
+		if err == nil {
+			err = os.Rename(file.Name(), file.name)
+		}
+		if err != nil {
+			for _, remaining := range files.files[i:] {
+				os.Remove(remaining.Name())
+			}
+			return fmt.Errorf("Failed to write %s: %s", file.name, err)
+		}
+	}
+
+	return nil
+}
+
+func (files *outputFiles) abort() {
+	files.mutex.Lock()
+	defer files.mutex.Unlock()
+
+	if files.done {
+		return
+	}
+	files.done = true
+
+	for _, file := range files.files {
+		file.Close()
+		os.Remove(file.Name())
+	}
+}
+
+// openOutput returns the writer for an output other than an XML sitemap, which may consist of several
+// files.
+func openOutput(output output, stdout io.Writer, files *outputFiles) (io.Writer, error) {
+	if output.path == "" {
+		return stdout, nil
+	}
+
+	fileName, err := output.fileName()
+	if err != nil {
+		return nil, err
+	}
+
+	return files.create(fileName)
+}
+
+// pageWriterFor returns a writer for an output that is not in the text format, which can only be
+// written once the crawl is done.
+func pageWriterFor(output output, options outputOptions, stdout io.Writer, files *outputFiles) (sitemap.PageWriter, error) {
+	if _, ok := tableSeparators[output.format]; ok {
+		return tableWritersFor(output, options.tables, stdout, files)
+	}
+
+	xmlBaseURL := options.xmlBaseURL
+	if output.format == "xml" {
+		if output.path == "" {
+			return sitemap.NewXMLWriter(xmlBaseURL, "sitemap.xml", func(fileName string) (io.WriteCloser, error) {
+				if fileName != "sitemap.xml" {
+					return nil, fmt.Errorf("Too many pages for a single XML sitemap; use -output-xml DIRECTORY to split it over several files")
+				}
+				return nopCloser{stdout}, nil
+			}), nil
+		}
+
+		fileName, err := output.fileName()
+		if err != nil {
+			return nil, err
+		}
+
+		directory := filepath.Dir(fileName)
+		return sitemap.NewXMLWriter(xmlBaseURL, filepath.Base(fileName), func(fileName string) (io.WriteCloser, error) {
+			return files.create(filepath.Join(directory, fileName))
+		}), nil
+	}
+
+	writer, err := openOutput(output, stdout, files)
+	if err != nil {
+		return nil, err
+	}
+
+	switch output.format {
+	case "html":
+		return sitemap.NewHTMLWriter(writer), nil
+	default:
+		return sitemap.NewNDJSONWriter(writer), nil
+	}
+}
+
+func tableWritersFor(output output, options tableOptions, stdout io.Writer, files *outputFiles) (sitemap.PageWriter, error) {
+	tables, err := options.tablesFor(output)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(multiPageWriter, 0, len(tables))
+	for _, table := range tables {
+		var writer io.Writer = stdout
+		if output.path != "" {
+			fileName, err := output.tableFileName(table, len(tables))
+			if err != nil {
+				return nil, err
+			}
+			if writer, err = files.create(fileName); err != nil {
+				return nil, err
+			}
+		}
+
+		result = append(result, options.tableWriter(table, output.format, writer))
+	}
+
+	return result, nil
+}
+
+type multiPageWriter []sitemap.PageWriter
+
+func (writers multiPageWriter) WritePage(URL url.URL, page sitemap.Page) error {
+	for _, writer := range writers {
+		if err := writer.WritePage(URL, page); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (writers multiPageWriter) Flush() error {
+	for _, writer := range writers {
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func Test_output_fileName(t *testing.T) {
+	directory := t.TempDir()
+
+	tests := []struct {
+		name   string
+		output output
+		want   string
+	}{
+		{
+			name:   "file",
+			output: output{format: "csv", path: filepath.Join(directory, "crawl.csv")},
+			want:   filepath.Join(directory, "crawl.csv"),
+		},
+		{
+			name:   "existing directory",
+			output: output{format: "ndjson", path: directory},
+			want:   filepath.Join(directory, "sitemap.ndjson"),
+		},
+		{
+			name:   "new directory",
+			output: output{format: "xml", path: filepath.Join(directory, "xml") + "/"},
+			want:   filepath.Join(directory, "xml", "sitemap.xml"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.output.fileName()
+			if err != nil {
+				t.Fatalf("fileName() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("fileName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_output_tableFileName(t *testing.T) {
+	directory := t.TempDir()
+
+	tests := []struct {
+		name   string
+		output output
+		tables int
+		want   string
+	}{
+		{
+			name:   "file with one table",
+			output: output{format: "csv", path: filepath.Join(directory, "crawl.csv")},
+			tables: 1,
+			want:   filepath.Join(directory, "crawl.csv"),
+		},
+		{
+			name:   "file with two tables",
+			output: output{format: "csv", path: filepath.Join(directory, "crawl.csv")},
+			tables: 2,
+			want:   filepath.Join(directory, "crawl-edges.csv"),
+		},
+		{
+			name:   "directory",
+			output: output{format: "tsv", path: filepath.Join(directory, "tables") + "/"},
+			tables: 2,
+			want:   filepath.Join(directory, "tables", "edges.tsv"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.output.tableFileName("edges", tt.tables)
+			if err != nil {
+				t.Fatalf("tableFileName() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("tableFileName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_outputFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		commit    bool
+		wantFiles []string
+		wantText  string
+	}{
+		{
+			name:      "commit replaces existing files",
+			commit:    true,
+			wantFiles: []string{"new.txt", "sitemap.txt"},
+			wantText:  "new",
+		},
+		{
+			name:      "abort leaves existing files alone",
+			commit:    false,
+			wantFiles: []string{"sitemap.txt"},
+			wantText:  "old",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directory := t.TempDir()
+			existingFile := filepath.Join(directory, "sitemap.txt")
+			if err := ioutil.WriteFile(existingFile, []byte("old"), 0640); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(existingFile, 0640); err != nil {
+				t.Fatal(err)
+			}
+
+			files := &outputFiles{}
+			for _, name := range []string{existingFile, filepath.Join(directory, "new.txt")} {
+				file, err := files.create(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := file.WriteString("new"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tt.commit {
+				if err := files.commit(); err != nil {
+					t.Fatalf("commit() error = %v", err)
+				}
+			} else {
+				files.abort()
+			}
+
+			entries, err := ioutil.ReadDir(directory)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotFiles := make([]string, 0)
+			for _, entry := range entries {
+				gotFiles = append(gotFiles, entry.Name())
+			}
+			sort.Strings(gotFiles)
+
+			if !reflect.DeepEqual(gotFiles, tt.wantFiles) {
+				t.Errorf("files = %v, want %v", gotFiles, tt.wantFiles)
+			}
+			if gotText, _ := ioutil.ReadFile(existingFile); string(gotText) != tt.wantText {
+				t.Errorf("%s contains %q, want %q", existingFile, gotText, tt.wantText)
+			}
+			if info, err := os.Stat(existingFile); err != nil {
+				t.Fatal(err)
+			} else if info.Mode().Perm() != 0640 {
+				t.Errorf("%s has mode %v, want it to keep -rw-r-----", existingFile, info.Mode().Perm())
+			}
+
+			if tt.commit {
+				probe, err := os.OpenFile(filepath.Join(t.TempDir(), "probe"), os.O_CREATE|os.O_WRONLY, 0666)
+				if err != nil {
+					t.Fatal(err)
+				}
+				probe.Close()
+				wantMode, err := os.Stat(probe.Name())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info, err := os.Stat(filepath.Join(directory, "new.txt")); err != nil {
+					t.Fatal(err)
+				} else if info.Mode().Perm() != wantMode.Mode().Perm() {
+					t.Errorf("new.txt has mode %v, want %v like other new files", info.Mode().Perm(), wantMode.Mode().Perm())
+				}
+			}
+		})
+	}
+}
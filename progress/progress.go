@@ -0,0 +1,244 @@
+// Package progress shows how a crawl is getting on, either as a status line that is refreshed in
+// place on a terminal, or as summary lines written at regular intervals.
+package progress
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hilverd/sitemapper/crawler"
+	"github.com/hilverd/sitemapper/linkextractor"
+)
+
+// rateWindow is the period over which the number of pages per second is measured.
+const rateWindow = 10 * time.Second
+
+// refreshInterval is how often the status line is redrawn on a terminal.
+const refreshInterval = 250 * time.Millisecond
+
+// A Snapshot describes the progress of a crawl at a given moment.
+type Snapshot struct {
+	Pages     int   `json:"pages"`
+	Queued    int   `json:"queued"`
+	InFlight  int   `json:"in_flight"`
+	Errors    int   `json:"errors"`
+	WireBytes int64 `json:"wire_bytes"`
+	Depth     int   `json:"depth"`
+
+	// Concurrency is the limit on requests in flight when using adaptive concurrency, and zero otherwise.
+	Concurrency int `json:"concurrency,omitempty"`
+}
+
+// A Tracker is an Observer that keeps count of how a crawl is getting on.
+type Tracker struct {
+	mutex    sync.Mutex
+	snapshot Snapshot
+	queued   map[url.URL]bool
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{queued: make(map[url.URL]bool)}
+}
+
+func (tracker *Tracker) Observe(event crawler.Event) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	switch event := event.(type) {
+	case crawler.PageQueued:
+		tracker.queued[event.URL] = true
+	case crawler.FetchStarted:
+		delete(tracker.queued, event.URL)
+		tracker.snapshot.InFlight++
+		if event.Depth > tracker.snapshot.Depth {
+			tracker.snapshot.Depth = event.Depth
+		}
+	case crawler.FetchFinished:
+		tracker.snapshot.InFlight--
+		if errors.Is(event.Err, linkextractor.ErrDisallowedByRobots) {
+			break
+		}
+		tracker.snapshot.Pages++
+		tracker.snapshot.WireBytes += event.WireBytes
+		if event.Err != nil {
+			tracker.snapshot.Errors++
+		}
+	case crawler.ConcurrencyChanged:
+		tracker.snapshot.Concurrency = event.Limit
+	case crawler.FrontierCleared:
+		tracker.queued = make(map[url.URL]bool)
+	}
+}
+
+func (tracker *Tracker) Snapshot() Snapshot {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	result := tracker.snapshot
+	result.Queued = len(tracker.queued)
+	return result
+}
+
+type sample struct {
+	at    time.Time
+	pages int
+}
+
+// A Display is an Observer that keeps track of a crawl and shows its progress. If MaxPages is set,
+// it is taken into account when estimating how long the crawl will take. A Display can be used for
+// one crawl after another, but not for several at the same time.
+type Display struct {
+	MaxPages    int
+	writer      io.Writer
+	interactive bool
+	interval    time.Duration
+	now         func() time.Time
+
+	tracker    *Tracker
+	mutex      sync.Mutex
+	done       chan struct{} // closed when the current crawl finishes; nil between crawls
+	startedAt  time.Time
+	samples    []sample
+	statusLine string
+}
+
+// New returns a Display that writes to writer. If interactive is true, the status line is refreshed in
+// place, otherwise a summary line is written every interval.
+func New(writer io.Writer, interactive bool, interval time.Duration) *Display {
+	if interactive {
+		interval = refreshInterval
+	}
+
+	return &Display{
+		writer:      writer,
+		interactive: interactive,
+		interval:    interval,
+		now:         time.Now,
+		tracker:     NewTracker(),
+	}
+}
+
+// IsTerminal reports whether file is a terminal rather than, say, a file or a pipe.
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (display *Display) Observe(event crawler.Event) {
+	display.mutex.Lock()
+	defer display.mutex.Unlock()
+
+	if display.done == nil {
+		display.start()
+	}
+	display.tracker.Observe(event)
+
+	if _, ok := event.(crawler.CrawlFinished); ok {
+		close(display.done)
+		display.done = nil
+		display.refresh()
+		if display.interactive {
+			fmt.Fprintln(display.writer)
+			display.statusLine = ""
+		}
+	}
+}
+
+// Write writes a message, such as a log message, without it getting mixed up with the status line.
+func (display *Display) Write(p []byte) (int, error) {
+	display.mutex.Lock()
+	defer display.mutex.Unlock()
+
+	if !display.interactive || display.statusLine == "" {
+		return display.writer.Write(p)
+	}
+
+	fmt.Fprint(display.writer, "\r\033[K")
+	n, err := display.writer.Write(p)
+	fmt.Fprint(display.writer, display.statusLine)
+	return n, err
+}
+
+// start starts keeping track of a new crawl. It is called with the mutex held.
+func (display *Display) start() {
+	done := make(chan struct{})
+	display.done = done
+	display.tracker = NewTracker()
+	display.startedAt = display.now()
+	display.samples = []sample{{at: display.startedAt}}
+
+	go func() {
+		ticker := time.NewTicker(display.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				display.mutex.Lock()
+				if display.done == done {
+					display.refresh()
+				}
+				display.mutex.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+func (display *Display) refresh() {
+	display.statusLine = display.status(display.now())
+
+	if display.interactive {
+		fmt.Fprintf(display.writer, "\r\033[K%s", display.statusLine)
+	} else {
+		fmt.Fprintln(display.writer, display.statusLine)
+	}
+}
+
+// status returns a line describing the progress of the crawl at the given time.
+func (display *Display) status(now time.Time) string {
+	snapshot := display.tracker.Snapshot()
+
+	display.samples = append(display.samples, sample{at: now, pages: snapshot.Pages})
+	for len(display.samples) > 2 && now.Sub(display.samples[1].at) >= rateWindow {
+		display.samples = display.samples[1:]
+	}
+
+	rate := 0.0
+	if oldest := display.samples[0]; now.After(oldest.at) {
+		rate = float64(snapshot.Pages-oldest.pages) / now.Sub(oldest.at).Seconds()
+	}
+
+	inFlight := fmt.Sprintf("%d in flight", snapshot.InFlight)
+	if snapshot.Concurrency > 0 {
+		inFlight += fmt.Sprintf(" (limit %d)", snapshot.Concurrency)
+	}
+
+	parts := []string{
+		fmt.Sprintf("%d pages", snapshot.Pages),
+		fmt.Sprintf("%d queued", snapshot.Queued),
+		inFlight,
+		fmt.Sprintf("%.1f/s", rate),
+		fmt.Sprintf("%d errors", snapshot.Errors),
+		crawler.FormatBytes(snapshot.WireBytes),
+		fmt.Sprintf("depth %d", snapshot.Depth),
+	}
+
+	remaining := snapshot.Queued + snapshot.InFlight
+	if display.MaxPages > 0 && display.MaxPages-snapshot.Pages < remaining {
+		remaining = display.MaxPages - snapshot.Pages
+	}
+	if rate > 0 && remaining > 0 {
+		eta := time.Duration(float64(remaining) / rate * float64(time.Second))
+		parts = append(parts, "ETA "+eta.Round(time.Second).String())
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,156 @@
+package progress
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hilverd/sitemapper/crawler"
+	"github.com/hilverd/sitemapper/crawlertest"
+	"github.com/hilverd/sitemapper/linkextractor"
+)
+
+func TestDisplay_status(t *testing.T) {
+	startedAt := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	home := crawlertest.MakeURL("https://example.com/")
+	about := crawlertest.MakeURL("https://example.com/about")
+	blog := crawlertest.MakeURL("https://example.com/blog")
+
+	tests := []struct {
+		name     string
+		maxPages int
+		events   []crawler.Event
+		elapsed  time.Duration
+		want     string
+	}{
+		{
+			name:    "nothing done yet",
+			events:  []crawler.Event{crawler.PageQueued{URL: home}},
+			elapsed: time.Second,
+			want:    "0 pages, 1 queued, 0 in flight, 0.0/s, 0 errors, 0 B, depth 0",
+		},
+		{
+			name: "pages done, failed and queued",
+			events: []crawler.Event{
+				crawler.PageQueued{URL: home},
+				crawler.FetchStarted{URL: home},
+				crawler.FetchFinished{URL: home, WireBytes: 2048},
+				crawler.PageQueued{URL: about, Depth: 1},
+				crawler.PageQueued{URL: blog, Depth: 1},
+				crawler.PageQueued{URL: blog, Depth: 1},
+				crawler.FetchStarted{URL: about, Depth: 1},
+				crawler.FetchFinished{URL: about, Depth: 1, Err: errors.New("Got a 404 Not Found response")},
+			},
+			elapsed: 4 * time.Second,
+			want:    "2 pages, 1 queued, 0 in flight, 0.5/s, 1 errors, 2.0 KiB, depth 1, ETA 2s",
+		},
+		{
+			name:     "estimate takes the maximum number of pages into account",
+			maxPages: 3,
+			events: []crawler.Event{
+				crawler.PageQueued{URL: home},
+				crawler.FetchStarted{URL: home},
+				crawler.FetchFinished{URL: home},
+				crawler.PageQueued{URL: about, Depth: 1},
+				crawler.PageQueued{URL: blog, Depth: 1},
+				crawler.FetchStarted{URL: about, Depth: 1},
+			},
+			elapsed: 2 * time.Second,
+			want:    "1 pages, 1 queued, 1 in flight, 0.5/s, 0 errors, 0 B, depth 1, ETA 4s",
+		},
+		{
+			name: "queued pages are dropped when the frontier is cleared",
+			events: []crawler.Event{
+				crawler.PageQueued{URL: home},
+				crawler.FetchStarted{URL: home},
+				crawler.FetchFinished{URL: home},
+				crawler.PageQueued{URL: about, Depth: 1},
+				crawler.PageQueued{URL: blog, Depth: 1},
+				crawler.FrontierCleared{Pages: 2},
+			},
+			elapsed: time.Second,
+			want:    "1 pages, 0 queued, 0 in flight, 1.0/s, 0 errors, 0 B, depth 0",
+		},
+		{
+			name: "pages that robots.txt disallows are not counted",
+			events: []crawler.Event{
+				crawler.PageQueued{URL: home},
+				crawler.FetchStarted{URL: home},
+				crawler.FetchFinished{URL: home},
+				crawler.PageQueued{URL: about, Depth: 1},
+				crawler.FetchStarted{URL: about, Depth: 1},
+				crawler.FetchFinished{URL: about, Depth: 1, Err: linkextractor.ErrDisallowedByRobots},
+			},
+			elapsed: time.Second,
+			want:    "1 pages, 0 queued, 0 in flight, 1.0/s, 0 errors, 0 B, depth 1",
+		},
+		{
+			name: "adaptive concurrency limit",
+			events: []crawler.Event{
+				crawler.ConcurrencyChanged{Limit: 1},
+				crawler.PageQueued{URL: home},
+				crawler.FetchStarted{URL: home},
+				crawler.FetchFinished{URL: home},
+				crawler.ConcurrencyChanged{Limit: 2},
+			},
+			elapsed: time.Second,
+			want:    "1 pages, 0 queued, 0 in flight (limit 2), 1.0/s, 0 errors, 0 B, depth 0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			display := New(&bytes.Buffer{}, false, time.Hour)
+			display.MaxPages = tt.maxPages
+			display.now = func() time.Time { return startedAt }
+			for _, event := range tt.events {
+				display.Observe(event)
+			}
+
+			if got := display.status(startedAt.Add(tt.elapsed)); got != tt.want {
+				t.Errorf("status() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisplay_Write(t *testing.T) {
+	var output bytes.Buffer
+	display := New(&output, true, 0)
+	display.interval = time.Hour
+	display.now = func() time.Time { return time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+	display.Observe(crawler.PageQueued{URL: crawlertest.MakeURL("https://example.com/")})
+	display.mutex.Lock()
+	display.refresh()
+	display.mutex.Unlock()
+	if _, err := display.Write([]byte("WARN Something happened\n")); err != nil {
+		t.Fatal(err)
+	}
+	display.Observe(crawler.CrawlFinished{})
+
+	status := "0 pages, 1 queued, 0 in flight, 0.0/s, 0 errors, 0 B, depth 0"
+	want := "\r\033[K" + status + "\r\033[KWARN Something happened\n" + status + "\r\033[K" + status + "\n"
+	if got := output.String(); got != want {
+		t.Errorf("Display wrote %q, want %q", got, want)
+	}
+}
+
+func TestDisplay_Observe_severalCrawls(t *testing.T) {
+	var output bytes.Buffer
+	display := New(&output, false, time.Hour)
+	display.now = func() time.Time { return time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC) }
+	home := crawlertest.MakeURL("https://example.com/")
+
+	for i := 0; i < 2; i++ {
+		display.Observe(crawler.PageQueued{URL: home})
+		display.Observe(crawler.FetchStarted{URL: home})
+		display.Observe(crawler.FetchFinished{URL: home})
+		display.Observe(crawler.CrawlFinished{})
+	}
+
+	status := "1 pages, 0 queued, 0 in flight, 0.0/s, 0 errors, 0 B, depth 0\n"
+	if got, want := output.String(), status+status; got != want {
+		t.Errorf("Display wrote %q, want %q", got, want)
+	}
+}
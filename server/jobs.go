@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/hilverd/sitemapper/crawler"
+	"github.com/hilverd/sitemapper/progress"
+)
+
+type State string
+
+const (
+	Queued    State = "queued"
+	Running   State = "running"
+	Finished  State = "finished"
+	Cancelled State = "cancelled"
+	Failed    State = "failed"
+)
+
+// A JobRequest describes a crawl to be run by the server. Settings that are left out are taken from
+// the options the server was started with.
+type JobRequest struct {
+	SeedURL               string `json:"seed_url"`
+	MaxDepth              *int   `json:"max_depth,omitempty"`
+	MaxPages              *int   `json:"max_pages,omitempty"`
+	MaxPagesPerHost       *int   `json:"max_pages_per_host,omitempty"`
+	MaxBytes              *int64 `json:"max_bytes,omitempty"`
+	MaxConcurrentRequests *int   `json:"max_concurrent_requests,omitempty"`
+	Deterministic         *bool  `json:"deterministic,omitempty"`
+	KeepFailedPages       *bool  `json:"keep_failed_pages,omitempty"`
+}
+
+// A Job is a crawl that was submitted to the server, as reported by its API.
+type Job struct {
+	ID         string            `json:"id"`
+	State      State             `json:"state"`
+	Request    JobRequest        `json:"request"`
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+	Progress   progress.Snapshot `json:"progress"`
+	Report     string            `json:"report,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+func (job Job) done() bool {
+	return job.State == Finished || job.State == Cancelled || job.State == Failed
+}
+
+type runningJob struct {
+	Job
+	configuration crawler.Configuration
+	tracker       *progress.Tracker
+	ctx           context.Context
+	cancel        context.CancelFunc
+}
+
+// configuration returns the crawler configuration for a request, based on the server's defaults.
+func (server *Server) configuration(request JobRequest) (crawler.Configuration, error) {
+	result := server.options.Defaults
+	result.Observers = nil
+
+	seedURL, err := url.Parse(request.SeedURL)
+	if err != nil || seedURL.Scheme != "http" && seedURL.Scheme != "https" || seedURL.Host == "" {
+		return result, fmt.Errorf("seed_url must be an absolute http or https URL")
+	}
+	if seedURL.Path == "" {
+		seedURL.Path = "/"
+	}
+	if !server.hostIsAllowed(seedURL.Hostname()) {
+		return result, fmt.Errorf("Crawling %s is not allowed", seedURL.Hostname())
+	}
+	result.SeedURL = *seedURL
+
+	for _, limit := range []struct {
+		name  string
+		value *int
+	}{
+		{"max_depth", request.MaxDepth},
+		{"max_pages", request.MaxPages},
+		{"max_pages_per_host", request.MaxPagesPerHost},
+	} {
+		if limit.value != nil && *limit.value < 0 {
+			return result, fmt.Errorf("%s must be at least zero", limit.name)
+		}
+	}
+
+	if request.MaxDepth != nil {
+		result.MaxDepth = *request.MaxDepth
+	}
+	if request.MaxPages != nil {
+		result.MaxPages = *request.MaxPages
+	}
+	if request.MaxPagesPerHost != nil {
+		result.MaxPagesPerHost = *request.MaxPagesPerHost
+	}
+	if request.MaxBytes != nil {
+		if *request.MaxBytes < 0 {
+			return result, fmt.Errorf("max_bytes must be at least zero")
+		}
+		result.MaxBytes = *request.MaxBytes
+	}
+	if request.MaxConcurrentRequests != nil {
+		maxConcurrentRequests := *request.MaxConcurrentRequests
+		if maxConcurrentRequests <= 0 || maxConcurrentRequests > server.options.Defaults.MaxConcurrentRequests {
+			return result, fmt.Errorf("max_concurrent_requests must be between 1 and %d", server.options.Defaults.MaxConcurrentRequests)
+		}
+		result.MaxConcurrentRequests = maxConcurrentRequests
+		if result.MinConcurrentRequests > maxConcurrentRequests {
+			result.MinConcurrentRequests = maxConcurrentRequests
+		}
+	}
+	if request.Deterministic != nil {
+		result.Deterministic = *request.Deterministic
+	}
+	if request.KeepFailedPages != nil {
+		result.KeepFailedPages = *request.KeepFailedPages
+	}
+
+	return result, nil
+}
+
+func (server *Server) hostIsAllowed(host string) bool {
+	if len(server.options.AllowedHosts) == 0 {
+		return true
+	}
+
+	for _, allowedHost := range server.options.AllowedHosts {
+		if host == allowedHost {
+			return true
+		}
+	}
+
+	return false
+}
+
+// run waits for a free slot and then crawls, unless the job is cancelled first.
+func (server *Server) run(job *runningJob) {
+	defer server.running.Done()
+
+	select {
+	case server.slots <- struct{}{}:
+		defer func() { <-server.slots }()
+	case <-job.ctx.Done():
+		server.finish(job, Cancelled, "", "")
+		return
+	}
+
+	server.mutex.Lock()
+	startedAt := time.Now()
+	job.State = Running
+	job.StartedAt = &startedAt
+	server.saveJob(job.Job)
+	server.mutex.Unlock()
+
+	server.options.Logger.Info("Started crawl job", "job", job.ID, "url", job.Request.SeedURL)
+
+	configuration := job.configuration
+	configuration.Observers = []crawler.Observer{job.tracker}
+	result, report := crawler.CrawlWithContext(job.ctx, configuration, server.options.LinkExtractor)
+
+	if err := server.options.Store.SaveResult(job.ID, result); err != nil {
+		server.finish(job, Failed, report.String(), fmt.Sprintf("Failed to save result: %s", err))
+		return
+	}
+
+	state := Finished
+	if report.Cancelled {
+		state = Cancelled
+	}
+	server.finish(job, state, report.String(), "")
+}
+
+func (server *Server) finish(job *runningJob, state State, report string, errorMessage string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	finishedAt := time.Now()
+	job.State = state
+	job.FinishedAt = &finishedAt
+	job.Progress = job.tracker.Snapshot()
+	job.Report = report
+	job.Error = errorMessage
+	job.cancel()
+	server.saveJob(job.Job)
+
+	server.options.Logger.Info("Crawl job "+string(state), "job", job.ID, "url", job.Request.SeedURL, "pages", job.Progress.Pages)
+}
+
+// saveJob saves a job in the store, logging rather than returning any error, as the job is still
+// available from memory.
+func (server *Server) saveJob(job Job) {
+	if err := server.options.Store.SaveJob(job); err != nil {
+		server.options.Logger.Error("Failed to save crawl job", "job", job.ID, "error", err)
+	}
+}
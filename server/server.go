@@ -0,0 +1,382 @@
+// Package server runs crawl jobs submitted over a small REST API:
+//
+//	POST   /crawls                 submit a job, described by a JobRequest
+//	GET    /crawls                 list all jobs
+//	GET    /crawls/ID              get the state and progress of a job
+//	GET    /crawls/ID/result       get the result of a finished or cancelled job, in the format given
+//	                               by the format parameter: ndjson (the default), csv, tsv, text, xml
+//	                               or html; for csv and tsv, the table parameter chooses between the
+//	                               pages (the default) and edges tables, and columns lists the columns
+//	DELETE /crawls/ID              cancel a job, or delete it if it is done
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hilverd/sitemapper/crawler"
+	"github.com/hilverd/sitemapper/linkextractor"
+	"github.com/hilverd/sitemapper/logging"
+	"github.com/hilverd/sitemapper/progress"
+	"github.com/hilverd/sitemapper/sitemap"
+)
+
+// maxRequestBytes is the maximum size of a request body.
+const maxRequestBytes = 64 * 1024
+
+// Options configure a Server. Jobs use Defaults for any settings they do not give themselves, and may
+// not ask for more concurrent requests than Defaults allows. Observers in Defaults are not used. If
+// AllowedHosts is not empty, only crawls with a seed URL on one of these hosts are accepted.
+type Options struct {
+	Defaults      crawler.Configuration
+	LinkExtractor linkextractor.LinkExtractor
+	MaxJobs       int
+	Store         Store
+	AllowedHosts  []string
+	Logger        *logging.Logger
+}
+
+// A Server runs at most MaxJobs crawls at a time; other jobs are queued until a slot is free.
+type Server struct {
+	options Options
+	mutex   sync.Mutex
+	jobs    map[string]*runningJob
+	slots   chan struct{}
+	running sync.WaitGroup
+}
+
+// New returns a Server with the jobs found in the store. Jobs that were still queued or running when
+// the store was last used are marked as failed.
+func New(options Options) (*Server, error) {
+	if options.MaxJobs <= 0 {
+		return nil, fmt.Errorf("The maximum number of jobs must be greater than zero")
+	}
+
+	server := &Server{
+		options: options,
+		jobs:    make(map[string]*runningJob),
+		slots:   make(chan struct{}, options.MaxJobs),
+	}
+
+	jobs, err := options.Store.Jobs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, job := range jobs {
+		if !job.done() {
+			finishedAt := time.Now()
+			job.State = Failed
+			job.FinishedAt = &finishedAt
+			job.Error = "The server stopped before the crawl finished"
+			if err := options.Store.SaveJob(job); err != nil {
+				return nil, err
+			}
+		}
+
+		server.jobs[job.ID] = &runningJob{Job: job, cancel: func() {}}
+	}
+
+	return server, nil
+}
+
+// Close cancels all jobs and waits for them to finish.
+func (server *Server) Close() {
+	server.mutex.Lock()
+	for _, job := range server.jobs {
+		job.cancel()
+	}
+	server.mutex.Unlock()
+
+	server.running.Wait()
+}
+
+func (server *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	path := strings.Trim(request.URL.Path, "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "crawls":
+		switch request.Method {
+		case http.MethodGet:
+			server.listJobs(writer)
+		case http.MethodPost:
+			server.submitJob(writer, request)
+		default:
+			methodNotAllowed(writer, http.MethodGet, http.MethodPost)
+		}
+	case len(parts) == 2 && parts[0] == "crawls":
+		switch request.Method {
+		case http.MethodGet:
+			server.getJob(writer, parts[1])
+		case http.MethodDelete:
+			server.deleteJob(writer, parts[1])
+		default:
+			methodNotAllowed(writer, http.MethodGet, http.MethodDelete)
+		}
+	case len(parts) == 3 && parts[0] == "crawls" && parts[2] == "result":
+		switch request.Method {
+		case http.MethodGet:
+			server.getResult(writer, parts[1], request.URL.Query())
+		default:
+			methodNotAllowed(writer, http.MethodGet)
+		}
+	default:
+		writeError(writer, http.StatusNotFound, "Not found")
+	}
+}
+
+func (server *Server) listJobs(writer http.ResponseWriter) {
+	server.mutex.Lock()
+	jobs := make([]Job, 0, len(server.jobs))
+	for _, job := range server.jobs {
+		jobs = append(jobs, server.snapshot(job))
+	}
+	server.mutex.Unlock()
+
+	sortJobs(jobs)
+	writeJSON(writer, http.StatusOK, jobs)
+}
+
+func (server *Server) submitJob(writer http.ResponseWriter, request *http.Request) {
+	var jobRequest JobRequest
+	decoder := json.NewDecoder(io.LimitReader(request.Body, maxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&jobRequest); err != nil {
+		writeError(writer, http.StatusBadRequest, fmt.Sprintf("Invalid crawl job: %s", err))
+		return
+	}
+
+	configuration, err := server.configuration(jobRequest)
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := newID()
+	if err != nil {
+		writeError(writer, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &runningJob{
+		Job: Job{
+			ID:        id,
+			State:     Queued,
+			Request:   jobRequest,
+			CreatedAt: time.Now(),
+		},
+		configuration: configuration,
+		tracker:       progress.NewTracker(),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+
+	server.mutex.Lock()
+	server.jobs[id] = job
+	server.saveJob(job.Job)
+	snapshot := server.snapshot(job)
+	server.running.Add(1)
+	server.mutex.Unlock()
+
+	go server.run(job)
+
+	writer.Header().Set("Location", "/crawls/"+id)
+	writeJSON(writer, http.StatusCreated, snapshot)
+}
+
+func (server *Server) getJob(writer http.ResponseWriter, id string) {
+	server.mutex.Lock()
+	job, ok := server.jobs[id]
+	var snapshot Job
+	if ok {
+		snapshot = server.snapshot(job)
+	}
+	server.mutex.Unlock()
+
+	if !ok {
+		writeError(writer, http.StatusNotFound, fmt.Sprintf("No crawl job %s", id))
+		return
+	}
+
+	writeJSON(writer, http.StatusOK, snapshot)
+}
+
+func (server *Server) deleteJob(writer http.ResponseWriter, id string) {
+	server.mutex.Lock()
+	job, ok := server.jobs[id]
+	if !ok {
+		server.mutex.Unlock()
+		writeError(writer, http.StatusNotFound, fmt.Sprintf("No crawl job %s", id))
+		return
+	}
+
+	if !job.done() {
+		job.cancel()
+		snapshot := server.snapshot(job)
+		server.mutex.Unlock()
+		writeJSON(writer, http.StatusAccepted, snapshot)
+		return
+	}
+
+	delete(server.jobs, id)
+	server.mutex.Unlock()
+
+	if err := server.options.Store.Delete(id); err != nil {
+		writeError(writer, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (server *Server) getResult(writer http.ResponseWriter, id string, query url.Values) {
+	server.mutex.Lock()
+	job, ok := server.jobs[id]
+	var snapshot Job
+	if ok {
+		snapshot = server.snapshot(job)
+	}
+	server.mutex.Unlock()
+
+	switch {
+	case !ok:
+		writeError(writer, http.StatusNotFound, fmt.Sprintf("No crawl job %s", id))
+		return
+	case snapshot.State != Finished && snapshot.State != Cancelled:
+		writeError(writer, http.StatusConflict, fmt.Sprintf("Crawl job %s is %s", id, snapshot.State))
+		return
+	}
+
+	result, err := server.options.Store.Result(id)
+	if err != nil {
+		writeError(writer, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	seedURL, _ := url.Parse(snapshot.Request.SeedURL)
+	body, contentType, err := formatResult(result, query, url.URL{Scheme: seedURL.Scheme, Host: seedURL.Host, Path: "/"})
+	if err != nil {
+		writeError(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writer.Header().Set("Content-Type", contentType)
+	_, _ = writer.Write(body)
+}
+
+// snapshot returns a copy of a job with up-to-date progress. The caller must hold the mutex.
+func (server *Server) snapshot(job *runningJob) Job {
+	result := job.Job
+	if job.State == Running {
+		result.Progress = job.tracker.Snapshot()
+	}
+
+	return result
+}
+
+func formatResult(result sitemap.Sitemap, query url.Values, baseURL url.URL) ([]byte, string, error) {
+	var output strings.Builder
+	var pageWriter sitemap.PageWriter
+	var contentType string
+
+	switch format := query.Get("format"); format {
+	case "", "ndjson":
+		pageWriter, contentType = sitemap.NewNDJSONWriter(&output), "application/x-ndjson"
+	case "csv", "tsv":
+		separator := ','
+		contentType = "text/csv; charset=utf-8"
+		if format == "tsv" {
+			separator, contentType = '\t', "text/tab-separated-values; charset=utf-8"
+		}
+
+		var err error
+		if pageWriter, err = tableWriter(query.Get("table"), query.Get("columns"), separator, &output); err != nil {
+			return nil, "", err
+		}
+	case "xml":
+		pageWriter = sitemap.NewXMLWriter(baseURL, "sitemap.xml", func(fileName string) (io.WriteCloser, error) {
+			if fileName != "sitemap.xml" {
+				return nil, fmt.Errorf("Too many pages for a single XML sitemap")
+			}
+			return nopCloser{&output}, nil
+		})
+		contentType = "application/xml"
+	case "html":
+		pageWriter, contentType = sitemap.NewHTMLWriter(&output), "text/html; charset=utf-8"
+	case "text":
+		return []byte(result.PrettyPrint() + "\n"), "text/plain; charset=utf-8", nil
+	default:
+		return nil, "", fmt.Errorf("format must be one of ndjson, csv, tsv, text, xml or html")
+	}
+
+	if err := result.Write(pageWriter); err != nil {
+		return nil, "", err
+	}
+
+	return []byte(output.String()), contentType, nil
+}
+
+func tableWriter(table string, columns string, separator rune, writer io.Writer) (sitemap.PageWriter, error) {
+	switch table {
+	case "", sitemap.PagesTable:
+		pageColumns, err := sitemap.ParseColumns(columns, sitemap.PageColumns, sitemap.DefaultPageColumns)
+		if err != nil {
+			return nil, err
+		}
+		return sitemap.NewPagesTableWriter(writer, separator, pageColumns), nil
+	case sitemap.EdgesTable:
+		edgeColumns, err := sitemap.ParseColumns(columns, sitemap.EdgeColumns, sitemap.EdgeColumns)
+		if err != nil {
+			return nil, err
+		}
+		return sitemap.NewEdgesTableWriter(writer, separator, edgeColumns), nil
+	default:
+		return nil, fmt.Errorf("table must be either pages or edges")
+	}
+}
+
+func newID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+func writeJSON(writer http.ResponseWriter, statusCode int, value interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(statusCode)
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(value)
+}
+
+func writeError(writer http.ResponseWriter, statusCode int, message string) {
+	writeJSON(writer, statusCode, map[string]string{"error": message})
+}
+
+func methodNotAllowed(writer http.ResponseWriter, methods ...string) {
+	writer.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(writer, http.StatusMethodNotAllowed, "Method not allowed")
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
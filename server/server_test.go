@@ -0,0 +1,258 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hilverd/sitemapper/crawler"
+	"github.com/hilverd/sitemapper/linkextractor"
+	"github.com/hilverd/sitemapper/logging"
+)
+
+// newSite returns a test site with a home page linking to /a and /b, where /b waits for release to be
+// closed before responding.
+func newSite(release chan struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "text/html")
+		switch request.URL.Path {
+		case "/":
+			fmt.Fprint(writer, `<a href="/a">A</a> <a href="/b">B</a>`)
+		case "/a":
+			fmt.Fprint(writer, `<a href="/">Home</a>`)
+		case "/b":
+			<-release
+			fmt.Fprint(writer, `<a href="/c">C</a>`)
+		default:
+			http.NotFound(writer, request)
+		}
+	}))
+}
+
+func newAPI(t *testing.T, store Store) (*Server, *httptest.Server) {
+	server, err := New(Options{
+		Defaults: crawler.Configuration{
+			MaxConcurrentRequests: 4,
+			MinConcurrentRequests: 1,
+			MaxDepth:              1,
+		},
+		LinkExtractor: linkextractor.HTTPClient{Do: http.DefaultClient.Do},
+		MaxJobs:       1,
+		Store:         store,
+		Logger:        logging.New(ioutil.Discard, logging.Text, logging.Info),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return server, httptest.NewServer(server)
+}
+
+func call(t *testing.T, method string, URL string, body string) (int, []byte) {
+	request, err := http.NewRequest(method, URL, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return response.StatusCode, responseBody
+}
+
+func submit(t *testing.T, api string, body string) Job {
+	statusCode, responseBody := call(t, http.MethodPost, api+"/crawls", body)
+	if statusCode != http.StatusCreated {
+		t.Fatalf("POST /crawls returned %d: %s", statusCode, responseBody)
+	}
+
+	var job Job
+	if err := json.Unmarshal(responseBody, &job); err != nil {
+		t.Fatal(err)
+	}
+
+	return job
+}
+
+func waitForState(t *testing.T, api string, id string, states ...State) Job {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		_, responseBody := call(t, http.MethodGet, api+"/crawls/"+id, "")
+
+		var job Job
+		if err := json.Unmarshal(responseBody, &job); err != nil {
+			t.Fatal(err)
+		}
+		for _, state := range states {
+			if job.State == state {
+				return job
+			}
+		}
+	}
+
+	t.Fatalf("Job %s did not reach state %v", id, states)
+	return Job{}
+}
+
+func TestServer(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	site := newSite(release)
+	defer site.Close()
+
+	server, api := newAPI(t, NewMemoryStore())
+	defer api.Close()
+	defer server.Close()
+
+	job := submit(t, api.URL, fmt.Sprintf(`{"seed_url": %q}`, site.URL))
+	job = waitForState(t, api.URL, job.ID, Finished)
+	if job.Progress.Pages != 3 || job.StartedAt == nil || job.FinishedAt == nil {
+		t.Errorf("Finished job = %+v", job)
+	}
+
+	statusCode, result := call(t, http.MethodGet, api.URL+"/crawls/"+job.ID+"/result?format=text", "")
+	want := fmt.Sprintf("%[1]s/\n  -> %[1]s/a\n  -> %[1]s/b\n\n%[1]s/a\n  -> %[1]s/\n\n%[1]s/b\n", site.URL)
+	if statusCode != http.StatusOK || string(result) != want {
+		t.Errorf("GET result returned %d: %q, want %q", statusCode, result, want)
+	}
+
+	statusCode, result = call(t, http.MethodGet, api.URL+"/crawls/"+job.ID+"/result", "")
+	if statusCode != http.StatusOK || bytes.Count(result, []byte("\n")) != 3 {
+		t.Errorf("GET result as NDJSON returned %d: %s", statusCode, result)
+	}
+
+	statusCode, _ = call(t, http.MethodDelete, api.URL+"/crawls/"+job.ID, "")
+	if statusCode != http.StatusNoContent {
+		t.Errorf("DELETE returned %d, want %d", statusCode, http.StatusNoContent)
+	}
+	statusCode, _ = call(t, http.MethodGet, api.URL+"/crawls/"+job.ID, "")
+	if statusCode != http.StatusNotFound {
+		t.Errorf("GET after DELETE returned %d, want %d", statusCode, http.StatusNotFound)
+	}
+}
+
+func TestServerCancelsJobs(t *testing.T) {
+	release := make(chan struct{})
+	site := newSite(release)
+	defer site.Close()
+
+	server, api := newAPI(t, NewMemoryStore())
+	defer api.Close()
+	defer server.Close()
+
+	running := submit(t, api.URL, fmt.Sprintf(`{"seed_url": %q}`, site.URL))
+	queued := submit(t, api.URL, fmt.Sprintf(`{"seed_url": %q}`, site.URL))
+
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		job := waitForState(t, api.URL, running.ID, Running)
+		if job.Progress.InFlight == 1 && job.Progress.Pages == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Job did not get to /b: %+v", job)
+		}
+	}
+
+	statusCode, result := call(t, http.MethodGet, api.URL+"/crawls/"+running.ID+"/result", "")
+	if statusCode != http.StatusConflict {
+		t.Errorf("GET result of running job returned %d: %s", statusCode, result)
+	}
+
+	if job := waitForState(t, api.URL, queued.ID, Queued); job.StartedAt != nil {
+		t.Errorf("Second job started while the first was running: %+v", job)
+	}
+
+	for _, id := range []string{queued.ID, running.ID} {
+		if statusCode, body := call(t, http.MethodDelete, api.URL+"/crawls/"+id, ""); statusCode != http.StatusAccepted {
+			t.Errorf("DELETE returned %d: %s", statusCode, body)
+		}
+	}
+	waitForState(t, api.URL, queued.ID, Cancelled)
+	close(release)
+	job := waitForState(t, api.URL, running.ID, Cancelled)
+
+	if job.Progress.Pages != 3 || !strings.Contains(job.Report, "cancelled") {
+		t.Errorf("Cancelled job = %+v", job)
+	}
+}
+
+func TestServerRejectsInvalidJobs(t *testing.T) {
+	server, api := newAPI(t, NewMemoryStore())
+	defer api.Close()
+	defer server.Close()
+	server.options.AllowedHosts = []string{"example.com"}
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{name: "not JSON", body: "example.com", wantErr: "Invalid crawl job"},
+		{name: "unknown field", body: `{"seed_url": "https://example.com/", "depth": 2}`, wantErr: "unknown field"},
+		{name: "relative seed URL", body: `{"seed_url": "example.com"}`, wantErr: "seed_url must be an absolute http or https URL"},
+		{name: "host that is not allowed", body: `{"seed_url": "https://example.org/"}`, wantErr: "Crawling example.org is not allowed"},
+		{name: "negative limit", body: `{"seed_url": "https://example.com/", "max_pages": -1}`, wantErr: "max_pages must be at least zero"},
+		{name: "too many concurrent requests", body: `{"seed_url": "https://example.com/", "max_concurrent_requests": 5}`, wantErr: "max_concurrent_requests must be between 1 and 4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statusCode, body := call(t, http.MethodPost, api.URL+"/crawls", tt.body)
+			if statusCode != http.StatusBadRequest || !strings.Contains(string(body), tt.wantErr) {
+				t.Errorf("POST returned %d: %s, want %d containing %q", statusCode, body, http.StatusBadRequest, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServerWithDirectoryStore(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	site := newSite(release)
+	defer site.Close()
+
+	store, err := NewDirectoryStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, api := newAPI(t, store)
+	job := submit(t, api.URL, fmt.Sprintf(`{"seed_url": %q}`, site.URL))
+	waitForState(t, api.URL, job.ID, Finished)
+	api.Close()
+	server.Close()
+
+	if err := store.SaveJob(Job{ID: "interrupted", State: Running, Request: JobRequest{SeedURL: site.URL}}); err != nil {
+		t.Fatal(err)
+	}
+
+	server, api = newAPI(t, store)
+	defer api.Close()
+	defer server.Close()
+
+	statusCode, result := call(t, http.MethodGet, api.URL+"/crawls/"+job.ID+"/result?format=csv", "")
+	if statusCode != http.StatusOK || !strings.HasPrefix(string(result), "url,depth,status,content_type,title,outbound\n") {
+		t.Errorf("GET result after restart returned %d: %s", statusCode, result)
+	}
+
+	statusCode, result = call(t, http.MethodGet, api.URL+"/crawls/"+job.ID+"/result?format=tsv&table=edges&columns=target,source", "")
+	if statusCode != http.StatusOK || !strings.HasPrefix(string(result), "target\tsource\n") {
+		t.Errorf("GET edges after restart returned %d: %s", statusCode, result)
+	}
+
+	if job := waitForState(t, api.URL, "interrupted", Failed); job.Error == "" {
+		t.Errorf("Interrupted job = %+v", job)
+	}
+}
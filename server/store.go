@@ -0,0 +1,185 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/hilverd/sitemapper/sitemap"
+)
+
+// A Store keeps crawl jobs and their results.
+type Store interface {
+	SaveJob(job Job) error
+	Jobs() ([]Job, error)
+	SaveResult(id string, result sitemap.Sitemap) error
+	Result(id string) (sitemap.Sitemap, error)
+	Delete(id string) error
+}
+
+// MemoryStore keeps jobs and their results in memory, so they are lost when the server stops.
+type MemoryStore struct {
+	mutex   sync.Mutex
+	jobs    map[string]Job
+	results map[string]sitemap.Sitemap
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]Job), results: make(map[string]sitemap.Sitemap)}
+}
+
+func (store *MemoryStore) SaveJob(job Job) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.jobs[job.ID] = job
+	return nil
+}
+
+func (store *MemoryStore) Jobs() ([]Job, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	result := make([]Job, 0, len(store.jobs))
+	for _, job := range store.jobs {
+		result = append(result, job)
+	}
+
+	sortJobs(result)
+	return result, nil
+}
+
+func (store *MemoryStore) SaveResult(id string, result sitemap.Sitemap) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.results[id] = result
+	return nil
+}
+
+func (store *MemoryStore) Result(id string) (sitemap.Sitemap, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	result, ok := store.results[id]
+	if !ok {
+		return nil, fmt.Errorf("No result for job %s", id)
+	}
+
+	return result, nil
+}
+
+func (store *MemoryStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.jobs, id)
+	delete(store.results, id)
+	return nil
+}
+
+// DirectoryStore keeps each job in a JSON file named after its ID, and its result next to it in NDJSON
+// format, so they survive restarts of the server.
+type DirectoryStore struct {
+	directory string
+}
+
+func NewDirectoryStore(directory string) (*DirectoryStore, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+
+	return &DirectoryStore{directory: directory}, nil
+}
+
+func (store *DirectoryStore) SaveJob(job Job) error {
+	return store.writeFile(job.ID+".json", func(writer io.Writer) error {
+		return json.NewEncoder(writer).Encode(job)
+	})
+}
+
+func (store *DirectoryStore) Jobs() ([]Job, error) {
+	fileNames, err := filepath.Glob(filepath.Join(store.directory, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Job, 0, len(fileNames))
+	for _, fileName := range fileNames {
+		contents, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		var job Job
+		if err := json.Unmarshal(contents, &job); err != nil {
+			return nil, fmt.Errorf("Failed to read job from %s: %w", fileName, err)
+		}
+		result = append(result, job)
+	}
+
+	sortJobs(result)
+	return result, nil
+}
+
+func (store *DirectoryStore) SaveResult(id string, result sitemap.Sitemap) error {
+	return store.writeFile(id+".ndjson", func(writer io.Writer) error {
+		return result.Write(sitemap.NewNDJSONWriter(writer))
+	})
+}
+
+func (store *DirectoryStore) Result(id string) (sitemap.Sitemap, error) {
+	file, err := os.Open(filepath.Join(store.directory, id+".ndjson"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return sitemap.ReadNDJSON(file)
+}
+
+func (store *DirectoryStore) Delete(id string) error {
+	for _, fileName := range []string{id + ".json", id + ".ndjson"} {
+		if err := os.Remove(filepath.Join(store.directory, fileName)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFile writes a file under a temporary name and then renames it, so a file is never left
+// half-written.
+func (store *DirectoryStore) writeFile(fileName string, write func(writer io.Writer) error) error {
+	file, err := ioutil.TempFile(store.directory, "."+fileName+".tmp-")
+	if err != nil {
+		return err
+	}
+
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filepath.Join(store.directory, fileName))
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+
+	return err
+}
+
+func sortJobs(jobs []Job) {
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+}
@@ -0,0 +1,134 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type Difference struct {
+	AddedPages       []url.URL
+	RemovedPages     []url.URL
+	NewlyBrokenPages []url.URL
+	ChangedPages     []PageDifference
+}
+
+type PageDifference struct {
+	URL          url.URL
+	OldDepth     int
+	NewDepth     int
+	AddedLinks   []url.URL
+	RemovedLinks []url.URL
+}
+
+// Diff compares two sitemaps of the same site. Pages that are broken in the new sitemap, but were
+// not in the old one, are listed as newly broken, and also as added if they are new.
+func Diff(old Sitemap, new Sitemap) Difference {
+	result := Difference{
+		AddedPages:       []url.URL{},
+		RemovedPages:     []url.URL{},
+		NewlyBrokenPages: []url.URL{},
+		ChangedPages:     []PageDifference{},
+	}
+
+	for _, URL := range sortedByURL(new) {
+		newPage := new[URL]
+		oldPage, existed := old[URL]
+
+		if !existed {
+			result.AddedPages = append(result.AddedPages, URL)
+		}
+
+		if newPage.Broken() && (!existed || !oldPage.Broken()) {
+			result.NewlyBrokenPages = append(result.NewlyBrokenPages, URL)
+		}
+
+		if existed {
+			pageDifference := PageDifference{
+				URL:          URL,
+				OldDepth:     oldPage.Depth,
+				NewDepth:     newPage.Depth,
+				AddedLinks:   linksMissingFrom(newPage.URLs, oldPage.URLs),
+				RemovedLinks: linksMissingFrom(oldPage.URLs, newPage.URLs),
+			}
+
+			if pageDifference.OldDepth != pageDifference.NewDepth || len(pageDifference.AddedLinks) > 0 || len(pageDifference.RemovedLinks) > 0 {
+				result.ChangedPages = append(result.ChangedPages, pageDifference)
+			}
+		}
+	}
+
+	for _, URL := range sortedByURL(old) {
+		if _, ok := new[URL]; !ok {
+			result.RemovedPages = append(result.RemovedPages, URL)
+		}
+	}
+
+	return result
+}
+
+func (difference Difference) IsEmpty() bool {
+	return len(difference.AddedPages) == 0 &&
+		len(difference.RemovedPages) == 0 &&
+		len(difference.NewlyBrokenPages) == 0 &&
+		len(difference.ChangedPages) == 0
+}
+
+func (difference Difference) String() string {
+	lines := make([]string, 0)
+
+	for _, URL := range difference.AddedPages {
+		lines = append(lines, fmt.Sprintf("+ %s", URL.String()))
+	}
+
+	for _, URL := range difference.RemovedPages {
+		lines = append(lines, fmt.Sprintf("- %s", URL.String()))
+	}
+
+	for _, URL := range difference.NewlyBrokenPages {
+		lines = append(lines, fmt.Sprintf("! %s", URL.String()))
+	}
+
+	for _, pageDifference := range difference.ChangedPages {
+		lines = append(lines, fmt.Sprintf("~ %s", pageDifference.URL.String()))
+		if pageDifference.OldDepth != pageDifference.NewDepth {
+			lines = append(lines, fmt.Sprintf("    depth %d -> %d", pageDifference.OldDepth, pageDifference.NewDepth))
+		}
+		for _, link := range pageDifference.AddedLinks {
+			lines = append(lines, fmt.Sprintf("    + %s", link.String()))
+		}
+		for _, link := range pageDifference.RemovedLinks {
+			lines = append(lines, fmt.Sprintf("    - %s", link.String()))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func sortedByURL(sitemap Sitemap) []url.URL {
+	result := make([]url.URL, 0, len(sitemap))
+	for URL := range sitemap {
+		result = append(result, URL)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].String() < result[j].String() })
+	return result
+}
+
+func linksMissingFrom(links []url.URL, otherLinks []url.URL) []url.URL {
+	present := map[url.URL]bool{}
+	for _, link := range otherLinks {
+		present[link] = true
+	}
+
+	result := []url.URL{}
+	for _, link := range links {
+		if !present[link] {
+			result = append(result, link)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].String() < result[j].String() })
+	return result
+}
@@ -0,0 +1,67 @@
+package sitemap
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+func TestDiff(t *testing.T) {
+	old := Sitemap{
+		crawlertest.MakeURL("https://example.com/"): {
+			Depth: 0,
+			URLs: []url.URL{
+				crawlertest.MakeURL("https://example.com/a"),
+				crawlertest.MakeURL("https://example.com/b"),
+			},
+		},
+		crawlertest.MakeURL("https://example.com/a"): {Depth: 1, URLs: []url.URL{}},
+		crawlertest.MakeURL("https://example.com/b"): {Depth: 1, URLs: []url.URL{}},
+	}
+
+	tests := []struct {
+		name      string
+		new       Sitemap
+		wantEmpty bool
+		want      string
+	}{
+		{
+			name:      "no differences",
+			new:       old,
+			wantEmpty: true,
+			want:      "",
+		},
+		{
+			name: "pages added, removed, broken and changed",
+			new: Sitemap{
+				crawlertest.MakeURL("https://example.com/"): {
+					Depth: 0,
+					URLs: []url.URL{
+						crawlertest.MakeURL("https://example.com/a"),
+						crawlertest.MakeURL("https://example.com/c"),
+					},
+				},
+				crawlertest.MakeURL("https://example.com/a"): {Depth: 1, URLs: []url.URL{}, StatusCode: 500, Error: "Got a 500 Internal Server Error response"},
+				crawlertest.MakeURL("https://example.com/c"): {Depth: 1, URLs: []url.URL{}},
+			},
+			want: `+ https://example.com/c
+- https://example.com/b
+! https://example.com/a
+~ https://example.com/
+    + https://example.com/c
+    - https://example.com/b`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff(old, tt.new)
+			if got.IsEmpty() != tt.wantEmpty {
+				t.Errorf("Diff().IsEmpty() = %v, want %v", got.IsEmpty(), tt.wantEmpty)
+			}
+			if got.String() != tt.want {
+				t.Errorf("Diff() = %v, want %v", got.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package sitemap
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"net/url"
+)
+
+//go:embed html_report.html
+var htmlReportTemplate string
+
+var htmlReport = template.Must(template.New("report").Parse(htmlReportTemplate))
+
+type htmlReportData struct {
+	Title string
+	Pages []htmlReportPage
+}
+
+// htmlReportPage refers to other pages by their index in the report, to keep large reports small.
+type htmlReportPage struct {
+	URL        string   `json:"url"`
+	Title      string   `json:"title,omitempty"`
+	Depth      int      `json:"depth"`
+	Status     int      `json:"status"`
+	Error      string   `json:"error,omitempty"`
+	Links      []int    `json:"links,omitempty"`
+	OtherLinks []string `json:"other_links,omitempty"`
+	LinkedFrom []int    `json:"linked_from,omitempty"`
+}
+
+// HTMLWriter writes a single, self-contained HTML file for browsing a crawl, with a searchable table
+// of pages, the links to and from each page, and a graph of the whole site. Pages are written on
+// Flush, as the links to a page are only known once all pages have been crawled.
+type HTMLWriter struct {
+	writer  io.Writer
+	sitemap Sitemap
+}
+
+func NewHTMLWriter(writer io.Writer) *HTMLWriter {
+	return &HTMLWriter{writer: writer, sitemap: make(Sitemap)}
+}
+
+func (writer *HTMLWriter) WritePage(URL url.URL, page Page) error {
+	writer.sitemap[URL] = page
+	return nil
+}
+
+func (writer *HTMLWriter) Flush() error {
+	URLs := writer.sitemap.SortedURLs()
+	indices := make(map[url.URL]int, len(URLs))
+	for i, URL := range URLs {
+		indices[URL] = i
+	}
+
+	data := htmlReportData{Title: "Site map", Pages: make([]htmlReportPage, 0, len(URLs))}
+	if len(URLs) > 0 {
+		data.Title = "Site map of " + URLs[0].String()
+	}
+
+	for _, URL := range URLs {
+		page := writer.sitemap[URL]
+		reportPage := htmlReportPage{
+			URL:    URL.String(),
+			Title:  page.Title,
+			Depth:  page.Depth,
+			Status: page.Status(),
+			Error:  page.Error,
+		}
+
+		for _, link := range page.URLs {
+			if index, ok := indices[link]; ok {
+				reportPage.Links = append(reportPage.Links, index)
+			} else {
+				reportPage.OtherLinks = append(reportPage.OtherLinks, link.String())
+			}
+		}
+
+		data.Pages = append(data.Pages, reportPage)
+	}
+
+	for i, page := range data.Pages {
+		for _, index := range page.Links {
+			data.Pages[index].LinkedFrom = append(data.Pages[index].LinkedFrom, i)
+		}
+	}
+
+	return htmlReport.Execute(writer.writer, data)
+}
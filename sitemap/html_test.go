@@ -0,0 +1,65 @@
+package sitemap
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+func TestHTMLWriter(t *testing.T) {
+	sitemap := Sitemap{
+		crawlertest.MakeURL("https://example.com/"): {
+			Title: "Home </script><script>alert(1)</script>",
+			URLs: []url.URL{
+				crawlertest.MakeURL("https://example.com/a"),
+				crawlertest.MakeURL("https://example.com/gone"),
+				crawlertest.MakeURL("https://other.example.com/"),
+			},
+		},
+		crawlertest.MakeURL("https://example.com/a"): {
+			Depth: 1,
+			Title: "A",
+			URLs:  []url.URL{crawlertest.MakeURL("https://example.com/")},
+		},
+		crawlertest.MakeURL("https://example.com/gone"): {Depth: 1, StatusCode: 404, Error: "Not Found"},
+	}
+
+	var output bytes.Buffer
+	if err := sitemap.Write(NewHTMLWriter(&output)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	html := output.String()
+
+	if !strings.Contains(html, "<title>Site map of https://example.com/</title>") {
+		t.Errorf("HTMLWriter did not write the expected title")
+	}
+
+	if regexp.MustCompile(`(src|href)="(https?:)?//`).MatchString(html) {
+		t.Errorf("HTMLWriter refers to external assets")
+	}
+
+	match := regexp.MustCompile(`(?s)<script id="report-data" type="application/json">(.*?)</script>`).FindStringSubmatch(html)
+	if match == nil {
+		t.Fatalf("HTMLWriter did not write the report data")
+	}
+
+	var got []htmlReportPage
+	if err := json.Unmarshal([]byte(match[1]), &got); err != nil {
+		t.Fatalf("Failed to parse report data: %v", err)
+	}
+
+	want := []htmlReportPage{
+		{URL: "https://example.com/", Title: "Home </script><script>alert(1)</script>", Status: 200, Links: []int{1, 2}, OtherLinks: []string{"https://other.example.com/"}, LinkedFrom: []int{1}},
+		{URL: "https://example.com/a", Title: "A", Depth: 1, Status: 200, Links: []int{0}, LinkedFrom: []int{0}},
+		{URL: "https://example.com/gone", Depth: 1, Status: 404, Error: "Not Found", LinkedFrom: []int{0}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("HTMLWriter wrote %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+package sitemap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// ReadNDJSON reads a sitemap written by an NDJSONWriter.
+func ReadNDJSON(reader io.Reader) (Sitemap, error) {
+	result := Sitemap{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record pageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("Failed to parse line %d: %w", lineNumber, err)
+		}
+
+		pageURL, err := url.Parse(record.URL)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse line %d: %w", lineNumber, err)
+		}
+
+		page := Page{
+			Depth:        record.Depth,
+			URLs:         make([]url.URL, 0, len(record.Links)),
+			Title:        record.Title,
+			ContentType:  record.ContentType,
+			Retries:      record.Retries,
+			Charset:      record.Charset,
+			Truncated:    record.Truncated,
+			WireBytes:    record.WireBytes,
+			DecodedBytes: record.DecodedBytes,
+			StatusCode:   record.StatusCode,
+			Error:        record.Error,
+		}
+
+		for _, link := range record.Links {
+			linkURL, err := url.Parse(link)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse line %d: %w", lineNumber, err)
+			}
+			page.URLs = append(page.URLs, *linkURL)
+		}
+
+		if len(record.LinkDetails) > 0 {
+			if len(record.LinkDetails) != len(record.Links) {
+				return nil, fmt.Errorf("Failed to parse line %d: there are %d link details for %d links", lineNumber, len(record.LinkDetails), len(record.Links))
+			}
+			for _, linkDetails := range record.LinkDetails {
+				page.LinkDetails = append(page.LinkDetails, LinkDetails(linkDetails))
+			}
+		}
+
+		result[*pageURL] = page
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
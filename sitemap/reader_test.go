@@ -0,0 +1,80 @@
+package sitemap
+
+import (
+	"bytes"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+func TestReadNDJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		sitemap Sitemap
+	}{
+		{
+			name:    "empty sitemap",
+			sitemap: Sitemap{},
+		},
+		{
+			name: "pages that were and were not crawled",
+			sitemap: Sitemap{
+				crawlertest.MakeURL("https://example.com/"): {
+					Depth: 0,
+					URLs: []url.URL{
+						crawlertest.MakeURL("https://example.com/caf%C3%A9?q=1"),
+						crawlertest.MakeURL("https://example.com/missing"),
+					},
+					LinkDetails: []LinkDetails{
+						{Text: "Café", Element: "a", Rel: "nofollow"},
+						{Element: "a"},
+					},
+					Title:        "Home",
+					ContentType:  "text/html",
+					Retries:      1,
+					Charset:      "windows-1252",
+					Truncated:    true,
+					WireBytes:    10,
+					DecodedBytes: 20,
+				},
+				crawlertest.MakeURL("https://example.com/caf%C3%A9?q=1"): {
+					Depth: 1,
+					URLs:  []url.URL{},
+				},
+				crawlertest.MakeURL("https://example.com/missing"): {
+					Depth:       1,
+					URLs:        []url.URL{},
+					ContentType: "text/html",
+					StatusCode:  404,
+					Error:       "Got a 404 Not Found response",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			if err := tt.sitemap.Write(NewNDJSONWriter(&buffer)); err != nil {
+				t.Fatalf("Sitemap.Write() error = %v", err)
+			}
+
+			got, err := ReadNDJSON(&buffer)
+			if err != nil {
+				t.Fatalf("ReadNDJSON() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.sitemap) {
+				t.Errorf("ReadNDJSON() = %v, want %v", got, tt.sitemap)
+			}
+		})
+	}
+}
+
+func TestReadNDJSON_reportsLineNumbers(t *testing.T) {
+	_, err := ReadNDJSON(strings.NewReader("{\"url\":\"https://example.com/\",\"depth\":0,\"links\":[]}\nnot json\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("ReadNDJSON() error = %v, want an error about line 2", err)
+	}
+}
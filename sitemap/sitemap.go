@@ -2,18 +2,69 @@ package sitemap
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
 	"sort"
 	"strings"
 )
 
 type Page struct {
-	Depth int
-	URLs  []url.URL
+	Depth        int
+	URLs         []url.URL
+	LinkDetails  []LinkDetails // either empty, or the details of each of URLs, in the same order
+	Title        string
+	ContentType  string
+	Retries      int
+	Charset      string
+	Truncated    bool
+	WireBytes    int64
+	DecodedBytes int64
+	StatusCode   int    // only set for pages that could not be crawled (zero if there was no response)
+	Error        string // only set for pages that could not be crawled
+}
+
+// LinkDetails describes where on a page a link was found.
+type LinkDetails struct {
+	Text    string // text of the link, with runs of whitespace collapsed
+	Element string // name of the element the link was found in, such as a or loc
+	Rel     string // relationship given by the link's rel attribute
 }
 
 type Sitemap map[url.URL]Page
 
+func (page Page) Failed() bool {
+	return page.Error != ""
+}
+
+// unsupportedContentTypeError starts the error of pages that were not crawled because links cannot
+// be extracted from their content type (see linkextractor.ErrUnsupportedContentType).
+const unsupportedContentTypeError = "Content type is not supported"
+
+// Broken reports whether a page could not be crawled because the server could not be reached, it
+// gave an error response or its body could not be read, as opposed to, say, the page not being HTML.
+func (page Page) Broken() bool {
+	switch {
+	case !page.Failed():
+		return false
+	case page.StatusCode == 0 || page.StatusCode >= 400:
+		return true
+	case page.StatusCode == http.StatusOK:
+		return !strings.HasPrefix(page.Error, unsupportedContentTypeError)
+	default:
+		return false
+	}
+}
+
+// Status returns the HTTP status code of the response for a page, which is 200 for pages that were
+// crawled successfully, and zero if there was no response.
+func (page Page) Status() int {
+	if !page.Failed() {
+		return http.StatusOK
+	}
+
+	return page.StatusCode
+}
+
 func (page Page) String() string {
 	lines := make([]string, 0)
 
@@ -26,43 +77,44 @@ func (page Page) String() string {
 }
 
 func (sitemap Sitemap) PrettyPrint() string {
-	type element struct {
-		URL  url.URL
-		page Page
-	}
-
 	if len(sitemap) == 0 {
 		return "[Empty sitemap]"
 	}
 
-	sortedByDepth := make([]element, 0)
+	lines := make([]string, 0)
+
+	for _, URL := range sitemap.SortedURLs() {
+		page := sitemap[URL]
+		if len(page.URLs) > 0 {
+			lines = append(lines, fmt.Sprintf("%s\n%s", URL.String(), page.String()))
+		} else {
+			lines = append(lines, URL.String())
+		}
+	}
+
+	return strings.Join(lines, "\n\n")
+}
 
-	for URL, page := range sitemap {
-		sortedByDepth = append(sortedByDepth, element{URL, page})
+// SortedURLs returns the URLs of all pages ordered by depth, and then alphabetically.
+func (sitemap Sitemap) SortedURLs() []url.URL {
+	result := make([]url.URL, 0, len(sitemap))
+
+	for URL := range sitemap {
+		result = append(result, URL)
 	}
 
-	sort.Slice(sortedByDepth, func(i, j int) bool {
+	sort.Slice(result, func(i, j int) bool {
 		switch {
-		case sortedByDepth[i].page.Depth < sortedByDepth[j].page.Depth:
+		case sitemap[result[i]].Depth < sitemap[result[j]].Depth:
 			return true
-		case sortedByDepth[j].page.Depth < sortedByDepth[i].page.Depth:
+		case sitemap[result[j]].Depth < sitemap[result[i]].Depth:
 			return false
 		default:
-			return sortedByDepth[i].URL.String() < sortedByDepth[j].URL.String()
+			return result[i].String() < result[j].String()
 		}
 	})
 
-	lines := make([]string, 0)
-
-	for _, element := range sortedByDepth {
-		if len(element.page.URLs) > 0 {
-			lines = append(lines, fmt.Sprintf("%s\n%s", element.URL.String(), element.page.String()))
-		} else {
-			lines = append(lines, element.URL.String())
-		}
-	}
-
-	return strings.Join(lines, "\n\n")
+	return result
 }
 
 func (sitemap Sitemap) FilterOutLinksThatHaveNoPage() Sitemap {
@@ -70,13 +122,19 @@ func (sitemap Sitemap) FilterOutLinksThatHaveNoPage() Sitemap {
 
 	for pageURL, page := range sitemap {
 		filteredURLs := []url.URL{}
-		for _, url := range page.URLs {
+		var filteredDetails []LinkDetails
+		for i, url := range page.URLs {
 			if _, ok := sitemap[url]; ok {
 				filteredURLs = append(filteredURLs, url)
+				if len(page.LinkDetails) > 0 {
+					filteredDetails = append(filteredDetails, page.LinkDetails[i])
+				}
 			}
 		}
 
-		result[pageURL] = Page{page.Depth, filteredURLs}
+		page.URLs = filteredURLs
+		page.LinkDetails = filteredDetails
+		result[pageURL] = page
 	}
 
 	return result
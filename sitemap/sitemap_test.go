@@ -24,19 +24,19 @@ func TestSitemap_PrettyPrint(t *testing.T) {
 			name: "simple sitemap",
 			sitemap: map[url.URL]Page{
 				crawlertest.MakeURL("https://example.com/first"): {
-					0,
-					[]url.URL{
+					Depth: 0,
+					URLs: []url.URL{
 						crawlertest.MakeURL("https://example.com/second"),
 						crawlertest.MakeURL("https://example.com/third"),
 					},
 				},
 				crawlertest.MakeURL("https://example.com/second"): {
-					1,
-					[]url.URL{},
+					Depth: 1,
+					URLs:  []url.URL{},
 				},
 				crawlertest.MakeURL("https://example.com/third"): {
-					1,
-					[]url.URL{
+					Depth: 1,
+					URLs: []url.URL{
 						crawlertest.MakeURL("https://example.com/first"),
 					},
 				},
@@ -73,40 +73,40 @@ func TestSitemap_FilterOutLinksThatHaveNoPage(t *testing.T) {
 			name: "links for which no page was created get removed",
 			sitemap: map[url.URL]Page{
 				crawlertest.MakeURL("https://example.com/retrieved-1"): {
-					0,
-					[]url.URL{
+					Depth: 0,
+					URLs: []url.URL{
 						crawlertest.MakeURL("https://example.com/retrieved-2"),
 						crawlertest.MakeURL("https://example.com/not-retrieved-1"),
 					},
 				},
 				crawlertest.MakeURL("https://example.com/retrieved-2"): {
-					1,
-					[]url.URL{
+					Depth: 1,
+					URLs: []url.URL{
 						crawlertest.MakeURL("https://example.com/retrieved-3"),
 						crawlertest.MakeURL("https://example.com/not-retrieved-2"),
 					},
 				},
 				crawlertest.MakeURL("https://example.com/retrieved-3"): {
-					1,
-					[]url.URL{},
+					Depth: 1,
+					URLs:  []url.URL{},
 				},
 			},
 			want: map[url.URL]Page{
 				crawlertest.MakeURL("https://example.com/retrieved-1"): {
-					0,
-					[]url.URL{
+					Depth: 0,
+					URLs: []url.URL{
 						crawlertest.MakeURL("https://example.com/retrieved-2"),
 					},
 				},
 				crawlertest.MakeURL("https://example.com/retrieved-2"): {
-					1,
-					[]url.URL{
+					Depth: 1,
+					URLs: []url.URL{
 						crawlertest.MakeURL("https://example.com/retrieved-3"),
 					},
 				},
 				crawlertest.MakeURL("https://example.com/retrieved-3"): {
-					1,
-					[]url.URL{},
+					Depth: 1,
+					URLs:  []url.URL{},
 				},
 			},
 		},
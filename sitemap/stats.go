@@ -0,0 +1,159 @@
+package sitemap
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const mostLinkedToPagesShown = 10
+
+type Statistics struct {
+	Pages               int
+	FailedPages         int
+	BrokenPages         int
+	Links               int
+	Retries             int
+	WireBytes           int64
+	DecodedBytes        int64
+	PagesByDepth        map[int]int
+	FailedPagesByStatus map[int]int // zero means there was no response
+	MostLinkedToPages   []LinkCount
+}
+
+type LinkCount struct {
+	URL   url.URL
+	Count int
+}
+
+func (sitemap Sitemap) Statistics() Statistics {
+	result := Statistics{
+		Pages:               len(sitemap),
+		PagesByDepth:        map[int]int{},
+		FailedPagesByStatus: map[int]int{},
+	}
+
+	inboundLinks := map[url.URL]int{}
+
+	for _, page := range sitemap {
+		result.Links += len(page.URLs)
+		result.Retries += page.Retries
+		result.WireBytes += page.WireBytes
+		result.DecodedBytes += page.DecodedBytes
+		result.PagesByDepth[page.Depth]++
+
+		if page.Failed() {
+			result.FailedPages++
+			result.FailedPagesByStatus[page.StatusCode]++
+		}
+		if page.Broken() {
+			result.BrokenPages++
+		}
+
+		for _, link := range page.URLs {
+			if _, ok := sitemap[link]; ok {
+				inboundLinks[link]++
+			}
+		}
+	}
+
+	for URL, count := range inboundLinks {
+		result.MostLinkedToPages = append(result.MostLinkedToPages, LinkCount{URL: URL, Count: count})
+	}
+
+	sort.Slice(result.MostLinkedToPages, func(i, j int) bool {
+		switch {
+		case result.MostLinkedToPages[i].Count != result.MostLinkedToPages[j].Count:
+			return result.MostLinkedToPages[i].Count > result.MostLinkedToPages[j].Count
+		default:
+			return result.MostLinkedToPages[i].URL.String() < result.MostLinkedToPages[j].URL.String()
+		}
+	})
+
+	if len(result.MostLinkedToPages) > mostLinkedToPagesShown {
+		result.MostLinkedToPages = result.MostLinkedToPages[:mostLinkedToPagesShown]
+	}
+
+	return result
+}
+
+func (statistics Statistics) String() string {
+	lines := []string{
+		fmt.Sprintf("Pages: %d (%d failed, %d broken)", statistics.Pages, statistics.FailedPages, statistics.BrokenPages),
+		fmt.Sprintf("Links: %d (%s per page)", statistics.Links, linksPerPage(statistics)),
+		fmt.Sprintf("Retries: %d", statistics.Retries),
+		fmt.Sprintf("Bytes transferred: %d (%d after decompression)", statistics.WireBytes, statistics.DecodedBytes),
+	}
+
+	if len(statistics.PagesByDepth) > 0 {
+		lines = append(lines, "Pages by depth:")
+		for _, depth := range sortedKeys(statistics.PagesByDepth) {
+			lines = append(lines, fmt.Sprintf("  %d: %d", depth, statistics.PagesByDepth[depth]))
+		}
+	}
+
+	if len(statistics.FailedPagesByStatus) > 0 {
+		lines = append(lines, "Failed pages by status:")
+		for _, statusCode := range sortedKeys(statistics.FailedPagesByStatus) {
+			status := "no response"
+			if statusCode != 0 {
+				status = fmt.Sprintf("%d", statusCode)
+			}
+			lines = append(lines, fmt.Sprintf("  %s: %d", status, statistics.FailedPagesByStatus[statusCode]))
+		}
+	}
+
+	if len(statistics.MostLinkedToPages) > 0 {
+		lines = append(lines, "Most linked-to pages:")
+		for _, linkCount := range statistics.MostLinkedToPages {
+			lines = append(lines, fmt.Sprintf("  %d %s", linkCount.Count, linkCount.URL.String()))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func linksPerPage(statistics Statistics) string {
+	if statistics.Pages == 0 {
+		return "0"
+	}
+
+	return fmt.Sprintf("%.1f", float64(statistics.Links)/float64(statistics.Pages))
+}
+
+func sortedKeys(counts map[int]int) []int {
+	result := make([]int, 0, len(counts))
+	for key := range counts {
+		result = append(result, key)
+	}
+
+	sort.Ints(result)
+	return result
+}
+
+type BrokenLink struct {
+	URL        url.URL
+	StatusCode int
+	Error      string
+	LinkedFrom []url.URL
+}
+
+// BrokenLinks lists the broken pages in the sitemap, each with the pages that link to it.
+func (sitemap Sitemap) BrokenLinks() []BrokenLink {
+	linkedFrom := map[url.URL][]url.URL{}
+	for _, URL := range sortedByURL(sitemap) {
+		for _, link := range sitemap[URL].URLs {
+			linkedFrom[link] = append(linkedFrom[link], URL)
+		}
+	}
+
+	result := make([]BrokenLink, 0)
+	for _, URL := range sortedByURL(sitemap) {
+		if page := sitemap[URL]; page.Broken() {
+			result = append(result, BrokenLink{URL: URL, StatusCode: page.StatusCode, Error: page.Error, LinkedFrom: linkedFrom[URL]})
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,78 @@
+package sitemap
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+var sitemapWithBrokenLinks = Sitemap{
+	crawlertest.MakeURL("https://example.com/"): {
+		Depth: 0,
+		URLs: []url.URL{
+			crawlertest.MakeURL("https://example.com/a"),
+			crawlertest.MakeURL("https://example.com/missing"),
+			crawlertest.MakeURL("https://example.com/file.pdf"),
+		},
+		WireBytes:    100,
+		DecodedBytes: 300,
+	},
+	crawlertest.MakeURL("https://example.com/a"): {
+		Depth:   1,
+		URLs:    []url.URL{crawlertest.MakeURL("https://example.com/missing")},
+		Retries: 2,
+	},
+	crawlertest.MakeURL("https://example.com/missing"): {
+		Depth:      1,
+		URLs:       []url.URL{},
+		StatusCode: 404,
+		Error:      "Got a 404 Not Found response",
+	},
+	crawlertest.MakeURL("https://example.com/file.pdf"): {
+		Depth:      1,
+		URLs:       []url.URL{},
+		StatusCode: 200,
+		Error:      "Content type is not supported: application/pdf",
+	},
+}
+
+func TestSitemap_Statistics(t *testing.T) {
+	want := `Pages: 4 (2 failed, 1 broken)
+Links: 4 (1.0 per page)
+Retries: 2
+Bytes transferred: 100 (300 after decompression)
+Pages by depth:
+  0: 1
+  1: 3
+Failed pages by status:
+  200: 1
+  404: 1
+Most linked-to pages:
+  2 https://example.com/missing
+  1 https://example.com/a
+  1 https://example.com/file.pdf`
+
+	if got := sitemapWithBrokenLinks.Statistics().String(); got != want {
+		t.Errorf("Sitemap.Statistics() = %v, want %v", got, want)
+	}
+}
+
+func TestSitemap_BrokenLinks(t *testing.T) {
+	want := []BrokenLink{
+		{
+			URL:        crawlertest.MakeURL("https://example.com/missing"),
+			StatusCode: 404,
+			Error:      "Got a 404 Not Found response",
+			LinkedFrom: []url.URL{
+				crawlertest.MakeURL("https://example.com/"),
+				crawlertest.MakeURL("https://example.com/a"),
+			},
+		},
+	}
+
+	if got := sitemapWithBrokenLinks.BrokenLinks(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Sitemap.BrokenLinks() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,250 @@
+package sitemap
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Names of the tables a sitemap can be written as, and of the columns they can have.
+const (
+	PagesTable = "pages"
+	EdgesTable = "edges"
+)
+
+// A pages table has an inbound column only if asked for, as its rows can then only be written once
+// all pages have been crawled.
+var (
+	PageColumns        = []string{"url", "depth", "status", "content_type", "title", "inbound", "outbound"}
+	DefaultPageColumns = []string{"url", "depth", "status", "content_type", "title", "outbound"}
+	EdgeColumns        = []string{"source", "target", "text", "element", "rel"}
+)
+
+// ParseColumns parses a comma-separated list of columns, each of which must be one of available. An
+// empty list stands for defaults.
+func ParseColumns(list string, available []string, defaults []string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return append([]string(nil), defaults...), nil
+	}
+
+	known := map[string]bool{}
+	for _, column := range available {
+		known[column] = true
+	}
+
+	result := make([]string, 0)
+	for _, column := range strings.Split(list, ",") {
+		column = strings.TrimSpace(column)
+		if !known[column] {
+			return nil, fmt.Errorf("Unknown column %q, expected one of %s", column, strings.Join(available, ", "))
+		}
+		result = append(result, column)
+	}
+
+	return result, nil
+}
+
+// A rowWriter writes the rows of a table in some format; csv.Writer is one.
+type rowWriter interface {
+	Write(row []string) error
+	Flush()
+	Error() error
+}
+
+// A table is written as CSV, or as TSV if its separator is a tab, with a header row naming the
+// columns.
+type table struct {
+	writer        rowWriter
+	columns       []string
+	headerWritten bool
+}
+
+func newTable(writer io.Writer, separator rune, columns []string) table {
+	if separator == '\t' {
+		return table{writer: &tsvWriter{writer: bufio.NewWriter(writer)}, columns: columns}
+	}
+
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Comma = separator
+	return table{writer: csvWriter, columns: columns}
+}
+
+// tsvCellReplacer replaces the characters that separate cells and rows in TSV.
+var tsvCellReplacer = strings.NewReplacer("\r\n", " ", "\t", " ", "\n", " ", "\r", " ")
+
+// A tsvWriter writes rows as tab-separated values. Unlike in CSV, cells are not quoted, so tabs and
+// line breaks in them are replaced by spaces.
+type tsvWriter struct {
+	writer *bufio.Writer
+	err    error
+}
+
+func (writer *tsvWriter) Write(row []string) error {
+	for i, cell := range row {
+		if i > 0 {
+			_ = writer.writer.WriteByte('\t')
+		}
+		_, _ = writer.writer.WriteString(tsvCellReplacer.Replace(cell))
+	}
+
+	return writer.writer.WriteByte('\n')
+}
+
+func (writer *tsvWriter) Flush() {
+	writer.err = writer.writer.Flush()
+}
+
+func (writer *tsvWriter) Error() error {
+	return writer.err
+}
+
+func (table *table) writeHeader() error {
+	if table.headerWritten {
+		return nil
+	}
+
+	table.headerWritten = true
+	return table.writer.Write(table.columns)
+}
+
+func (table *table) writeRow(row []string) error {
+	if err := table.writeHeader(); err != nil {
+		return err
+	}
+
+	return table.writer.Write(row)
+}
+
+// flush writes any buffered rows, and the header if there were no rows at all.
+func (table *table) flush() error {
+	if err := table.writeHeader(); err != nil {
+		return err
+	}
+
+	table.writer.Flush()
+	return table.writer.Error()
+}
+
+// PagesTableWriter writes a row for each page. Rows are written as pages come in, through a buffer
+// that is emptied whenever it fills up and on Flush, unless there is an inbound column: the number
+// of pages that link to a page is only known once all pages have been crawled, so rows are then kept
+// until Flush.
+type PagesTableWriter struct {
+	table       table
+	inbound     map[url.URL]int
+	pendingURLs []url.URL
+	pendingRows [][]string
+}
+
+func NewPagesTableWriter(writer io.Writer, separator rune, columns []string) *PagesTableWriter {
+	result := &PagesTableWriter{table: newTable(writer, separator, columns)}
+
+	for _, column := range columns {
+		if column == "inbound" {
+			result.inbound = map[url.URL]int{}
+		}
+	}
+
+	return result
+}
+
+func (writer *PagesTableWriter) WritePage(URL url.URL, page Page) error {
+	row := make([]string, len(writer.table.columns))
+
+	for i, column := range writer.table.columns {
+		switch column {
+		case "url":
+			row[i] = URL.String()
+		case "depth":
+			row[i] = strconv.Itoa(page.Depth)
+		case "status":
+			if status := page.Status(); status != 0 {
+				row[i] = strconv.Itoa(status)
+			}
+		case "content_type":
+			row[i] = page.ContentType
+		case "title":
+			row[i] = page.Title
+		case "outbound":
+			row[i] = strconv.Itoa(len(page.URLs))
+		}
+	}
+
+	if writer.inbound == nil {
+		return writer.table.writeRow(row)
+	}
+
+	for _, link := range page.URLs {
+		writer.inbound[link]++
+	}
+
+	writer.pendingURLs = append(writer.pendingURLs, URL)
+	writer.pendingRows = append(writer.pendingRows, row)
+	return nil
+}
+
+func (writer *PagesTableWriter) Flush() error {
+	for i, row := range writer.pendingRows {
+		for j, column := range writer.table.columns {
+			if column == "inbound" {
+				row[j] = strconv.Itoa(writer.inbound[writer.pendingURLs[i]])
+			}
+		}
+
+		if err := writer.table.writeRow(row); err != nil {
+			return err
+		}
+	}
+
+	writer.pendingURLs, writer.pendingRows = nil, nil
+	return writer.table.flush()
+}
+
+// EdgesTableWriter writes a row for each link on each page, as pages come in. Like
+// PagesTableWriter, it buffers rows until the buffer fills up or Flush is called.
+type EdgesTableWriter struct {
+	table table
+}
+
+func NewEdgesTableWriter(writer io.Writer, separator rune, columns []string) *EdgesTableWriter {
+	return &EdgesTableWriter{table: newTable(writer, separator, columns)}
+}
+
+func (writer *EdgesTableWriter) WritePage(URL url.URL, page Page) error {
+	for i, link := range page.URLs {
+		var details LinkDetails
+		if len(page.LinkDetails) == len(page.URLs) {
+			details = page.LinkDetails[i]
+		}
+
+		row := make([]string, len(writer.table.columns))
+		for j, column := range writer.table.columns {
+			switch column {
+			case "source":
+				row[j] = URL.String()
+			case "target":
+				row[j] = link.String()
+			case "text":
+				row[j] = details.Text
+			case "element":
+				row[j] = details.Element
+			case "rel":
+				row[j] = details.Rel
+			}
+		}
+
+		if err := writer.table.writeRow(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (writer *EdgesTableWriter) Flush() error {
+	return writer.table.flush()
+}
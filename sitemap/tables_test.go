@@ -0,0 +1,88 @@
+package sitemap
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "default columns", list: "", want: DefaultPageColumns},
+		{name: "some columns", list: "inbound, url", want: []string{"inbound", "url"}},
+		{name: "unknown column", list: "url,source", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseColumns(tt.list, PageColumns, DefaultPageColumns)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseColumns() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseColumns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPagesTableWriter_empty(t *testing.T) {
+	for _, columns := range [][]string{PageColumns, {"url"}} {
+		var buffer bytes.Buffer
+		if err := (Sitemap{}).Write(NewPagesTableWriter(&buffer, ',', columns)); err != nil {
+			t.Fatalf("Sitemap.Write() error = %v", err)
+		}
+		if got, want := buffer.String(), strings.Join(columns, ",")+"\n"; got != want {
+			t.Errorf("PagesTableWriter wrote %q, want %q", got, want)
+		}
+	}
+}
+
+func TestPagesTableWriter_streamsRowsWithDefaultColumns(t *testing.T) {
+	for _, separator := range []rune{',', '\t'} {
+		var buffer bytes.Buffer
+		writer := NewPagesTableWriter(&buffer, separator, DefaultPageColumns)
+
+		page := Page{URLs: []url.URL{crawlertest.MakeURL("https://example.com/a")}, Title: "Home", ContentType: "text/html"}
+		for i := 0; i < 1000; i++ {
+			if err := writer.WritePage(crawlertest.MakeURL(fmt.Sprintf("https://example.com/%d", i)), page); err != nil {
+				t.Fatalf("WritePage() error = %v", err)
+			}
+		}
+
+		written := buffer.Len()
+		if written == 0 {
+			t.Errorf("PagesTableWriter kept all rows until Flush")
+		}
+
+		if err := writer.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		if rows := strings.Count(buffer.String(), "\n"); rows != 1001 || buffer.Len() == written {
+			t.Errorf("PagesTableWriter wrote %d lines, %d bytes of them before Flush, want 1001 lines and some written on Flush", rows, written)
+		}
+	}
+}
+
+func TestPagesTableWriter_TSV(t *testing.T) {
+	var buffer bytes.Buffer
+	sitemap := Sitemap{crawlertest.MakeURL("https://example.com/"): {URLs: []url.URL{}, Title: "\"Tabs\"\tand\r\nlines"}}
+	if err := sitemap.Write(NewPagesTableWriter(&buffer, '\t', []string{"url", "title"})); err != nil {
+		t.Fatalf("Sitemap.Write() error = %v", err)
+	}
+
+	want := "url\ttitle\nhttps://example.com/\t\"Tabs\" and lines\n"
+	if got := buffer.String(); got != want {
+		t.Errorf("PagesTableWriter wrote %q, want %q", got, want)
+	}
+}
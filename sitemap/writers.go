@@ -0,0 +1,87 @@
+package sitemap
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+)
+
+// A PageWriter writes pages one at a time, so they can be written while a crawl is still going on.
+type PageWriter interface {
+	WritePage(URL url.URL, page Page) error
+	Flush() error
+}
+
+type NDJSONWriter struct {
+	encoder *json.Encoder
+}
+
+type pageRecord struct {
+	URL          string              `json:"url"`
+	Depth        int                 `json:"depth"`
+	Links        []string            `json:"links"`
+	LinkDetails  []linkDetailsRecord `json:"link_details,omitempty"`
+	Title        string              `json:"title,omitempty"`
+	ContentType  string              `json:"content_type,omitempty"`
+	Retries      int                 `json:"retries,omitempty"`
+	Charset      string              `json:"charset,omitempty"`
+	Truncated    bool                `json:"truncated,omitempty"`
+	WireBytes    int64               `json:"wire_bytes,omitempty"`
+	DecodedBytes int64               `json:"decoded_bytes,omitempty"`
+	StatusCode   int                 `json:"status_code,omitempty"`
+	Error        string              `json:"error,omitempty"`
+}
+
+type linkDetailsRecord struct {
+	Text    string `json:"text,omitempty"`
+	Element string `json:"element,omitempty"`
+	Rel     string `json:"rel,omitempty"`
+}
+
+func NewNDJSONWriter(writer io.Writer) *NDJSONWriter {
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	return &NDJSONWriter{encoder: encoder}
+}
+
+func (writer *NDJSONWriter) WritePage(URL url.URL, page Page) error {
+	record := pageRecord{
+		URL:          URL.String(),
+		Depth:        page.Depth,
+		Links:        make([]string, 0, len(page.URLs)),
+		Title:        page.Title,
+		ContentType:  page.ContentType,
+		Retries:      page.Retries,
+		Charset:      page.Charset,
+		Truncated:    page.Truncated,
+		WireBytes:    page.WireBytes,
+		DecodedBytes: page.DecodedBytes,
+		StatusCode:   page.StatusCode,
+		Error:        page.Error,
+	}
+
+	for _, link := range page.URLs {
+		record.Links = append(record.Links, link.String())
+	}
+
+	for _, linkDetails := range page.LinkDetails {
+		record.LinkDetails = append(record.LinkDetails, linkDetailsRecord(linkDetails))
+	}
+
+	return writer.encoder.Encode(record)
+}
+
+func (writer *NDJSONWriter) Flush() error {
+	return nil
+}
+
+// Write writes all pages of a sitemap in the order given by SortedURLs.
+func (sitemap Sitemap) Write(writer PageWriter) error {
+	for _, URL := range sitemap.SortedURLs() {
+		if err := writer.WritePage(URL, sitemap[URL]); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,100 @@
+package sitemap
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+func TestSitemap_Write(t *testing.T) {
+	sitemap := Sitemap{
+		crawlertest.MakeURL("https://example.com/"): {
+			Depth: 0,
+			URLs: []url.URL{
+				crawlertest.MakeURL("https://example.com/a?x=1&y=2"),
+				crawlertest.MakeURL("https://example.com/b,c"),
+			},
+			LinkDetails: []LinkDetails{
+				{Text: "A", Element: "a"},
+				{Text: `"B", C`, Element: "a", Rel: "nofollow"},
+			},
+			Title:        "Home",
+			ContentType:  "text/html",
+			Charset:      "utf-8",
+			WireBytes:    120,
+			DecodedBytes: 300,
+		},
+		crawlertest.MakeURL("https://example.com/a?x=1&y=2"): {
+			Depth:   1,
+			URLs:    []url.URL{},
+			Retries: 2,
+		},
+		crawlertest.MakeURL("https://example.com/b,c"): {
+			Depth:       1,
+			URLs:        []url.URL{crawlertest.MakeURL("https://example.com/")},
+			ContentType: "application/pdf",
+			StatusCode:  200,
+			Error:       "Content type is not supported: application/pdf",
+		},
+	}
+
+	tests := []struct {
+		name      string
+		newWriter func(buffer *bytes.Buffer) PageWriter
+		want      string
+	}{
+		{
+			name:      "NDJSON",
+			newWriter: func(buffer *bytes.Buffer) PageWriter { return NewNDJSONWriter(buffer) },
+			want: `{"url":"https://example.com/","depth":0,"links":["https://example.com/a?x=1&y=2","https://example.com/b,c"],"link_details":[{"text":"A","element":"a"},{"text":"\"B\", C","element":"a","rel":"nofollow"}],"title":"Home","content_type":"text/html","charset":"utf-8","wire_bytes":120,"decoded_bytes":300}
+{"url":"https://example.com/a?x=1&y=2","depth":1,"links":[],"retries":2}
+{"url":"https://example.com/b,c","depth":1,"links":["https://example.com/"],"content_type":"application/pdf","status_code":200,"error":"Content type is not supported: application/pdf"}
+`,
+		},
+		{
+			name:      "pages table",
+			newWriter: func(buffer *bytes.Buffer) PageWriter { return NewPagesTableWriter(buffer, ',', PageColumns) },
+			want: `url,depth,status,content_type,title,inbound,outbound
+https://example.com/,0,200,text/html,Home,1,2
+https://example.com/a?x=1&y=2,1,200,,,1,0
+"https://example.com/b,c",1,200,application/pdf,,1,1
+`,
+		},
+		{
+			name: "pages table as TSV with some columns",
+			newWriter: func(buffer *bytes.Buffer) PageWriter {
+				return NewPagesTableWriter(buffer, '\t', []string{"title", "url"})
+			},
+			want: "title\turl\nHome\thttps://example.com/\n\thttps://example.com/a?x=1&y=2\n\thttps://example.com/b,c\n",
+		},
+		{
+			name:      "edges table",
+			newWriter: func(buffer *bytes.Buffer) PageWriter { return NewEdgesTableWriter(buffer, ',', EdgeColumns) },
+			want: `source,target,text,element,rel
+https://example.com/,https://example.com/a?x=1&y=2,A,a,
+https://example.com/,"https://example.com/b,c","""B"", C",a,nofollow
+"https://example.com/b,c",https://example.com/,,,
+`,
+		},
+		{
+			name: "edges table as TSV with some columns",
+			newWriter: func(buffer *bytes.Buffer) PageWriter {
+				return NewEdgesTableWriter(buffer, '\t', []string{"target", "text"})
+			},
+			want: "target\ttext\nhttps://example.com/a?x=1&y=2\tA\nhttps://example.com/b,c\t\"B\", C\nhttps://example.com/\t\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			if err := sitemap.Write(tt.newWriter(&buffer)); err != nil {
+				t.Fatalf("Sitemap.Write() error = %v", err)
+			}
+			if got := buffer.String(); got != tt.want {
+				t.Errorf("Sitemap.Write() wrote %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package sitemap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Limits on the size of a single XML sitemap file, as set by the sitemaps.org protocol.
+const (
+	MaxURLsPerXMLSitemap  = 50000
+	MaxBytesPerXMLSitemap = 50 * 1024 * 1024
+)
+
+const (
+	xmlSitemapHeader = xml.Header + `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n"
+	xmlSitemapFooter = "</urlset>\n"
+	xmlIndexHeader   = xml.Header + `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n"
+	xmlIndexFooter   = "</sitemapindex>\n"
+)
+
+// XMLWriter writes the pages that were crawled successfully as an XML sitemap in the sitemaps.org
+// format. Pages are written on Flush, ordered as by SortedURLs. If they do not fit in a single file,
+// they are split over files named like sitemap-1.xml, sitemap-2.xml and so on, and the file itself
+// becomes a sitemap index that refers to these files by their URLs under BaseURL.
+type XMLWriter struct {
+	BaseURL  url.URL
+	FileName string
+	Create   func(fileName string) (io.WriteCloser, error)
+	MaxURLs  int
+	MaxBytes int
+	sitemap  Sitemap
+}
+
+func NewXMLWriter(baseURL url.URL, fileName string, create func(fileName string) (io.WriteCloser, error)) *XMLWriter {
+	return &XMLWriter{
+		BaseURL:  baseURL,
+		FileName: fileName,
+		Create:   create,
+		MaxURLs:  MaxURLsPerXMLSitemap,
+		MaxBytes: MaxBytesPerXMLSitemap,
+		sitemap:  make(Sitemap),
+	}
+}
+
+func (writer *XMLWriter) WritePage(URL url.URL, page Page) error {
+	if !page.Failed() {
+		writer.sitemap[URL] = page
+	}
+
+	return nil
+}
+
+func (writer *XMLWriter) Flush() error {
+	chunks := make([]*bytes.Buffer, 0)
+	var chunk *bytes.Buffer
+	urlsInChunk := 0
+
+	for _, URL := range writer.sitemap.SortedURLs() {
+		entry := xmlEntry("url", URL.String())
+		if len(xmlSitemapHeader)+len(entry)+len(xmlSitemapFooter) > writer.MaxBytes {
+			return fmt.Errorf("URL too long for an XML sitemap: %s", URL.String())
+		}
+
+		if chunk == nil || urlsInChunk == writer.MaxURLs || chunk.Len()+len(entry)+len(xmlSitemapFooter) > writer.MaxBytes {
+			chunk = bytes.NewBufferString(xmlSitemapHeader)
+			chunks = append(chunks, chunk)
+			urlsInChunk = 0
+		}
+
+		chunk.WriteString(entry)
+		urlsInChunk++
+	}
+
+	switch len(chunks) {
+	case 0:
+		return writer.writeFile(writer.FileName, xmlSitemapHeader+xmlSitemapFooter)
+	case 1:
+		return writer.writeFile(writer.FileName, chunks[0].String()+xmlSitemapFooter)
+	}
+
+	extension := path.Ext(writer.FileName)
+	stem := strings.TrimSuffix(writer.FileName, extension)
+	index := bytes.NewBufferString(xmlIndexHeader)
+
+	for i, chunk := range chunks {
+		chunkFileName := fmt.Sprintf("%s-%d%s", stem, i+1, extension)
+		if err := writer.writeFile(chunkFileName, chunk.String()+xmlSitemapFooter); err != nil {
+			return err
+		}
+
+		chunkURL := writer.BaseURL
+		chunkURL.Path = path.Join(chunkURL.Path, path.Base(chunkFileName))
+		index.WriteString(xmlEntry("sitemap", chunkURL.String()))
+	}
+
+	return writer.writeFile(writer.FileName, index.String()+xmlIndexFooter)
+}
+
+func (writer *XMLWriter) writeFile(fileName string, contents string) error {
+	file, err := writer.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(file, contents); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func xmlEntry(element string, loc string) string {
+	var escaped bytes.Buffer
+	_ = xml.EscapeText(&escaped, []byte(loc))
+
+	return fmt.Sprintf("  <%s>\n    <loc>%s</loc>\n  </%s>\n", element, escaped.String(), element)
+}
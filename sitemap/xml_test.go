@@ -0,0 +1,154 @@
+package sitemap
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+)
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func TestXMLWriter(t *testing.T) {
+	sitemap := Sitemap{
+		crawlertest.MakeURL("https://example.com/"):           {Depth: 0},
+		crawlertest.MakeURL("https://example.com/a?x=1&y=2"):  {Depth: 1},
+		crawlertest.MakeURL("https://example.com/b"):          {Depth: 1},
+		crawlertest.MakeURL("https://example.com/gone"):       {Depth: 1, StatusCode: 404, Error: "Not Found"},
+		crawlertest.MakeURL("https://example.com/b/c"):        {Depth: 2},
+		crawlertest.MakeURL("https://example.com/b/c/d/e/f"):  {Depth: 3},
+		crawlertest.MakeURL("https://example.com/b/c/d/e/fg"): {Depth: 3},
+	}
+
+	tests := []struct {
+		name    string
+		maxURLs int
+		want    map[string]string
+	}{
+		{
+			name:    "single file",
+			maxURLs: MaxURLsPerXMLSitemap,
+			want: map[string]string{
+				"sitemap.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://example.com/</loc>
+  </url>
+  <url>
+    <loc>https://example.com/a?x=1&amp;y=2</loc>
+  </url>
+  <url>
+    <loc>https://example.com/b</loc>
+  </url>
+  <url>
+    <loc>https://example.com/b/c</loc>
+  </url>
+  <url>
+    <loc>https://example.com/b/c/d/e/f</loc>
+  </url>
+  <url>
+    <loc>https://example.com/b/c/d/e/fg</loc>
+  </url>
+</urlset>
+`,
+			},
+		},
+		{
+			name:    "split with an index",
+			maxURLs: 4,
+			want: map[string]string{
+				"sitemap.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>https://example.com/sitemaps/sitemap-1.xml</loc>
+  </sitemap>
+  <sitemap>
+    <loc>https://example.com/sitemaps/sitemap-2.xml</loc>
+  </sitemap>
+</sitemapindex>
+`,
+				"sitemap-1.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://example.com/</loc>
+  </url>
+  <url>
+    <loc>https://example.com/a?x=1&amp;y=2</loc>
+  </url>
+  <url>
+    <loc>https://example.com/b</loc>
+  </url>
+  <url>
+    <loc>https://example.com/b/c</loc>
+  </url>
+</urlset>
+`,
+				"sitemap-2.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://example.com/b/c/d/e/f</loc>
+  </url>
+  <url>
+    <loc>https://example.com/b/c/d/e/fg</loc>
+  </url>
+</urlset>
+`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := make(map[string]*bytes.Buffer)
+			writer := NewXMLWriter(crawlertest.MakeURL("https://example.com/sitemaps/"), "sitemap.xml", func(fileName string) (io.WriteCloser, error) {
+				files[fileName] = new(bytes.Buffer)
+				return nopCloser{files[fileName]}, nil
+			})
+			writer.MaxURLs = tt.maxURLs
+
+			if err := sitemap.Write(writer); err != nil {
+				t.Fatalf("Sitemap.Write() error = %v", err)
+			}
+
+			got := make(map[string]string)
+			for fileName, buffer := range files {
+				got[fileName] = buffer.String()
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Sitemap.Write() wrote %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXMLWriter_splitsBySize(t *testing.T) {
+	var fileNames []string
+	writer := NewXMLWriter(url.URL{Scheme: "https", Host: "example.com", Path: "/"}, "sitemap.xml", func(fileName string) (io.WriteCloser, error) {
+		fileNames = append(fileNames, fileName)
+		return nopCloser{ioutil.Discard}, nil
+	})
+	writer.MaxBytes = len(xmlSitemapHeader) + 2*len(xmlEntry("url", "https://example.com/a")) + len(xmlSitemapFooter)
+
+	sitemap := Sitemap{
+		crawlertest.MakeURL("https://example.com/a"): {},
+		crawlertest.MakeURL("https://example.com/b"): {},
+		crawlertest.MakeURL("https://example.com/c"): {},
+	}
+	if err := sitemap.Write(writer); err != nil {
+		t.Fatalf("Sitemap.Write() error = %v", err)
+	}
+
+	want := []string{"sitemap-1.xml", "sitemap-2.xml", "sitemap.xml"}
+	if !reflect.DeepEqual(fileNames, want) {
+		t.Errorf("Sitemap.Write() created %v, want %v", fileNames, want)
+	}
+}
@@ -0,0 +1,224 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hilverd/sitemapper/sitemap"
+)
+
+const (
+	AlertPagesRemoved     = "pages-removed"
+	AlertNewlyBrokenPages = "newly-broken-pages"
+	AlertPageCountChanged = "page-count-changed"
+)
+
+type Alert struct {
+	Kind    string   `json:"kind"`
+	Message string   `json:"message"`
+	URLs    []string `json:"urls,omitempty"`
+}
+
+// A Notification is sent after a crawl that raised alerts.
+type Notification struct {
+	SeedURL          string    `json:"seed_url"`
+	StartedAt        time.Time `json:"started_at"`
+	Snapshot         string    `json:"snapshot"`
+	PreviousSnapshot string    `json:"previous_snapshot"`
+	Pages            int       `json:"pages"`
+	PreviousPages    int       `json:"previous_pages"`
+	Alerts           []Alert   `json:"alerts"`
+}
+
+// PageCountThreshold says by how much the number of pages may change between two crawls before an
+// alert is raised, either as a number of pages or as a percentage of the previous number of pages.
+// The zero value never raises an alert.
+type PageCountThreshold struct {
+	Pages   int
+	Percent float64
+}
+
+// ParsePageCountThreshold parses a threshold such as 50 (pages) or 10%.
+func ParsePageCountThreshold(threshold string) (PageCountThreshold, error) {
+	if strings.HasSuffix(threshold, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(threshold, "%"), 64)
+		if err != nil || percent <= 0 {
+			return PageCountThreshold{}, fmt.Errorf("Invalid page count threshold %q", threshold)
+		}
+		return PageCountThreshold{Percent: percent}, nil
+	}
+
+	pages, err := strconv.Atoi(threshold)
+	if err != nil || pages <= 0 {
+		return PageCountThreshold{}, fmt.Errorf("Invalid page count threshold %q", threshold)
+	}
+
+	return PageCountThreshold{Pages: pages}, nil
+}
+
+func (threshold PageCountThreshold) exceeded(oldCount int, newCount int) bool {
+	change := newCount - oldCount
+	if change < 0 {
+		change = -change
+	}
+
+	switch {
+	case threshold.Pages > 0:
+		return change >= threshold.Pages
+	case threshold.Percent > 0:
+		if oldCount == 0 {
+			return newCount > 0
+		}
+		return float64(change)*100 >= threshold.Percent*float64(oldCount)
+	default:
+		return false
+	}
+}
+
+// Alerts compares two snapshots and returns alerts for pages that disappeared, pages that became
+// broken, and a change in the number of pages that reaches the threshold.
+func Alerts(old sitemap.Sitemap, new sitemap.Sitemap, threshold PageCountThreshold) []Alert {
+	result := make([]Alert, 0)
+	difference := sitemap.Diff(old, new)
+
+	if len(difference.RemovedPages) > 0 {
+		result = append(result, Alert{
+			Kind:    AlertPagesRemoved,
+			Message: fmt.Sprintf("%d pages disappeared", len(difference.RemovedPages)),
+			URLs:    urlStrings(difference.RemovedPages),
+		})
+	}
+
+	if len(difference.NewlyBrokenPages) > 0 {
+		result = append(result, Alert{
+			Kind:    AlertNewlyBrokenPages,
+			Message: fmt.Sprintf("%d pages are newly broken", len(difference.NewlyBrokenPages)),
+			URLs:    urlStrings(difference.NewlyBrokenPages),
+		})
+	}
+
+	if oldCount, newCount := pageCount(old), pageCount(new); threshold.exceeded(oldCount, newCount) {
+		result = append(result, Alert{
+			Kind:    AlertPageCountChanged,
+			Message: fmt.Sprintf("The number of pages changed from %d to %d", oldCount, newCount),
+		})
+	}
+
+	return result
+}
+
+// pageCount returns the number of pages that were crawled successfully.
+func pageCount(result sitemap.Sitemap) int {
+	count := 0
+	for _, page := range result {
+		if !page.Failed() {
+			count++
+		}
+	}
+
+	return count
+}
+
+func urlStrings(URLs []url.URL) []string {
+	result := make([]string, 0, len(URLs))
+	for _, URL := range URLs {
+		result = append(result, URL.String())
+	}
+
+	return result
+}
+
+// A Notifier sends notifications about alerts.
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// defaultWebhookTimeout is how long a Webhook waits for a response if it has no Timeout of its own.
+const defaultWebhookTimeout = 30 * time.Second
+
+// Webhook is a Notifier that POSTs each notification as JSON to a URL.
+type Webhook struct {
+	URL     string
+	Client  *http.Client
+	Timeout time.Duration // how long to wait for a response (default 30 seconds)
+}
+
+func (webhook Webhook) Notify(ctx context.Context, notification Notification) error {
+	timeout := webhook.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	client := webhook.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("Failed to call webhook: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("Webhook responded with %s", response.Status)
+	}
+
+	return nil
+}
+
+// Command is a Notifier that runs a shell command for each notification, with the notification as
+// JSON on its standard input, and a summary of the alerts in the SITEMAPPER_ALERTS environment
+// variable.
+type Command struct {
+	Command string
+}
+
+func (command Command) Notify(ctx context.Context, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	messages := make([]string, 0, len(notification.Alerts))
+	for _, alert := range notification.Alerts {
+		messages = append(messages, alert.Message)
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command.Command)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"SITEMAPPER_ALERTS="+strings.Join(messages, "\n"),
+		"SITEMAPPER_SEED_URL="+notification.SeedURL,
+		"SITEMAPPER_SNAPSHOT="+notification.Snapshot,
+		"SITEMAPPER_PREVIOUS_SNAPSHOT="+notification.PreviousSnapshot,
+	)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("Hook command failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
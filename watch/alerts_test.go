@@ -0,0 +1,176 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hilverd/sitemapper/crawlertest"
+	"github.com/hilverd/sitemapper/sitemap"
+)
+
+func TestAlerts(t *testing.T) {
+	old := sitemap.Sitemap{
+		crawlertest.MakeURL("https://example.com/"):      {URLs: []url.URL{}},
+		crawlertest.MakeURL("https://example.com/a"):     {Depth: 1, URLs: []url.URL{}},
+		crawlertest.MakeURL("https://example.com/b"):     {Depth: 1, URLs: []url.URL{}},
+		crawlertest.MakeURL("https://example.com/c"):     {Depth: 1, URLs: []url.URL{}},
+		crawlertest.MakeURL("https://example.com/d"):     {Depth: 1, URLs: []url.URL{}},
+		crawlertest.MakeURL("https://example.com/gone"):  {Depth: 1, StatusCode: 404, Error: "Not Found"},
+		crawlertest.MakeURL("https://example.com/fixed"): {Depth: 1, StatusCode: 500, Error: "Internal Server Error"},
+	}
+	new := sitemap.Sitemap{
+		crawlertest.MakeURL("https://example.com/"):      {URLs: []url.URL{}},
+		crawlertest.MakeURL("https://example.com/a"):     {Depth: 1, URLs: []url.URL{}},
+		crawlertest.MakeURL("https://example.com/b"):     {Depth: 1, StatusCode: 503, Error: "Service Unavailable"},
+		crawlertest.MakeURL("https://example.com/gone"):  {Depth: 1, StatusCode: 404, Error: "Not Found"},
+		crawlertest.MakeURL("https://example.com/fixed"): {Depth: 1, URLs: []url.URL{}},
+	}
+
+	tests := []struct {
+		name      string
+		threshold PageCountThreshold
+		want      []Alert
+	}{
+		{
+			name: "without a page count threshold",
+			want: []Alert{
+				{Kind: AlertPagesRemoved, Message: "2 pages disappeared", URLs: []string{"https://example.com/c", "https://example.com/d"}},
+				{Kind: AlertNewlyBrokenPages, Message: "1 pages are newly broken", URLs: []string{"https://example.com/b"}},
+			},
+		},
+		{
+			name:      "page count changed by a percentage",
+			threshold: PageCountThreshold{Percent: 40},
+			want: []Alert{
+				{Kind: AlertPagesRemoved, Message: "2 pages disappeared", URLs: []string{"https://example.com/c", "https://example.com/d"}},
+				{Kind: AlertNewlyBrokenPages, Message: "1 pages are newly broken", URLs: []string{"https://example.com/b"}},
+				{Kind: AlertPageCountChanged, Message: "The number of pages changed from 5 to 3"},
+			},
+		},
+		{
+			name:      "page count changed by less than the threshold",
+			threshold: PageCountThreshold{Pages: 3},
+			want: []Alert{
+				{Kind: AlertPagesRemoved, Message: "2 pages disappeared", URLs: []string{"https://example.com/c", "https://example.com/d"}},
+				{Kind: AlertNewlyBrokenPages, Message: "1 pages are newly broken", URLs: []string{"https://example.com/b"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Alerts(old, new, tt.threshold); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Alerts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := Alerts(old, old, PageCountThreshold{Pages: 1}); len(got) != 0 {
+		t.Errorf("Alerts() for identical snapshots = %v", got)
+	}
+}
+
+func TestParsePageCountThreshold(t *testing.T) {
+	tests := []struct {
+		threshold string
+		want      PageCountThreshold
+		wantErr   bool
+	}{
+		{threshold: "50", want: PageCountThreshold{Pages: 50}},
+		{threshold: "12.5%", want: PageCountThreshold{Percent: 12.5}},
+		{threshold: "0", wantErr: true},
+		{threshold: "-5%", wantErr: true},
+		{threshold: "many", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.threshold, func(t *testing.T) {
+			got, err := ParsePageCountThreshold(tt.threshold)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParsePageCountThreshold() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParsePageCountThreshold() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+var testNotification = Notification{
+	SeedURL:  "https://example.com/",
+	Snapshot: "snapshot-20210501T120000Z.ndjson",
+	Pages:    3,
+	Alerts:   []Alert{{Kind: AlertPagesRemoved, Message: "2 pages disappeared", URLs: []string{"https://example.com/c", "https://example.com/d"}}},
+}
+
+func TestWebhook(t *testing.T) {
+	var got Notification
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost || request.Header.Get("Content-Type") != "application/json" {
+			http.Error(writer, "Unexpected request", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(request.Body).Decode(&got); err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	if err := (Webhook{URL: server.URL}).Notify(context.Background(), testNotification); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, testNotification) {
+		t.Errorf("Webhook received %v, want %v", got, testNotification)
+	}
+
+	failing := httptest.NewServer(http.NotFoundHandler())
+	defer failing.Close()
+
+	if err := (Webhook{URL: failing.URL}).Notify(context.Background(), testNotification); err == nil {
+		t.Errorf("Notify() did not return an error for a failing webhook")
+	}
+}
+
+func TestWebhook_timesOut(t *testing.T) {
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		<-release
+	}))
+	defer hanging.Close()
+	defer close(release)
+
+	webhook := Webhook{URL: hanging.URL, Timeout: 10 * time.Millisecond}
+	if err := webhook.Notify(context.Background(), testNotification); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Notify() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "output")
+
+	command := Command{Command: `printf '%s\n' "$SITEMAPPER_ALERTS" > ` + output + ` && cat >> ` + output}
+	if err := command.Notify(context.Background(), testNotification); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	got, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.SplitN(string(got), "\n", 2); lines[0] != "2 pages disappeared" || !strings.Contains(lines[1], `"seed_url":"https://example.com/"`) {
+		t.Errorf("Command wrote %q", got)
+	}
+
+	if err := (Command{Command: "echo oops >&2; exit 3"}).Notify(context.Background(), testNotification); err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Errorf("Notify() error = %v, want one containing the command's output", err)
+	}
+}
@@ -0,0 +1,98 @@
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hilverd/sitemapper/sitemap"
+)
+
+const snapshotTimeFormat = "20060102T150405Z"
+
+// A History keeps a snapshot of each crawl in a directory, as an NDJSON file named after the time the
+// crawl started. If Keep is greater than zero, only that many of the most recent snapshots are kept.
+type History struct {
+	Directory string
+	Keep      int
+}
+
+// Save saves a snapshot and returns its file name.
+func (history History) Save(result sitemap.Sitemap, startedAt time.Time) (string, error) {
+	if err := os.MkdirAll(history.Directory, 0755); err != nil {
+		return "", err
+	}
+
+	fileName := filepath.Join(history.Directory, "snapshot-"+startedAt.UTC().Format(snapshotTimeFormat)+".ndjson")
+
+	file, err := ioutil.TempFile(history.Directory, ".snapshot-*.tmp")
+	if err != nil {
+		return "", err
+	}
+
+	err = result.Write(sitemap.NewNDJSONWriter(file))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), fileName)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	return fileName, history.prune()
+}
+
+// Latest returns the most recent snapshot and its file name, or an empty file name if there are no
+// snapshots yet.
+func (history History) Latest() (sitemap.Sitemap, string, error) {
+	fileNames, err := history.snapshots()
+	if err != nil || len(fileNames) == 0 {
+		return nil, "", err
+	}
+
+	fileName := fileNames[len(fileNames)-1]
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	result, err := sitemap.ReadNDJSON(file)
+	return result, fileName, err
+}
+
+// snapshots returns the file names of all snapshots, oldest first.
+func (history History) snapshots() ([]string, error) {
+	fileNames, err := filepath.Glob(filepath.Join(history.Directory, "snapshot-*.ndjson"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(fileNames)
+	return fileNames, nil
+}
+
+func (history History) prune() error {
+	if history.Keep <= 0 {
+		return nil
+	}
+
+	fileNames, err := history.snapshots()
+	if err != nil {
+		return err
+	}
+
+	for len(fileNames) > history.Keep {
+		if err := os.Remove(fileNames[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		fileNames = fileNames[1:]
+	}
+
+	return nil
+}
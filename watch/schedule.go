@@ -0,0 +1,150 @@
+package watch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Schedule says when to crawl next, given the time the previous crawl started.
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// Every is a Schedule that crawls at a fixed interval.
+type Every time.Duration
+
+func (every Every) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(every))
+}
+
+// Cron is a Schedule given by a cron expression with five fields: minute, hour, day of month, month
+// and day of week. Each field is *, a number, a range such as 1-5, or a list of these separated by
+// commas, optionally followed by a step such as */15. Sunday is day 0 or 7. As in cron, if both the
+// day of month and the day of week are restricted, a day matches if either of them does.
+type Cron struct {
+	minutes, hours, daysOfMonth, months, daysOfWeek map[int]bool
+	anyDayOfMonth, anyDayOfWeek                     bool
+}
+
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+func ParseCron(expression string) (*Cron, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("Invalid cron expression %q: expected %d fields", expression, len(cronFields))
+	}
+
+	values := make([]map[int]bool, len(fields))
+	for i, field := range fields {
+		var err error
+		if values[i], err = parseCronField(field, cronFields[i].min, cronFields[i].max); err != nil {
+			return nil, fmt.Errorf("Invalid %s in cron expression %q: %w", cronFields[i].name, expression, err)
+		}
+	}
+
+	if values[4][7] {
+		values[4][0] = true
+	}
+
+	return &Cron{
+		minutes:       values[0],
+		hours:         values[1],
+		daysOfMonth:   values[2],
+		months:        values[3],
+		daysOfWeek:    values[4],
+		anyDayOfMonth: fields[2] == "*",
+		anyDayOfWeek:  fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, min int, max int) (map[int]bool, error) {
+	result := make(map[int]bool)
+
+	for _, part := range strings.Split(field, ",") {
+		step, hasStep := 1, false
+		if i := strings.Index(part, "/"); i >= 0 {
+			hasStep = true
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step %q", part[i+1:])
+			}
+			part = part[:i]
+		}
+
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid value %q", bounds[0])
+			}
+			if !hasStep {
+				to = from
+			}
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value %q", bounds[1])
+				}
+			}
+			if from < min || to > max || from > to {
+				return nil, fmt.Errorf("%q is not within %d-%d", part, min, max)
+			}
+		}
+
+		for value := from; value <= to; value += step {
+			result[value] = true
+		}
+	}
+
+	return result, nil
+}
+
+// Next returns the first minute after the given time that matches the expression, in the location of
+// that time, or the zero time if there is no such minute in the next five years.
+func (cron *Cron) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case !cron.months[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !cron.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !cron.hours[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !cron.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (cron *Cron) dayMatches(t time.Time) bool {
+	dayOfMonth := cron.daysOfMonth[t.Day()]
+	dayOfWeek := cron.daysOfWeek[int(t.Weekday())]
+
+	switch {
+	case cron.anyDayOfMonth:
+		return dayOfWeek
+	case cron.anyDayOfWeek:
+		return dayOfMonth
+	default:
+		return dayOfMonth || dayOfWeek
+	}
+}
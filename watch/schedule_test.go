@@ -0,0 +1,53 @@
+package watch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCron_Next(t *testing.T) {
+	// Saturday 1 May 2021, 12:34:56
+	after := time.Date(2021, 5, 1, 12, 34, 56, 0, time.UTC)
+
+	tests := []struct {
+		expression string
+		want       time.Time
+	}{
+		{"* * * * *", time.Date(2021, 5, 1, 12, 35, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 5, 1, 12, 45, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2021, 5, 1, 12, 45, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2021, 5, 2, 3, 0, 0, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2021, 5, 3, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, 5, 2, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 15 * 1", time.Date(2021, 5, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 12 29 2 *", time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			cron, err := ParseCron(tt.expression)
+			if err != nil {
+				t.Fatalf("ParseCron() error = %v", err)
+			}
+			if got := cron.Next(after); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCron_invalid(t *testing.T) {
+	for _, expression := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* * 0 * *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+	} {
+		if _, err := ParseCron(expression); err == nil {
+			t.Errorf("ParseCron(%q) did not return an error", expression)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Package watch crawls a site on a schedule, keeps a history of snapshots, and sends notifications
+// when a crawl finds that pages disappeared, pages became broken, or the number of pages changed a lot.
+package watch
+
+import (
+	"context"
+	"time"
+
+	"github.com/hilverd/sitemapper/crawler"
+	"github.com/hilverd/sitemapper/logging"
+	"github.com/hilverd/sitemapper/sitemap"
+)
+
+// CrawlFunc crawls the site being watched.
+type CrawlFunc func(ctx context.Context) (sitemap.Sitemap, crawler.Report, error)
+
+// A Watcher crawls a site whenever its Schedule says so. If RunImmediately is true, it also crawls as
+// soon as it starts. Logger must not be nil.
+type Watcher struct {
+	SeedURL            string
+	Schedule           Schedule
+	RunImmediately     bool
+	History            History
+	Crawl              CrawlFunc
+	PageCountThreshold PageCountThreshold
+	Notifiers          []Notifier
+	Logger             *logging.Logger
+}
+
+// Run crawls whenever the schedule says so, until ctx is done.
+func (watcher Watcher) Run(ctx context.Context) error {
+	next := time.Now()
+	if !watcher.RunImmediately {
+		next = watcher.Schedule.Next(next)
+	}
+
+	for {
+		if next.IsZero() {
+			watcher.Logger.Warn("The schedule has no more crawls")
+			return nil
+		}
+
+		watcher.Logger.Info("Waiting for next crawl", "at", next.Format(time.RFC3339))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		startedAt := time.Now()
+		if err := watcher.RunOnce(ctx, startedAt); err != nil {
+			watcher.Logger.Error("Crawl failed", "url", watcher.SeedURL, "error", err)
+		}
+
+		next = watcher.Schedule.Next(startedAt)
+		if now := time.Now(); !next.IsZero() && next.Before(now) {
+			next = now
+		}
+	}
+}
+
+// RunOnce crawls, saves a snapshot, and sends notifications for any alerts raised by comparing it with
+// the previous snapshot. A crawl that is cancelled because ctx is done is not saved.
+func (watcher Watcher) RunOnce(ctx context.Context, startedAt time.Time) error {
+	previous, previousFileName, err := watcher.History.Latest()
+	if err != nil {
+		return err
+	}
+
+	result, report, err := watcher.Crawl(ctx)
+	if err != nil {
+		return err
+	}
+	if report.Cancelled {
+		return nil
+	}
+
+	fileName, err := watcher.History.Save(result, startedAt)
+	if err != nil {
+		return err
+	}
+	watcher.Logger.Info("Saved snapshot", "url", watcher.SeedURL, "file", fileName, "pages", pageCount(result))
+
+	if previousFileName == "" {
+		return nil
+	}
+
+	alerts := Alerts(previous, result, watcher.PageCountThreshold)
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	notification := Notification{
+		SeedURL:          watcher.SeedURL,
+		StartedAt:        startedAt,
+		Snapshot:         fileName,
+		PreviousSnapshot: previousFileName,
+		Pages:            pageCount(result),
+		PreviousPages:    pageCount(previous),
+		Alerts:           alerts,
+	}
+
+	for _, alert := range alerts {
+		watcher.Logger.Warn(alert.Message, "url", watcher.SeedURL, "alert", alert.Kind)
+	}
+
+	for _, notifier := range watcher.Notifiers {
+		if err := notifier.Notify(ctx, notification); err != nil {
+			watcher.Logger.Error("Failed to send notification", "url", watcher.SeedURL, "error", err)
+		}
+	}
+
+	return nil
+}
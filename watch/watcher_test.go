@@ -0,0 +1,116 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hilverd/sitemapper/crawler"
+	"github.com/hilverd/sitemapper/crawlertest"
+	"github.com/hilverd/sitemapper/logging"
+	"github.com/hilverd/sitemapper/sitemap"
+)
+
+type recordingNotifier struct {
+	notifications []Notification
+}
+
+func (notifier *recordingNotifier) Notify(ctx context.Context, notification Notification) error {
+	notifier.notifications = append(notifier.notifications, notification)
+	return nil
+}
+
+func TestHistory(t *testing.T) {
+	history := History{Directory: t.TempDir(), Keep: 2}
+
+	result, fileName, err := history.Latest()
+	if result != nil || fileName != "" || err != nil {
+		t.Fatalf("Latest() = %v, %q, %v for an empty history", result, fileName, err)
+	}
+
+	startedAt := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		snapshot := sitemap.Sitemap{crawlertest.MakeURL("https://example.com/"): {Depth: i, URLs: []url.URL{}}}
+		if _, err := history.Save(snapshot, startedAt.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	snapshots, err := history.snapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(history.Directory, "snapshot-20210501T130000Z.ndjson"),
+		filepath.Join(history.Directory, "snapshot-20210501T140000Z.ndjson"),
+	}
+	if !reflect.DeepEqual(snapshots, want) {
+		t.Errorf("snapshots() = %v, want %v", snapshots, want)
+	}
+
+	result, fileName, err = history.Latest()
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	if fileName != want[1] || result[crawlertest.MakeURL("https://example.com/")].Depth != 2 {
+		t.Errorf("Latest() = %v, %q", result, fileName)
+	}
+}
+
+func TestWatcher_RunOnce(t *testing.T) {
+	crawls := []sitemap.Sitemap{
+		{
+			crawlertest.MakeURL("https://example.com/"):  {URLs: []url.URL{crawlertest.MakeURL("https://example.com/a")}},
+			crawlertest.MakeURL("https://example.com/a"): {Depth: 1, URLs: []url.URL{}},
+		},
+		{
+			crawlertest.MakeURL("https://example.com/"):  {URLs: []url.URL{crawlertest.MakeURL("https://example.com/a")}},
+			crawlertest.MakeURL("https://example.com/a"): {Depth: 1, URLs: []url.URL{}},
+		},
+		{
+			crawlertest.MakeURL("https://example.com/"): {URLs: []url.URL{}},
+		},
+	}
+
+	notifier := &recordingNotifier{}
+	var logs bytes.Buffer
+	watcher := Watcher{
+		SeedURL: "https://example.com/",
+		History: History{Directory: t.TempDir()},
+		Crawl: func(ctx context.Context) (sitemap.Sitemap, crawler.Report, error) {
+			result := crawls[0]
+			crawls = crawls[1:]
+			return result, crawler.Report{}, nil
+		},
+		Notifiers: []Notifier{notifier},
+		Logger:    logging.New(&logs, logging.Text, logging.Warn),
+	}
+
+	startedAt := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if err := watcher.RunOnce(context.Background(), startedAt.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatalf("RunOnce() error = %v", err)
+		}
+	}
+
+	want := []Notification{{
+		SeedURL:          "https://example.com/",
+		StartedAt:        startedAt.Add(2 * time.Hour),
+		Snapshot:         filepath.Join(watcher.History.Directory, "snapshot-20210501T140000Z.ndjson"),
+		PreviousSnapshot: filepath.Join(watcher.History.Directory, "snapshot-20210501T130000Z.ndjson"),
+		Pages:            1,
+		PreviousPages:    2,
+		Alerts:           []Alert{{Kind: AlertPagesRemoved, Message: "1 pages disappeared", URLs: []string{"https://example.com/a"}}},
+	}}
+	if !reflect.DeepEqual(notifier.notifications, want) {
+		t.Errorf("RunOnce() sent %v, want %v", notifier.notifications, want)
+	}
+
+	if got, want := logs.String(), "level=WARN msg=\"1 pages disappeared\" url=https://example.com/ alert=pages-removed\n"; got != want {
+		t.Errorf("RunOnce() logged %q, want %q", got, want)
+	}
+}